@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"math/big"
 	"monkey/token"
 	"strings"
 )
@@ -9,6 +10,7 @@ import (
 type Node interface {
 	TokenLiteral() string //字面量
 	String() string       //Debug展示值
+	Pos() token.Position  //节点在源码中的起始位置
 }
 
 //Statement 语句（不产生值）
@@ -37,6 +39,13 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 	for _, s := range p.Statements {
@@ -71,6 +80,10 @@ func (l *LetStatement) TokenLiteral() string {
 	return l.Token.Literal
 }
 
+func (l *LetStatement) Pos() token.Position {
+	return l.Token.Pos
+}
+
 //Identifier 标识符
 type Identifier struct {
 	Token token.Token
@@ -88,6 +101,10 @@ func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *Identifier) Pos() token.Position {
+	return i.Token.Pos
+}
+
 //ReturnStatement return <表达式>;
 type ReturnStatement struct {
 	Token       token.Token
@@ -110,6 +127,10 @@ func (l *ReturnStatement) TokenLiteral() string {
 	return l.Token.Literal
 }
 
+func (l *ReturnStatement) Pos() token.Position {
+	return l.Token.Pos
+}
+
 //ExpressionStatement 表达式
 type ExpressionStatement struct {
 	Token      token.Token
@@ -129,15 +150,24 @@ func (l *ExpressionStatement) TokenLiteral() string {
 	return l.Token.Literal
 }
 
+func (l *ExpressionStatement) Pos() token.Position {
+	return l.Token.Pos
+}
+
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	Big   *big.Int //超出int64范围时不为空，此时Value无意义
 }
 
 func (i *IntegerLiteral) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *IntegerLiteral) Pos() token.Position {
+	return i.Token.Pos
+}
+
 func (i *IntegerLiteral) String() string {
 	return i.Token.Literal
 }
@@ -145,6 +175,27 @@ func (i *IntegerLiteral) String() string {
 func (i *IntegerLiteral) expressionNode() {
 }
 
+//FloatLiteral 浮点数字面量
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (f *FloatLiteral) TokenLiteral() string {
+	return f.Token.Literal
+}
+
+func (f *FloatLiteral) Pos() token.Position {
+	return f.Token.Pos
+}
+
+func (f *FloatLiteral) String() string {
+	return f.Token.Literal
+}
+
+func (f *FloatLiteral) expressionNode() {
+}
+
 //PrefixExpression 前缀表达式，由前缀token+表达式组成
 type PrefixExpression struct {
 	Token    token.Token
@@ -156,6 +207,10 @@ func (p *PrefixExpression) TokenLiteral() string {
 	return p.Token.Literal
 }
 
+func (p *PrefixExpression) Pos() token.Position {
+	return p.Token.Pos
+}
+
 func (p *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -180,6 +235,13 @@ func (i *InfixExpression) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *InfixExpression) Pos() token.Position {
+	if i.Left != nil {
+		return i.Left.Pos()
+	}
+	return i.Token.Pos
+}
+
 func (i *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -202,6 +264,10 @@ func (b *Boolean) TokenLiteral() string {
 	return b.Token.Literal
 }
 
+func (b *Boolean) Pos() token.Position {
+	return b.Token.Pos
+}
+
 func (b *Boolean) String() string {
 	return b.Token.Literal
 }
@@ -223,6 +289,10 @@ func (i *IfExpression) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *IfExpression) Pos() token.Position {
+	return i.Token.Pos
+}
+
 func (i *IfExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("if")
@@ -249,6 +319,10 @@ func (b *BlockStatement) TokenLiteral() string {
 	return b.Token.Literal
 }
 
+func (b *BlockStatement) Pos() token.Position {
+	return b.Token.Pos
+}
+
 func (b *BlockStatement) String() string {
 	var out bytes.Buffer
 	for _, s := range b.Statements {
@@ -265,7 +339,10 @@ func (b *BlockStatement) statementNode() {
 
 type FunctionLiteral struct {
 	Token      token.Token
+	Name       string //通过let绑定时的名称
 	Parameters []*Identifier
+	Defaults   map[string]Expression //参数默认值，键为参数名
+	Rest       *Identifier           //剩余参数 ...rest，可为空
 	Body       *BlockStatement
 }
 
@@ -273,12 +350,13 @@ func (f *FunctionLiteral) TokenLiteral() string {
 	return f.Token.Literal
 }
 
+func (f *FunctionLiteral) Pos() token.Position {
+	return f.Token.Pos
+}
+
 func (f *FunctionLiteral) String() string {
 	var out bytes.Buffer
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
+	params := ParameterStrings(f.Parameters, f.Defaults, f.Rest)
 	out.WriteString(f.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ","))
@@ -290,6 +368,22 @@ func (f *FunctionLiteral) String() string {
 func (f *FunctionLiteral) expressionNode() {
 }
 
+//ParameterStrings 将参数列表格式化为 a、b = 10、...rest 的形式
+func ParameterStrings(params []*Identifier, defaults map[string]Expression, rest *Identifier) []string {
+	out := []string{}
+	for _, p := range params {
+		if def, ok := defaults[p.Value]; ok {
+			out = append(out, p.String()+" = "+def.String())
+		} else {
+			out = append(out, p.String())
+		}
+	}
+	if rest != nil {
+		out = append(out, "..."+rest.String())
+	}
+	return out
+}
+
 type CallExpression struct {
 	Token     token.Token // The '(' token
 	Function  Expression  // Identifier or FunctionLiteral
@@ -298,6 +392,14 @@ type CallExpression struct {
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+
+func (ce *CallExpression) Pos() token.Position {
+	if ce.Function != nil {
+		return ce.Function.Pos()
+	}
+	return ce.Token.Pos
+}
+
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -323,6 +425,10 @@ func (s *StringLiteral) TokenLiteral() string {
 	return s.Token.Literal
 }
 
+func (s *StringLiteral) Pos() token.Position {
+	return s.Token.Pos
+}
+
 func (s *StringLiteral) String() string {
 	return s.Token.Literal
 }
@@ -339,6 +445,10 @@ func (a *ArrayLiteral) TokenLiteral() string {
 	return a.Token.Literal
 }
 
+func (a *ArrayLiteral) Pos() token.Position {
+	return a.Token.Pos
+}
+
 func (a *ArrayLiteral) String() string {
 	var out bytes.Buffer
 	elements := []string{}
@@ -364,6 +474,13 @@ func (i *IndexExpression) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *IndexExpression) Pos() token.Position {
+	if i.Left != nil {
+		return i.Left.Pos()
+	}
+	return i.Token.Pos
+}
+
 func (i *IndexExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -386,11 +503,15 @@ func (h *HashLiteral) TokenLiteral() string {
 	return h.Token.Literal
 }
 
+func (h *HashLiteral) Pos() token.Position {
+	return h.Token.Pos
+}
+
 func (h *HashLiteral) String() string {
 	var out bytes.Buffer
 	pairs := []string{}
-	for key, value := range h.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range SortedKeys(h.Pairs) {
+		pairs = append(pairs, key.String()+":"+h.Pairs[key].String())
 	}
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ", "))
@@ -413,6 +534,13 @@ func (as *AssignStatement) expressionNode() {}
 // TokenLiteral returns the literal token.
 func (as *AssignStatement) TokenLiteral() string { return as.Token.Literal }
 
+func (as *AssignStatement) Pos() token.Position {
+	if as.Name != nil {
+		return as.Name.Pos()
+	}
+	return as.Token.Pos
+}
+
 // String returns this object as a string.
 func (as *AssignStatement) String() string {
 	var out bytes.Buffer
@@ -421,3 +549,232 @@ func (as *AssignStatement) String() string {
 	out.WriteString(as.Value.String())
 	return out.String()
 }
+
+//ForStatement for (<初始化>; <条件>; <后置>) <循环体>
+//三个部分均可省略，省略条件时表示无限循环
+type ForStatement struct {
+	Token     token.Token
+	Label     *Identifier //循环标签，可为空
+	Init      Statement   //循环开始前执行一次
+	Condition Expression  //每轮开始前求值，为假时退出
+	Post      Expression  //每轮结束后求值
+	Body      *BlockStatement
+}
+
+func (f *ForStatement) TokenLiteral() string {
+	return f.Token.Literal
+}
+
+func (f *ForStatement) Pos() token.Position {
+	if f.Label != nil {
+		return f.Label.Pos()
+	}
+	return f.Token.Pos
+}
+
+func (f *ForStatement) String() string {
+	var out bytes.Buffer
+	if f.Label != nil {
+		out.WriteString(f.Label.String() + ": ")
+	}
+	out.WriteString("for (")
+	if f.Init != nil {
+		out.WriteString(strings.TrimSuffix(f.Init.String(), ";"))
+	}
+	out.WriteString("; ")
+	if f.Condition != nil {
+		out.WriteString(f.Condition.String())
+	}
+	out.WriteString("; ")
+	if f.Post != nil {
+		out.WriteString(f.Post.String())
+	}
+	out.WriteString(") ")
+	out.WriteString(f.Body.String())
+	return out.String()
+}
+
+func (f *ForStatement) statementNode() {
+}
+
+//ForInStatement for (<变量> in <可迭代表达式>) <循环体>
+//可迭代对象为数组、字符串以及哈希（遍历键）
+type ForInStatement struct {
+	Token    token.Token
+	Label    *Identifier
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (f *ForInStatement) TokenLiteral() string {
+	return f.Token.Literal
+}
+
+func (f *ForInStatement) Pos() token.Position {
+	if f.Label != nil {
+		return f.Label.Pos()
+	}
+	return f.Token.Pos
+}
+
+func (f *ForInStatement) String() string {
+	var out bytes.Buffer
+	if f.Label != nil {
+		out.WriteString(f.Label.String() + ": ")
+	}
+	out.WriteString("for (")
+	out.WriteString(f.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(f.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(f.Body.String())
+	return out.String()
+}
+
+func (f *ForInStatement) statementNode() {
+}
+
+//BreakStatement break [<标签>];
+type BreakStatement struct {
+	Token token.Token
+	Label *Identifier //为空时跳出最内层循环
+}
+
+func (b *BreakStatement) TokenLiteral() string {
+	return b.Token.Literal
+}
+
+func (b *BreakStatement) Pos() token.Position {
+	return b.Token.Pos
+}
+
+func (b *BreakStatement) String() string {
+	if b.Label != nil {
+		return b.TokenLiteral() + " " + b.Label.String() + ";"
+	}
+	return b.TokenLiteral() + ";"
+}
+
+func (b *BreakStatement) statementNode() {
+}
+
+//ContinueStatement continue [<标签>];
+type ContinueStatement struct {
+	Token token.Token
+	Label *Identifier //为空时继续最内层循环
+}
+
+func (c *ContinueStatement) TokenLiteral() string {
+	return c.Token.Literal
+}
+
+func (c *ContinueStatement) Pos() token.Position {
+	return c.Token.Pos
+}
+
+func (c *ContinueStatement) String() string {
+	if c.Label != nil {
+		return c.TokenLiteral() + " " + c.Label.String() + ";"
+	}
+	return c.TokenLiteral() + ";"
+}
+
+func (c *ContinueStatement) statementNode() {
+}
+
+//TryExpression try <语句块> catch (<变量>) <语句块> finally <语句块>
+//catch和finally至少出现一个，catch的变量可以省略
+type TryExpression struct {
+	Token      token.Token
+	Block      *BlockStatement
+	CatchParam *Identifier //绑定捕获的错误
+	Catch      *BlockStatement
+	Finally    *BlockStatement
+}
+
+func (t *TryExpression) TokenLiteral() string {
+	return t.Token.Literal
+}
+
+func (t *TryExpression) Pos() token.Position {
+	return t.Token.Pos
+}
+
+func (t *TryExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("try ")
+	out.WriteString(t.Block.String())
+	if t.Catch != nil {
+		out.WriteString(" catch")
+		if t.CatchParam != nil {
+			out.WriteString("(" + t.CatchParam.String() + ")")
+		}
+		out.WriteString(" ")
+		out.WriteString(t.Catch.String())
+	}
+	if t.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(t.Finally.String())
+	}
+	return out.String()
+}
+
+func (t *TryExpression) expressionNode() {
+}
+
+//ThrowStatement throw <表达式>;
+type ThrowStatement struct {
+	Token token.Token
+	Value Expression
+}
+
+func (t *ThrowStatement) TokenLiteral() string {
+	return t.Token.Literal
+}
+
+func (t *ThrowStatement) Pos() token.Position {
+	return t.Token.Pos
+}
+
+func (t *ThrowStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(t.TokenLiteral() + " ")
+	if t.Value != nil {
+		out.WriteString(t.Value.String())
+	}
+	out.WriteString(";")
+	return out.String()
+}
+
+func (t *ThrowStatement) statementNode() {
+}
+
+//MacroLiteral macro(<参数>) <块>，宏在展开阶段以未求值的语法树作为实参
+type MacroLiteral struct {
+	Token      token.Token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (m *MacroLiteral) TokenLiteral() string {
+	return m.Token.Literal
+}
+
+func (m *MacroLiteral) Pos() token.Position {
+	return m.Token.Pos
+}
+
+func (m *MacroLiteral) String() string {
+	var out bytes.Buffer
+	params := ParameterStrings(m.Parameters, nil, nil)
+	out.WriteString(m.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ","))
+	out.WriteString(")")
+	out.WriteString(m.Body.String())
+	return out.String()
+}
+
+func (m *MacroLiteral) expressionNode() {
+}
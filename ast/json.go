@@ -0,0 +1,661 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"monkey/token"
+)
+
+// ast/json.go
+//
+//语法树的JSON编码，每个节点是一个对象：kind为节点类型，token为节点的token（含位置），其余为各节点的字段。
+//空的切片与nil分别编码为[]与null，解码后得到与编码前完全相同的语法树
+
+//EncodeJSON 将语法树编码为JSON
+func EncodeJSON(node Node) ([]byte, error) {
+	return json.Marshal(encodeNode(node))
+}
+
+//DecodeJSON 由JSON重建语法树，缺少必需的子节点时返回错误
+func DecodeJSON(data []byte) (Node, error) {
+	d := &jsonDecoder{}
+	node := d.node(data)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return node, nil
+}
+
+//DecodeProgramJSON 由JSON重建程序节点
+func DecodeProgramJSON(data []byte) (*Program, error) {
+	node, err := DecodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	program, ok := node.(*Program)
+	if !ok {
+		return nil, fmt.Errorf("ast json: expected Program, got %T", node)
+	}
+	return program, nil
+}
+
+type jsonPosition struct {
+	Filename string `json:"filename,omitempty"`
+	Offset   int    `json:"offset"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+type jsonToken struct {
+	Type    token.TokenType `json:"type"`
+	Literal string          `json:"literal"`
+	Pos     jsonPosition    `json:"pos"`
+	End     jsonPosition    `json:"end"`
+}
+
+type jsonPair struct {
+	Key   interface{} `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+func encodePosition(p token.Position) jsonPosition {
+	return jsonPosition{Filename: p.Filename, Offset: p.Offset, Line: p.Line, Column: p.Column}
+}
+
+func encodeToken(t token.Token) jsonToken {
+	return jsonToken{Type: t.Type, Literal: t.Literal, Pos: encodePosition(t.Pos), End: encodePosition(t.End)}
+}
+
+//encodeNode 节点编码为map，nil节点编码为null
+func encodeNode(node Node) interface{} {
+	m := map[string]interface{}{}
+	switch node := node.(type) {
+	case *Program:
+		if node == nil {
+			return nil
+		}
+		m["statements"] = encodeStatements(node.Statements)
+	case *LetStatement:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["name"] = encodeNode(node.Name)
+		m["value"] = encodeNode(node.Value)
+	case *Identifier:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["value"] = node.Value
+	case *ReturnStatement:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["returnValue"] = encodeNode(node.ReturnValue)
+	case *ExpressionStatement:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["expression"] = encodeNode(node.Expression)
+	case *IntegerLiteral:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["value"] = node.Value
+		if node.Big != nil {
+			m["big"] = node.Big.String()
+		}
+	case *FloatLiteral:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["value"] = node.Value
+	case *PrefixExpression:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["operator"] = node.Operator
+		m["right"] = encodeNode(node.Right)
+	case *InfixExpression:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["left"] = encodeNode(node.Left)
+		m["operator"] = node.Operator
+		m["right"] = encodeNode(node.Right)
+	case *Boolean:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["value"] = node.Value
+	case *IfExpression:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["condition"] = encodeNode(node.Condition)
+		m["consequence"] = encodeNode(node.Consequence)
+		m["alternative"] = encodeNode(node.Alternative)
+	case *BlockStatement:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["statements"] = encodeStatements(node.Statements)
+	case *FunctionLiteral:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["name"] = node.Name
+		m["parameters"] = encodeIdentifiers(node.Parameters)
+		if node.Defaults != nil {
+			defaults := map[string]interface{}{}
+			for name, def := range node.Defaults {
+				defaults[name] = encodeNode(def)
+			}
+			m["defaults"] = defaults
+		} else {
+			m["defaults"] = nil
+		}
+		m["rest"] = encodeNode(node.Rest)
+		m["body"] = encodeNode(node.Body)
+	case *MacroLiteral:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["parameters"] = encodeIdentifiers(node.Parameters)
+		m["body"] = encodeNode(node.Body)
+	case *CallExpression:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["function"] = encodeNode(node.Function)
+		m["arguments"] = encodeExpressions(node.Arguments)
+	case *StringLiteral:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["value"] = node.Value
+	case *ArrayLiteral:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["elements"] = encodeExpressions(node.Elements)
+	case *IndexExpression:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["left"] = encodeNode(node.Left)
+		m["index"] = encodeNode(node.Index)
+	case *HashLiteral:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		if node.Pairs != nil {
+			//按键在源码中的位置排序，保证编码结果是确定的
+			pairs := []jsonPair{}
+			for _, k := range SortedKeys(node.Pairs) {
+				pairs = append(pairs, jsonPair{Key: encodeNode(k), Value: encodeNode(node.Pairs[k])})
+			}
+			m["pairs"] = pairs
+		} else {
+			m["pairs"] = nil
+		}
+	case *AssignStatement:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["name"] = encodeNode(node.Name)
+		m["operator"] = node.Operator
+		m["value"] = encodeNode(node.Value)
+	case *ForStatement:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["label"] = encodeNode(node.Label)
+		m["init"] = encodeNode(node.Init)
+		m["condition"] = encodeNode(node.Condition)
+		m["post"] = encodeNode(node.Post)
+		m["body"] = encodeNode(node.Body)
+	case *ForInStatement:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["label"] = encodeNode(node.Label)
+		m["variable"] = encodeNode(node.Variable)
+		m["iterable"] = encodeNode(node.Iterable)
+		m["body"] = encodeNode(node.Body)
+	case *BreakStatement:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["label"] = encodeNode(node.Label)
+	case *ContinueStatement:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["label"] = encodeNode(node.Label)
+	case *TryExpression:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["block"] = encodeNode(node.Block)
+		m["catchParam"] = encodeNode(node.CatchParam)
+		m["catch"] = encodeNode(node.Catch)
+		m["finally"] = encodeNode(node.Finally)
+	case *ThrowStatement:
+		if node == nil {
+			return nil
+		}
+		m["token"] = encodeToken(node.Token)
+		m["value"] = encodeNode(node.Value)
+	case nil:
+		return nil
+	default:
+		panic(fmt.Sprintf("ast json: unknown node %T", node))
+	}
+	m["kind"] = kindOf(node)
+	return m
+}
+
+func kindOf(node Node) string {
+	t := fmt.Sprintf("%T", node)
+	return t[len("*ast."):]
+}
+
+func encodeIdentifiers(idents []*Identifier) interface{} {
+	if idents == nil {
+		return nil
+	}
+	out := []interface{}{}
+	for _, i := range idents {
+		out = append(out, encodeNode(i))
+	}
+	return out
+}
+
+func encodeStatements(stmts []Statement) interface{} {
+	if stmts == nil {
+		return nil
+	}
+	out := []interface{}{}
+	for _, s := range stmts {
+		out = append(out, encodeNode(s))
+	}
+	return out
+}
+
+func encodeExpressions(exps []Expression) interface{} {
+	if exps == nil {
+		return nil
+	}
+	out := []interface{}{}
+	for _, e := range exps {
+		out = append(out, encodeNode(e))
+	}
+	return out
+}
+
+//jsonDecoder 记录第一个错误，出错后的解码都返回零值
+type jsonDecoder struct {
+	err error
+}
+
+func (d *jsonDecoder) fail(format string, a ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf("ast json: "+format, a...)
+	}
+}
+
+func isNull(data json.RawMessage) bool {
+	return len(data) == 0 || string(data) == "null"
+}
+
+//node 解码一个节点，null解码为nil
+func (d *jsonDecoder) node(data json.RawMessage) Node {
+	if d.err != nil || isNull(data) {
+		return nil
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		d.fail("%s", err)
+		return nil
+	}
+	var kind string
+	d.value(m, "kind", &kind)
+	node := d.decode(kind, m)
+	if d.err == nil {
+		for _, key := range requiredFields[kind] {
+			if isNull(m[key]) {
+				d.missing(kind, key)
+			}
+		}
+	}
+	if d.err != nil {
+		return nil
+	}
+	return node
+}
+
+//requiredFields 各种节点必须有的子节点，语法分析器不会产生缺少这些子节点的语法树
+var requiredFields = map[string][]string{
+	"LetStatement":        {"name", "value"},
+	"ReturnStatement":     {"returnValue"},
+	"ExpressionStatement": {"expression"},
+	"PrefixExpression":    {"right"},
+	"InfixExpression":     {"left", "right"},
+	"IfExpression":        {"condition", "consequence"},
+	"FunctionLiteral":     {"body"},
+	"MacroLiteral":        {"body"},
+	"CallExpression":      {"function"},
+	"IndexExpression":     {"left", "index"},
+	"AssignStatement":     {"name", "value"},
+	"ForStatement":        {"body"},
+	"ForInStatement":      {"variable", "iterable", "body"},
+	"TryExpression":       {"block"},
+	"ThrowStatement":      {"value"},
+}
+
+func (d *jsonDecoder) missing(kind, field string) {
+	d.fail("%s: missing %s", kind, field)
+}
+
+//decode 按kind解码节点的字段
+func (d *jsonDecoder) decode(kind string, m map[string]json.RawMessage) Node {
+	switch kind {
+	case "Program":
+		return &Program{Statements: d.statements(m["statements"])}
+	case "LetStatement":
+		return &LetStatement{Token: d.token(m), Name: d.identifier(m["name"]), Value: d.expression(m["value"])}
+	case "Identifier":
+		node := &Identifier{Token: d.token(m)}
+		d.value(m, "value", &node.Value)
+		return node
+	case "ReturnStatement":
+		return &ReturnStatement{Token: d.token(m), ReturnValue: d.expression(m["returnValue"])}
+	case "ExpressionStatement":
+		return &ExpressionStatement{Token: d.token(m), Expression: d.expression(m["expression"])}
+	case "IntegerLiteral":
+		node := &IntegerLiteral{Token: d.token(m)}
+		d.value(m, "value", &node.Value)
+		if !isNull(m["big"]) {
+			var s string
+			d.value(m, "big", &s)
+			big, ok := new(big.Int).SetString(s, 10)
+			if !ok {
+				d.fail("invalid big integer %q", s)
+			}
+			node.Big = big
+		}
+		return node
+	case "FloatLiteral":
+		node := &FloatLiteral{Token: d.token(m)}
+		d.value(m, "value", &node.Value)
+		return node
+	case "PrefixExpression":
+		node := &PrefixExpression{Token: d.token(m), Right: d.expression(m["right"])}
+		d.value(m, "operator", &node.Operator)
+		return node
+	case "InfixExpression":
+		node := &InfixExpression{Token: d.token(m), Left: d.expression(m["left"]), Right: d.expression(m["right"])}
+		d.value(m, "operator", &node.Operator)
+		return node
+	case "Boolean":
+		node := &Boolean{Token: d.token(m)}
+		d.value(m, "value", &node.Value)
+		return node
+	case "IfExpression":
+		return &IfExpression{
+			Token:       d.token(m),
+			Condition:   d.expression(m["condition"]),
+			Consequence: d.block(m["consequence"]),
+			Alternative: d.block(m["alternative"]),
+		}
+	case "BlockStatement":
+		return &BlockStatement{Token: d.token(m), Statements: d.statements(m["statements"])}
+	case "FunctionLiteral":
+		node := &FunctionLiteral{Token: d.token(m), Rest: d.identifier(m["rest"]), Body: d.block(m["body"])}
+		d.value(m, "name", &node.Name)
+		node.Parameters = d.identifiers(m["parameters"], kind, "parameters")
+		if !isNull(m["defaults"]) {
+			var defaults map[string]json.RawMessage
+			d.value(m, "defaults", &defaults)
+			node.Defaults = map[string]Expression{}
+			for name, def := range defaults {
+				if isNull(def) {
+					d.missing(kind, "default of "+name)
+				}
+				node.Defaults[name] = d.expression(def)
+			}
+		}
+		return node
+	case "MacroLiteral":
+		return &MacroLiteral{Token: d.token(m), Parameters: d.identifiers(m["parameters"], kind, "parameters"), Body: d.block(m["body"])}
+	case "CallExpression":
+		return &CallExpression{Token: d.token(m), Function: d.expression(m["function"]), Arguments: d.expressions(m["arguments"], kind, "arguments")}
+	case "StringLiteral":
+		node := &StringLiteral{Token: d.token(m)}
+		d.value(m, "value", &node.Value)
+		return node
+	case "ArrayLiteral":
+		return &ArrayLiteral{Token: d.token(m), Elements: d.expressions(m["elements"], kind, "elements")}
+	case "IndexExpression":
+		return &IndexExpression{Token: d.token(m), Left: d.expression(m["left"]), Index: d.expression(m["index"])}
+	case "HashLiteral":
+		node := &HashLiteral{Token: d.token(m)}
+		if !isNull(m["pairs"]) {
+			var pairs []struct {
+				Key   json.RawMessage `json:"key"`
+				Value json.RawMessage `json:"value"`
+			}
+			d.value(m, "pairs", &pairs)
+			node.Pairs = map[Expression]Expression{}
+			for _, pair := range pairs {
+				if isNull(pair.Key) {
+					d.missing(kind, "pair key")
+				} else if isNull(pair.Value) {
+					d.missing(kind, "pair value")
+				}
+				if key := d.expression(pair.Key); key != nil {
+					node.Pairs[key] = d.expression(pair.Value)
+				}
+			}
+		}
+		return node
+	case "AssignStatement":
+		node := &AssignStatement{Token: d.token(m), Name: d.identifier(m["name"]), Value: d.expression(m["value"])}
+		d.value(m, "operator", &node.Operator)
+		return node
+	case "ForStatement":
+		node := &ForStatement{
+			Token:     d.token(m),
+			Label:     d.identifier(m["label"]),
+			Condition: d.expression(m["condition"]),
+			Post:      d.expression(m["post"]),
+			Body:      d.block(m["body"]),
+		}
+		if init := d.node(m["init"]); init != nil {
+			if stmt, ok := init.(Statement); ok {
+				node.Init = stmt
+			} else {
+				d.fail("expected statement, got %s", kindOf(init))
+			}
+		}
+		return node
+	case "ForInStatement":
+		return &ForInStatement{
+			Token:    d.token(m),
+			Label:    d.identifier(m["label"]),
+			Variable: d.identifier(m["variable"]),
+			Iterable: d.expression(m["iterable"]),
+			Body:     d.block(m["body"]),
+		}
+	case "BreakStatement":
+		return &BreakStatement{Token: d.token(m), Label: d.identifier(m["label"])}
+	case "ContinueStatement":
+		return &ContinueStatement{Token: d.token(m), Label: d.identifier(m["label"])}
+	case "TryExpression":
+		node := &TryExpression{
+			Token:      d.token(m),
+			Block:      d.block(m["block"]),
+			CatchParam: d.identifier(m["catchParam"]),
+			Catch:      d.block(m["catch"]),
+			Finally:    d.block(m["finally"]),
+		}
+		if node.Catch == nil && node.Finally == nil {
+			d.missing(kind, "catch or finally")
+		} else if node.Catch == nil && node.CatchParam != nil {
+			d.missing(kind, "catch")
+		}
+		return node
+	case "ThrowStatement":
+		return &ThrowStatement{Token: d.token(m), Value: d.expression(m["value"])}
+	default:
+		d.fail("unknown node kind %q", kind)
+		return nil
+	}
+}
+
+//value 解码字段，缺少的字段保持零值
+func (d *jsonDecoder) value(m map[string]json.RawMessage, key string, v interface{}) {
+	if d.err != nil || isNull(m[key]) {
+		return
+	}
+	if err := json.Unmarshal(m[key], v); err != nil {
+		d.fail("field %s: %s", key, err)
+	}
+}
+
+func decodePosition(p jsonPosition) token.Position {
+	return token.Position{Filename: p.Filename, Offset: p.Offset, Line: p.Line, Column: p.Column}
+}
+
+func (d *jsonDecoder) token(m map[string]json.RawMessage) token.Token {
+	var t jsonToken
+	d.value(m, "token", &t)
+	return token.Token{Type: t.Type, Literal: t.Literal, Pos: decodePosition(t.Pos), End: decodePosition(t.End)}
+}
+
+func (d *jsonDecoder) expression(data json.RawMessage) Expression {
+	node := d.node(data)
+	if node == nil {
+		return nil
+	}
+	exp, ok := node.(Expression)
+	if !ok {
+		d.fail("expected expression, got %s", kindOf(node))
+		return nil
+	}
+	return exp
+}
+
+func (d *jsonDecoder) identifier(data json.RawMessage) *Identifier {
+	node := d.node(data)
+	if node == nil {
+		return nil
+	}
+	ident, ok := node.(*Identifier)
+	if !ok {
+		d.fail("expected Identifier, got %s", kindOf(node))
+		return nil
+	}
+	return ident
+}
+
+func (d *jsonDecoder) block(data json.RawMessage) *BlockStatement {
+	node := d.node(data)
+	if node == nil {
+		return nil
+	}
+	block, ok := node.(*BlockStatement)
+	if !ok {
+		d.fail("expected BlockStatement, got %s", kindOf(node))
+		return nil
+	}
+	return block
+}
+
+func (d *jsonDecoder) list(data json.RawMessage) []json.RawMessage {
+	if d.err != nil || isNull(data) {
+		return nil
+	}
+	items := []json.RawMessage{}
+	if err := json.Unmarshal(data, &items); err != nil {
+		d.fail("%s", err)
+		return nil
+	}
+	return items
+}
+
+func (d *jsonDecoder) statements(data json.RawMessage) []Statement {
+	items := d.list(data)
+	if items == nil {
+		return nil
+	}
+	stmts := []Statement{}
+	for _, item := range items {
+		node := d.node(item)
+		stmt, ok := node.(Statement)
+		if !ok {
+			if d.err == nil {
+				d.fail("expected statement, got %T", node)
+			}
+			return nil
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+//expressions 解码表达式列表，列表中不能有null，kind和field用于错误信息
+func (d *jsonDecoder) expressions(data json.RawMessage, kind, field string) []Expression {
+	items := d.list(data)
+	if items == nil {
+		return nil
+	}
+	exps := []Expression{}
+	for i, item := range items {
+		if isNull(item) {
+			d.missing(kind, fmt.Sprintf("%s[%d]", field, i))
+		}
+		exps = append(exps, d.expression(item))
+	}
+	return exps
+}
+
+func (d *jsonDecoder) identifiers(data json.RawMessage, kind, field string) []*Identifier {
+	items := d.list(data)
+	if items == nil {
+		return nil
+	}
+	idents := []*Identifier{}
+	for i, item := range items {
+		if isNull(item) {
+			d.missing(kind, fmt.Sprintf("%s[%d]", field, i))
+		}
+		idents = append(idents, d.identifier(item))
+	}
+	return idents
+}
@@ -0,0 +1,115 @@
+package ast_test
+
+import (
+	"bytes"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ast/json_test.go
+
+func TestJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		input string
+		//哈希字面量以指针作为键且String()的顺序不固定，只比较再次编码的结果
+		hasHash bool
+	}{
+		{"let x = 5; let y = x;", false},
+		{"return 1 + 2 * -3;", false},
+		{`"str" + "ing"; 1.5; 99999999999999999999; true; !false`, false},
+		{"if (x < 1) { x } else { y }; if (x) { }", false},
+		{"let add = fn(a, b = 2, ...rest) { return a + b; }; add(1, 2, 3); fn() {}", false},
+		{`[1, [2, 3]][0]; {"a": 1, 2: fn(x) { x }, true: {}}["a"]; {}`, true},
+		{"x = 10; let f = fn() { y = y + 1; }", false},
+		{"for (let i = 0; i < 10; i = i + 1) { continue; } for (;;) { break; }", false},
+		{"outer: for (x in [1, 2]) { for (y in x) { break outer; continue outer; } }", false},
+		{"let m = macro(a, b) { quote(unquote(a) + b) }; macro() {}", false},
+		{`try { throw "x"; } catch (e) { e["message"] } finally { 1 }; try { 1 } catch { 2 }`, false},
+	}
+	for _, tt := range tests {
+		input := tt.input
+		l := lexer.NewWithFilename("test.mk", input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		if len(p.Diagnostics()) != 0 {
+			t.Fatalf("parser errors for %q: %v", input, p.Errors())
+		}
+		data, err := ast.EncodeJSON(program)
+		if err != nil {
+			t.Fatalf("encode error for %q: %s", input, err)
+		}
+		decoded, err := ast.DecodeProgramJSON(data)
+		if err != nil {
+			t.Fatalf("decode error for %q: %s", input, err)
+		}
+		if !tt.hasHash && !reflect.DeepEqual(program, decoded) {
+			t.Errorf("decoded ast differs for %q.\nwant=%s\ngot=%s", input, program, decoded)
+		}
+		again, err := ast.EncodeJSON(decoded)
+		if err != nil {
+			t.Fatalf("encode error for %q: %s", input, err)
+		}
+		if !bytes.Equal(data, again) {
+			t.Errorf("encoding is not stable for %q.\nwant=%s\ngot=%s", input, data, again)
+		}
+		if !tt.hasHash && decoded.String() != program.String() {
+			t.Errorf("wrong String() for %q. want=%q, got=%q", input, program.String(), decoded.String())
+		}
+	}
+}
+
+func TestJSONKindsAndPositions(t *testing.T) {
+	program := parser.New(lexer.New("let x = 5;")).ParseProgram()
+	data, err := ast.EncodeJSON(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range []string{
+		`"kind":"Program"`,
+		`"kind":"LetStatement"`,
+		`"kind":"Identifier"`,
+		`"kind":"IntegerLiteral"`,
+		`"pos":{"offset":8,"line":1,"column":9}`,
+	} {
+		if !strings.Contains(string(data), part) {
+			t.Errorf("json does not contain %s.\n%s", part, data)
+		}
+	}
+}
+
+func TestJSONDecodeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"kind":"Nope"}`, `unknown node kind "Nope"`},
+		{`{"kind":"Program","statements":[{"kind":"Identifier","value":"x"}]}`, "expected statement"},
+		{`{"kind":"LetStatement","name":{"kind":"IntegerLiteral","value":1}}`, "expected Identifier"},
+		{`{"kind":"IntegerLiteral","value":"one"}`, "field value"},
+		{`{"kind":"IntegerLiteral","big":"1x"}`, "invalid big integer"},
+		{`[1, 2]`, "cannot unmarshal"},
+		{`{"kind":"IfExpression","condition":{"kind":"Boolean","value":true}}`, "IfExpression: missing consequence"},
+		{`{"kind":"ForInStatement","iterable":{"kind":"Identifier","value":"x"},"body":{"kind":"BlockStatement"}}`, "ForInStatement: missing variable"},
+		{`{"kind":"LetStatement","name":{"kind":"Identifier","value":"x"}}`, "LetStatement: missing value"},
+		{`{"kind":"InfixExpression","operator":"+","right":{"kind":"Boolean","value":true}}`, "InfixExpression: missing left"},
+		{`{"kind":"Program","statements":[{"kind":"ExpressionStatement"}]}`, "ExpressionStatement: missing expression"},
+		{`{"kind":"CallExpression","function":{"kind":"Identifier","value":"f"},"arguments":[null]}`, "CallExpression: missing arguments[0]"},
+		{`{"kind":"FunctionLiteral","parameters":[null],"body":{"kind":"BlockStatement"}}`, "FunctionLiteral: missing parameters[0]"},
+		{`{"kind":"FunctionLiteral","defaults":{"a":null},"body":{"kind":"BlockStatement"}}`, "FunctionLiteral: missing default of a"},
+		{`{"kind":"HashLiteral","pairs":[{"key":{"kind":"Boolean","value":true}}]}`, "HashLiteral: missing pair value"},
+		{`{"kind":"TryExpression","block":{"kind":"BlockStatement"}}`, "TryExpression: missing catch or finally"},
+	}
+	for _, tt := range tests {
+		_, err := ast.DecodeJSON([]byte(tt.input))
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("wrong error for %s. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+	if _, err := ast.DecodeProgramJSON([]byte(`{"kind":"Identifier","value":"x"}`)); err == nil {
+		t.Errorf("expected error for non-program root")
+	}
+}
@@ -0,0 +1,133 @@
+package ast
+
+//ModifierFunc 替换节点，返回原节点表示不修改
+type ModifierFunc func(Node) Node
+
+//Modify 自底向上改写语法树：先改写子节点，再以改写后的节点调用modifier
+//子节点被替换为类型不符的节点时，该子节点置为空
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch node := node.(type) {
+	case *Program:
+		for i, s := range node.Statements {
+			node.Statements[i], _ = Modify(s, modifier).(Statement)
+		}
+	case *LetStatement:
+		node.Name = modifyIdentifier(node.Name, modifier)
+		node.Value = modifyExpression(node.Value, modifier)
+	case *ReturnStatement:
+		node.ReturnValue = modifyExpression(node.ReturnValue, modifier)
+	case *ExpressionStatement:
+		node.Expression = modifyExpression(node.Expression, modifier)
+	case *PrefixExpression:
+		node.Right = modifyExpression(node.Right, modifier)
+	case *InfixExpression:
+		node.Left = modifyExpression(node.Left, modifier)
+		node.Right = modifyExpression(node.Right, modifier)
+	case *IfExpression:
+		node.Condition = modifyExpression(node.Condition, modifier)
+		node.Consequence = modifyBlock(node.Consequence, modifier)
+		node.Alternative = modifyBlock(node.Alternative, modifier)
+	case *BlockStatement:
+		for i, s := range node.Statements {
+			node.Statements[i], _ = Modify(s, modifier).(Statement)
+		}
+	case *FunctionLiteral:
+		//参数可能被改名，默认值的键随参数一起更新
+		var defaults map[string]Expression
+		if node.Defaults != nil {
+			defaults = map[string]Expression{}
+		}
+		for i, p := range node.Parameters {
+			param := modifyIdentifier(p, modifier)
+			if def, ok := node.Defaults[p.Value]; ok && param != nil {
+				defaults[param.Value] = modifyExpression(def, modifier)
+			}
+			node.Parameters[i] = param
+		}
+		node.Defaults = defaults
+		node.Rest = modifyIdentifier(node.Rest, modifier)
+		node.Body = modifyBlock(node.Body, modifier)
+	case *MacroLiteral:
+		for i, p := range node.Parameters {
+			node.Parameters[i] = modifyIdentifier(p, modifier)
+		}
+		node.Body = modifyBlock(node.Body, modifier)
+	case *CallExpression:
+		node.Function = modifyExpression(node.Function, modifier)
+		for i, a := range node.Arguments {
+			node.Arguments[i] = modifyExpression(a, modifier)
+		}
+	case *ArrayLiteral:
+		for i, e := range node.Elements {
+			node.Elements[i] = modifyExpression(e, modifier)
+		}
+	case *IndexExpression:
+		node.Left = modifyExpression(node.Left, modifier)
+		node.Index = modifyExpression(node.Index, modifier)
+	case *HashLiteral:
+		//键可能被替换，需要重建映射
+		pairs := make(map[Expression]Expression, len(node.Pairs))
+		for _, key := range SortedKeys(node.Pairs) {
+			newKey := modifyExpression(key, modifier)
+			newValue := modifyExpression(node.Pairs[key], modifier)
+			if newKey != nil {
+				pairs[newKey] = newValue
+			}
+		}
+		node.Pairs = pairs
+	case *AssignStatement:
+		node.Name = modifyIdentifier(node.Name, modifier)
+		node.Value = modifyExpression(node.Value, modifier)
+	case *ForStatement:
+		node.Label = modifyIdentifier(node.Label, modifier)
+		if node.Init != nil {
+			node.Init, _ = Modify(node.Init, modifier).(Statement)
+		}
+		node.Condition = modifyExpression(node.Condition, modifier)
+		node.Post = modifyExpression(node.Post, modifier)
+		node.Body = modifyBlock(node.Body, modifier)
+	case *ForInStatement:
+		node.Label = modifyIdentifier(node.Label, modifier)
+		node.Variable = modifyIdentifier(node.Variable, modifier)
+		node.Iterable = modifyExpression(node.Iterable, modifier)
+		node.Body = modifyBlock(node.Body, modifier)
+	case *BreakStatement:
+		node.Label = modifyIdentifier(node.Label, modifier)
+	case *ContinueStatement:
+		node.Label = modifyIdentifier(node.Label, modifier)
+	case *TryExpression:
+		node.Block = modifyBlock(node.Block, modifier)
+		node.CatchParam = modifyIdentifier(node.CatchParam, modifier)
+		node.Catch = modifyBlock(node.Catch, modifier)
+		node.Finally = modifyBlock(node.Finally, modifier)
+	case *ThrowStatement:
+		node.Value = modifyExpression(node.Value, modifier)
+	}
+	return modifier(node)
+}
+
+//以下函数跳过为空的子节点，并将改写结果转换为字段的类型
+
+func modifyExpression(e Expression, modifier ModifierFunc) Expression {
+	if e == nil {
+		return nil
+	}
+	modified, _ := Modify(e, modifier).(Expression)
+	return modified
+}
+
+func modifyIdentifier(i *Identifier, modifier ModifierFunc) *Identifier {
+	if i == nil {
+		return nil
+	}
+	modified, _ := Modify(i, modifier).(*Identifier)
+	return modified
+}
+
+func modifyBlock(b *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if b == nil {
+		return nil
+	}
+	modified, _ := Modify(b, modifier).(*BlockStatement)
+	return modified
+}
@@ -0,0 +1,78 @@
+package ast_test
+
+import (
+	"monkey/ast"
+	"testing"
+)
+
+// ast/modify_test.go
+
+func TestModify(t *testing.T) {
+	turnOneIntoTwo := func(node ast.Node) ast.Node {
+		integer, ok := node.(*ast.IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+		integer.Value = 2
+		integer.Token.Literal = "2"
+		return integer
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1", "2"},
+		{"1 + 2", "(2 + 2)"},
+		{"-1", "(-2)"},
+		{"a[1]", "(a[2])"},
+		{"if (1) { 1 } else { 1 }", "if2 2else 2"},
+		{"return 1;", "return 2;"},
+		{"let x = 1;", "let x = 2;"},
+		{"x = 1;", "x=2"},
+		{"fn(a = 1) { 1 }", "fn(a = 2)2"},
+		{"[1, 1]", "[2, 2]"},
+		{"f(1)", "f(2)"},
+		{"throw 1;", "throw 2;"},
+	}
+	for _, tt := range tests {
+		modified := ast.Modify(parse(t, tt.input), turnOneIntoTwo)
+		if modified.String() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, modified.String())
+		}
+	}
+
+	hash := ast.Modify(parse(t, "{1: 1}"), turnOneIntoTwo).(*ast.Program)
+	literal := hash.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.HashLiteral)
+	for key, value := range literal.Pairs {
+		if key.(*ast.IntegerLiteral).Value != 2 || value.(*ast.IntegerLiteral).Value != 2 {
+			t.Errorf("hash pair not modified. got=%s: %s", key, value)
+		}
+	}
+}
+
+func TestModifyRenamesDefaults(t *testing.T) {
+	rename := func(node ast.Node) ast.Node {
+		ident, ok := node.(*ast.Identifier)
+		if !ok {
+			return node
+		}
+		switch ident.Value {
+		case "a":
+			return &ast.Identifier{Token: ident.Token, Value: "b"}
+		case "b":
+			return &ast.Identifier{Token: ident.Token, Value: "c"}
+		}
+		return node
+	}
+	program := ast.Modify(parse(t, "fn(a = 1, b = 2) { a + b }"), rename).(*ast.Program)
+	fn := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if b := fn.Defaults["b"].(*ast.IntegerLiteral); b.Value != 1 {
+		t.Errorf("wrong default for b. want=1, got=%d", b.Value)
+	}
+	if c := fn.Defaults["c"].(*ast.IntegerLiteral); c.Value != 2 {
+		t.Errorf("wrong default for c. want=2, got=%d", c.Value)
+	}
+	if len(fn.Defaults) != 2 {
+		t.Errorf("wrong defaults. got=%v", fn.Defaults)
+	}
+}
@@ -0,0 +1,151 @@
+package ast
+
+import "sort"
+
+//Visitor Walk遇到每个节点时调用Visit，返回的Visitor用于遍历该节点的子节点，返回nil时不再遍历子节点
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+//Walk 深度优先遍历语法树，子节点按源码中的顺序访问，子节点遍历完后调用w.Visit(nil)
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	switch n := node.(type) {
+	case *Program:
+		for _, s := range n.Statements {
+			Walk(v, s)
+		}
+	case *LetStatement:
+		walkIdentifier(v, n.Name)
+		walkExpression(v, n.Value)
+	case *ReturnStatement:
+		walkExpression(v, n.ReturnValue)
+	case *ExpressionStatement:
+		walkExpression(v, n.Expression)
+	case *PrefixExpression:
+		walkExpression(v, n.Right)
+	case *InfixExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Right)
+	case *IfExpression:
+		walkExpression(v, n.Condition)
+		walkBlock(v, n.Consequence)
+		walkBlock(v, n.Alternative)
+	case *BlockStatement:
+		for _, s := range n.Statements {
+			Walk(v, s)
+		}
+	case *FunctionLiteral:
+		for _, p := range n.Parameters {
+			walkIdentifier(v, p)
+			if def, ok := n.Defaults[p.Value]; ok {
+				walkExpression(v, def)
+			}
+		}
+		walkIdentifier(v, n.Rest)
+		walkBlock(v, n.Body)
+	case *MacroLiteral:
+		for _, p := range n.Parameters {
+			walkIdentifier(v, p)
+		}
+		walkBlock(v, n.Body)
+	case *CallExpression:
+		walkExpression(v, n.Function)
+		for _, a := range n.Arguments {
+			walkExpression(v, a)
+		}
+	case *ArrayLiteral:
+		for _, e := range n.Elements {
+			walkExpression(v, e)
+		}
+	case *IndexExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Index)
+	case *HashLiteral:
+		for _, k := range SortedKeys(n.Pairs) {
+			walkExpression(v, k)
+			walkExpression(v, n.Pairs[k])
+		}
+	case *AssignStatement:
+		walkIdentifier(v, n.Name)
+		walkExpression(v, n.Value)
+	case *ForStatement:
+		walkIdentifier(v, n.Label)
+		if n.Init != nil {
+			Walk(v, n.Init)
+		}
+		walkExpression(v, n.Condition)
+		walkExpression(v, n.Post)
+		walkBlock(v, n.Body)
+	case *ForInStatement:
+		walkIdentifier(v, n.Label)
+		walkIdentifier(v, n.Variable)
+		walkExpression(v, n.Iterable)
+		walkBlock(v, n.Body)
+	case *BreakStatement:
+		walkIdentifier(v, n.Label)
+	case *ContinueStatement:
+		walkIdentifier(v, n.Label)
+	case *TryExpression:
+		walkBlock(v, n.Block)
+		walkIdentifier(v, n.CatchParam)
+		walkBlock(v, n.Catch)
+		walkBlock(v, n.Finally)
+	case *ThrowStatement:
+		walkExpression(v, n.Value)
+	}
+	v.Visit(nil)
+}
+
+//以下函数跳过为空的子节点
+
+func walkExpression(v Visitor, e Expression) {
+	if e != nil {
+		Walk(v, e)
+	}
+}
+
+func walkIdentifier(v Visitor, i *Identifier) {
+	if i != nil {
+		Walk(v, i)
+	}
+}
+
+func walkBlock(v Visitor, b *BlockStatement) {
+	if b != nil {
+		Walk(v, b)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+//Inspect 深度优先遍历语法树，对每个节点调用f，f返回false时不再遍历该节点的子节点
+//子节点遍历完后调用f(nil)
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+//SortedKeys 哈希字面量的键，按在源码中的位置排序
+func SortedKeys(pairs map[Expression]Expression) []Expression {
+	keys := make([]Expression, 0, len(pairs))
+	for k := range pairs {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		pi, pj := keys[i].Pos(), keys[j].Pos()
+		if pi.Offset != pj.Offset {
+			return pi.Offset < pj.Offset
+		}
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
@@ -0,0 +1,122 @@
+package ast_test
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"reflect"
+	"testing"
+)
+
+// ast/walk_test.go
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+//nodeNames 按访问顺序记录节点，标识符和字面量带上值
+func nodeNames(node ast.Node) []string {
+	names := []string{}
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case nil:
+		case *ast.Identifier:
+			names = append(names, "Identifier "+n.Value)
+		case *ast.IntegerLiteral:
+			names = append(names, fmt.Sprintf("IntegerLiteral %d", n.Value))
+		case *ast.StringLiteral:
+			names = append(names, "StringLiteral "+n.Value)
+		default:
+			names = append(names, fmt.Sprintf("%T", n)[len("*ast."):])
+		}
+		return true
+	})
+	return names
+}
+
+func TestInspectOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = -1;", []string{"Program", "LetStatement", "Identifier x", "PrefixExpression", "IntegerLiteral 1"}},
+		{"x = a[1];", []string{"Program", "ExpressionStatement", "AssignStatement", "Identifier x", "IndexExpression", "Identifier a", "IntegerLiteral 1"}},
+		{"if (a) { 1 } else { 2 }", []string{
+			"Program", "ExpressionStatement", "IfExpression", "Identifier a",
+			"BlockStatement", "ExpressionStatement", "IntegerLiteral 1",
+			"BlockStatement", "ExpressionStatement", "IntegerLiteral 2",
+		}},
+		{"fn(a, b = 1, ...c) { b }", []string{
+			"Program", "ExpressionStatement", "FunctionLiteral",
+			"Identifier a", "Identifier b", "IntegerLiteral 1", "Identifier c",
+			"BlockStatement", "ExpressionStatement", "Identifier b",
+		}},
+		{`{"b": 2, "a": 1}`, []string{
+			"Program", "ExpressionStatement", "HashLiteral",
+			"StringLiteral b", "IntegerLiteral 2", "StringLiteral a", "IntegerLiteral 1",
+		}},
+		{"try { f(1) } catch (e) { throw e; }", []string{
+			"Program", "ExpressionStatement", "TryExpression",
+			"BlockStatement", "ExpressionStatement", "CallExpression", "Identifier f", "IntegerLiteral 1",
+			"Identifier e", "BlockStatement", "ThrowStatement", "Identifier e",
+		}},
+		{"for (x in [1]) { break; }", []string{
+			"Program", "ForInStatement", "Identifier x", "ArrayLiteral", "IntegerLiteral 1",
+			"BlockStatement", "BreakStatement",
+		}},
+	}
+	for _, tt := range tests {
+		names := nodeNames(parse(t, tt.input))
+		if !reflect.DeepEqual(names, tt.expected) {
+			t.Errorf("wrong order for %q.\nwant=%q\ngot=%q", tt.input, tt.expected, names)
+		}
+	}
+}
+
+func TestInspectPrune(t *testing.T) {
+	program := parse(t, "let f = fn(a) { a + 1 }; f(2)")
+	var idents []string
+	ast.Inspect(program, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Identifier); ok {
+			idents = append(idents, ident.Value)
+		}
+		_, isFn := n.(*ast.FunctionLiteral)
+		return !isFn
+	})
+	expected := []string{"f", "f"}
+	if !reflect.DeepEqual(idents, expected) {
+		t.Errorf("wrong identifiers. want=%q, got=%q", expected, idents)
+	}
+}
+
+//countVisitor 统计进入与离开的次数，两者应当相等
+type countVisitor struct {
+	enter, leave int
+}
+
+func (v *countVisitor) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		v.leave++
+	} else {
+		v.enter++
+	}
+	return v
+}
+
+func TestWalkNilChildren(t *testing.T) {
+	program := parse(t, "for (;;) { return 1; } if (x) { }; try { } catch { }")
+	v := &countVisitor{}
+	ast.Walk(v, program)
+	if v.enter != v.leave {
+		t.Errorf("unbalanced walk. enter=%d, leave=%d", v.enter, v.leave)
+	}
+	if v.enter != 13 {
+		t.Errorf("wrong node count. want=13, got=%d", v.enter)
+	}
+}
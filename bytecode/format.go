@@ -0,0 +1,514 @@
+package bytecode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"math/big"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/object"
+	"monkey/token"
+)
+
+// bytecode/format.go
+//
+//编译后程序的二进制格式(.mkc)，多字节整数均为大端序
+//
+//	magic    4字节 "MKC\x00"
+//	version  2字节
+//	payload  全局变量名、常量池、主程序的指令和行号表
+//	checksum 4字节 payload的CRC32
+//
+//payload中的整数使用变长编码，字符串为长度加内容
+
+//Magic 文件头
+const Magic = "MKC\x00"
+
+//Version 当前的格式版本，格式不兼容时递增
+const Version = 1
+
+//Extension 编译后文件的扩展名
+const Extension = ".mkc"
+
+var (
+	ErrNotBytecode = errors.New("not a compiled monkey program")
+	ErrVersion     = errors.New("unsupported bytecode version")
+	ErrCorrupt     = errors.New("corrupt bytecode")
+)
+
+//常量池中常量的类型标记
+const (
+	tagInteger byte = iota + 1
+	tagFloat
+	tagBigInt
+	tagString
+	tagFunction
+)
+
+//Encode 将编译结果写入w
+func Encode(w io.Writer, bc *compiler.Bytecode) error {
+	e := &encoder{}
+	e.strings(bc.Globals)
+	e.uint(len(bc.Constants))
+	for _, c := range bc.Constants {
+		if err := e.constant(c); err != nil {
+			return err
+		}
+	}
+	e.instructions(bc.Instructions)
+	e.positions(bc.Positions)
+
+	header := make([]byte, len(Magic)+2)
+	copy(header, Magic)
+	binary.BigEndian.PutUint16(header[len(Magic):], Version)
+	checksum := make([]byte, 4)
+	binary.BigEndian.PutUint32(checksum, crc32.ChecksumIEEE(e.buf.Bytes()))
+	for _, b := range [][]byte{header, e.buf.Bytes(), checksum} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Decode 读取编译结果，文件头、版本或内容有误时返回错误
+func Decode(r io.Reader) (*compiler.Bytecode, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < len(Magic)+2 || string(data[:len(Magic)]) != Magic {
+		return nil, ErrNotBytecode
+	}
+	if v := binary.BigEndian.Uint16(data[len(Magic):]); v != Version {
+		return nil, fmt.Errorf("%w: %d, want %d", ErrVersion, v, Version)
+	}
+	data = data[len(Magic)+2:]
+	if len(data) < 4 {
+		return nil, fmt.Errorf("%w: missing checksum", ErrCorrupt)
+	}
+	payload, checksum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+	}
+
+	d := &decoder{data: payload}
+	bc := &compiler.Bytecode{}
+	bc.Globals = d.strings()
+	n := d.count()
+	bc.Constants = make([]object.Object, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		bc.Constants = append(bc.Constants, d.constant())
+	}
+	bc.Instructions = d.instructions()
+	bc.Positions = d.positions()
+	if d.err == nil && d.pos != len(d.data) {
+		d.fail("unexpected trailing data")
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if err := validate(bc); err != nil {
+		return nil, err
+	}
+	return bc, nil
+}
+
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func (e *encoder) uint(v int) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], uint64(v))
+	e.buf.Write(b[:n])
+}
+
+func (e *encoder) int(v int64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], v)
+	e.buf.Write(b[:n])
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.uint(len(b))
+	e.buf.Write(b)
+}
+
+func (e *encoder) string(s string) {
+	e.bytes([]byte(s))
+}
+
+func (e *encoder) strings(ss []string) {
+	e.uint(len(ss))
+	for _, s := range ss {
+		e.string(s)
+	}
+}
+
+func (e *encoder) bool(b bool) {
+	if b {
+		e.buf.WriteByte(1)
+	} else {
+		e.buf.WriteByte(0)
+	}
+}
+
+func (e *encoder) instructions(ins code.Instructions) {
+	e.bytes(ins)
+}
+
+//positions 行号表，文件名只记录一次，偏移量记录与前一项的差
+func (e *encoder) positions(ps code.Positions) {
+	filename := ""
+	if len(ps) > 0 {
+		filename = ps[0].Pos.Filename
+	}
+	e.string(filename)
+	e.uint(len(ps))
+	last := 0
+	for _, p := range ps {
+		e.uint(p.Offset - last)
+		e.uint(p.Pos.Offset)
+		e.uint(p.Pos.Line)
+		e.uint(p.Pos.Column)
+		last = p.Offset
+	}
+}
+
+func (e *encoder) constant(obj object.Object) error {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		e.buf.WriteByte(tagInteger)
+		e.int(obj.Value)
+	case *object.Float:
+		e.buf.WriteByte(tagFloat)
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], math.Float64bits(obj.Value))
+		e.buf.Write(b[:])
+	case *object.BigInt:
+		e.buf.WriteByte(tagBigInt)
+		e.bool(obj.Value.Sign() < 0)
+		e.bytes(obj.Value.Bytes())
+	case *object.String:
+		e.buf.WriteByte(tagString)
+		e.string(obj.Value)
+	case *object.CompiledFunction:
+		e.buf.WriteByte(tagFunction)
+		e.string(obj.Name)
+		e.uint(obj.NumLocals)
+		e.uint(obj.NumParameters)
+		e.bool(obj.Rest)
+		e.uint(len(obj.Entries))
+		for _, entry := range obj.Entries {
+			e.uint(entry)
+		}
+		e.strings(obj.LocalNames)
+		e.strings(obj.FreeNames)
+		e.instructions(obj.Instructions)
+		e.positions(obj.Positions)
+	default:
+		return fmt.Errorf("cannot encode constant of type %s", obj.Type())
+	}
+	return nil
+}
+
+//decoder 按顺序读取payload，出错后的读取都返回零值，最后统一检查err
+type decoder struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (d *decoder) fail(format string, a ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: %s", ErrCorrupt, fmt.Sprintf(format, a...))
+	}
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if d.pos >= len(d.data) {
+		d.fail("unexpected end of data")
+		return 0
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b
+}
+
+func (d *decoder) uint() int {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 || v > math.MaxInt32 {
+		d.fail("invalid integer at %d", d.pos)
+		return 0
+	}
+	d.pos += n
+	return int(v)
+}
+
+func (d *decoder) int() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.data[d.pos:])
+	if n <= 0 {
+		d.fail("invalid integer at %d", d.pos)
+		return 0
+	}
+	d.pos += n
+	return v
+}
+
+//count 读取元素个数，每个元素至少占一个字节，个数不能超过剩余的字节数
+func (d *decoder) count() int {
+	n := d.uint()
+	if n > len(d.data)-d.pos {
+		d.fail("invalid length %d at %d", n, d.pos)
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.count()
+	if d.err != nil {
+		return nil
+	}
+	b := make([]byte, n)
+	copy(b, d.data[d.pos:d.pos+n])
+	d.pos += n
+	return b
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) strings() []string {
+	n := d.count()
+	ss := make([]string, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		ss = append(ss, d.string())
+	}
+	return ss
+}
+
+func (d *decoder) bool() bool {
+	switch d.byte() {
+	case 0:
+		return false
+	case 1:
+		return true
+	}
+	d.fail("invalid boolean at %d", d.pos-1)
+	return false
+}
+
+func (d *decoder) instructions() code.Instructions {
+	return code.Instructions(d.bytes())
+}
+
+func (d *decoder) positions() code.Positions {
+	filename := d.string()
+	n := d.count()
+	ps := make(code.Positions, 0, n)
+	offset := 0
+	for i := 0; i < n && d.err == nil; i++ {
+		offset += d.uint()
+		pos := token.Position{Filename: filename}
+		pos.Offset = d.uint()
+		pos.Line = d.uint()
+		pos.Column = d.uint()
+		ps = append(ps, code.Position{Offset: offset, Pos: pos})
+	}
+	return ps
+}
+
+func (d *decoder) constant() object.Object {
+	switch tag := d.byte(); tag {
+	case tagInteger:
+		return &object.Integer{Value: d.int()}
+	case tagFloat:
+		if len(d.data)-d.pos < 8 {
+			d.fail("unexpected end of data")
+			return nil
+		}
+		bits := binary.BigEndian.Uint64(d.data[d.pos:])
+		d.pos += 8
+		return &object.Float{Value: math.Float64frombits(bits)}
+	case tagBigInt:
+		negative := d.bool()
+		value := new(big.Int).SetBytes(d.bytes())
+		if negative {
+			value.Neg(value)
+		}
+		return &object.BigInt{Value: value}
+	case tagString:
+		return &object.String{Value: d.string()}
+	case tagFunction:
+		fn := &object.CompiledFunction{}
+		fn.Name = d.string()
+		fn.NumLocals = d.uint()
+		fn.NumParameters = d.uint()
+		fn.Rest = d.bool()
+		if n := d.count(); n > 0 {
+			fn.Entries = make([]int, 0, n)
+			for i := 0; i < n && d.err == nil; i++ {
+				fn.Entries = append(fn.Entries, d.uint())
+			}
+		}
+		fn.LocalNames = d.strings()
+		fn.FreeNames = d.strings()
+		fn.Instructions = d.instructions()
+		fn.Positions = d.positions()
+		return fn
+	default:
+		if d.err == nil {
+			d.fail("unknown constant tag %d at %d", tag, d.pos-1)
+		}
+		return nil
+	}
+}
+
+//validate 检查指令能否被虚拟机安全地执行
+func validate(bc *compiler.Bytecode) error {
+	main := scope{constants: bc.Constants, numGlobals: len(bc.Globals), main: true}
+	if err := validateInstructions(bc.Instructions, main); err != nil {
+		return fmt.Errorf("%w: main: %s", ErrCorrupt, err)
+	}
+	for i, c := range bc.Constants {
+		fn, ok := c.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+		if err := validateFunction(fn); err != nil {
+			return fmt.Errorf("%w: constant %d: %s", ErrCorrupt, i, err)
+		}
+		s := scope{
+			constants:  bc.Constants,
+			numGlobals: len(bc.Globals),
+			numLocals:  fn.NumLocals,
+			numFree:    len(fn.FreeNames),
+		}
+		if err := validateInstructions(fn.Instructions, s); err != nil {
+			return fmt.Errorf("%w: constant %d: %s", ErrCorrupt, i, err)
+		}
+	}
+	return nil
+}
+
+//scope 校验指令时可以引用的常量、变量和内置函数的数量
+type scope struct {
+	constants  []object.Object
+	numGlobals int
+	numLocals  int
+	numFree    int
+	main       bool //主程序可以跳转到指令末尾结束执行
+}
+
+func validateFunction(fn *object.CompiledFunction) error {
+	numParams := fn.NumParameters
+	if fn.Rest {
+		numParams++
+	}
+	if numParams > fn.NumLocals || len(fn.LocalNames) != fn.NumLocals {
+		return fmt.Errorf("invalid locals")
+	}
+	if len(fn.Entries) > fn.NumParameters+1 {
+		return fmt.Errorf("invalid entries")
+	}
+	for _, entry := range fn.Entries {
+		if entry > len(fn.Instructions) {
+			return fmt.Errorf("invalid entry %d", entry)
+		}
+	}
+	return nil
+}
+
+func validateInstructions(ins code.Instructions, s scope) error {
+	//第一遍检查指令的完整性并记录每条指令的起始位置，第二遍检查操作数
+	starts := make(map[int]bool)
+	for i := 0; i < len(ins); {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			return fmt.Errorf("%s at %d", err, i)
+		}
+		width := 0
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+		if i+1+width > len(ins) {
+			return fmt.Errorf("truncated %s at %d", def.Name, i)
+		}
+		starts[i] = true
+		i += 1 + width
+	}
+
+	checkJump := func(target, i int) error {
+		if starts[target] || s.main && target == len(ins) {
+			return nil
+		}
+		return fmt.Errorf("invalid jump target %d at %d", target, i)
+	}
+	checkIndex := func(kind string, index, limit, i int) error {
+		if index < limit {
+			return nil
+		}
+		return fmt.Errorf("%s %d out of range at %d", kind, index, i)
+	}
+
+	for i := 0; i < len(ins); {
+		def, _ := code.Lookup(ins[i])
+		operands, read := code.ReadOperands(def, ins[i+1:])
+		var err error
+		switch code.Opcode(ins[i]) {
+		case code.OpConstant:
+			err = checkIndex("constant", operands[0], len(s.constants), i)
+		case code.OpClosure:
+			if err = checkIndex("constant", operands[0], len(s.constants), i); err != nil {
+				break
+			}
+			fn, ok := s.constants[operands[0]].(*object.CompiledFunction)
+			if !ok {
+				err = fmt.Errorf("constant %d is not a function at %d", operands[0], i)
+			} else if operands[1] != len(fn.FreeNames) {
+				err = fmt.Errorf("wrong number of free variables %d at %d", operands[1], i)
+			}
+		case code.OpJump, code.OpJumpNotTruthy, code.OpIterNext, code.OpSetupTry:
+			err = checkJump(operands[0], i)
+		case code.OpGetGlobal, code.OpSetGlobal:
+			err = checkIndex("global", operands[0], s.numGlobals, i)
+		case code.OpGetLocal, code.OpSetLocal, code.OpCaptureLocal:
+			err = checkIndex("local", operands[0], s.numLocals, i)
+		case code.OpGetFree, code.OpCaptureFree:
+			err = checkIndex("free variable", operands[0], s.numFree, i)
+		case code.OpGetBuiltin:
+			err = checkIndex("builtin", operands[0], len(evaluator.BuiltinNames), i)
+		case code.OpGetBoundLocal:
+			if err = checkIndex("local", operands[0], s.numLocals, i); err == nil {
+				err = checkJump(operands[1], i)
+			}
+		case code.OpGetBoundFree:
+			if err = checkIndex("free variable", operands[0], s.numFree, i); err == nil {
+				err = checkJump(operands[1], i)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		i += 1 + read
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package bytecode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/vm"
+	"reflect"
+	"testing"
+)
+
+// bytecode/format_test.go
+
+const testProgram = `let big = 99999999999999999999;
+let add = fn(a, b = 2.5, ...rest) { a + b + len(rest) };
+let counter = fn() { let n = 0; fn() { n = n + 1; n } };
+let c = counter();
+c();
+[add(1), add(1, 2, 3), big, c(), "done"]`
+
+func compile(t *testing.T, input string) *compiler.Bytecode {
+	t.Helper()
+	program := parser.New(lexer.NewWithFilename("test.mk", input)).ParseProgram()
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	return comp.Bytecode()
+}
+
+func encode(t *testing.T, bc *compiler.Bytecode) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Encode(&buf, bc); err != nil {
+		t.Fatalf("encode error: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	bc := compile(t, testProgram)
+	decoded, err := Decode(bytes.NewReader(encode(t, bc)))
+	if err != nil {
+		t.Fatalf("decode error: %s", err)
+	}
+	if !reflect.DeepEqual(bc, decoded) {
+		t.Errorf("decoded bytecode differs.\nwant=%+v\ngot=%+v", bc, decoded)
+	}
+
+	machine := vm.New(decoded)
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	expected := "[3.5, 4, 99999999999999999999, 1, done]"
+	if got := machine.Result().Inspect(); got != expected {
+		t.Errorf("wrong result. want=%q, got=%q", expected, got)
+	}
+}
+
+func TestDecodedPositions(t *testing.T) {
+	bc := compile(t, "let f = fn(x) { x / 0 };\nf(1)")
+	decoded, err := Decode(bytes.NewReader(encode(t, bc)))
+	if err != nil {
+		t.Fatalf("decode error: %s", err)
+	}
+	machine := vm.New(decoded)
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	errObj, ok := machine.Result().(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", machine.Result(), machine.Result())
+	}
+	if errObj.Pos.String() != "test.mk:1:17" {
+		t.Errorf("wrong error position. got=%q", errObj.Pos.String())
+	}
+	if len(errObj.Stack) != 1 || errObj.Stack[0].Pos.String() != "test.mk:2:1" {
+		t.Errorf("wrong stack. got=%+v", errObj.Stack)
+	}
+}
+
+//withChecksum 修改payload后重新计算校验和，用于构造内容有误但校验和正确的文件
+func withChecksum(payload []byte) []byte {
+	data := append([]byte(Magic), 0, Version)
+	data = append(data, payload...)
+	checksum := make([]byte, 4)
+	binary.BigEndian.PutUint32(checksum, crc32.ChecksumIEEE(payload))
+	return append(data, checksum...)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	valid := encode(t, compile(t, testProgram))
+	payload := valid[len(Magic)+2 : len(valid)-4]
+
+	wrongVersion := append([]byte{}, valid...)
+	wrongVersion[len(Magic)+1] = Version + 1
+	flipped := append([]byte{}, valid...)
+	flipped[len(flipped)/2] ^= 0xff
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		{"empty", []byte{}, ErrNotBytecode},
+		{"source file", []byte("let a = 1;"), ErrNotBytecode},
+		{"wrong version", wrongVersion, ErrVersion},
+		{"no checksum", valid[:len(Magic)+3], ErrCorrupt},
+		{"checksum mismatch", flipped, ErrCorrupt},
+		{"truncated payload", withChecksum(payload[:len(payload)-3]), ErrCorrupt},
+		{"trailing data", withChecksum(append(append([]byte{}, payload...), 0)), ErrCorrupt},
+		{"unknown constant", withChecksum([]byte{0, 1, 99}), ErrCorrupt},
+		{"huge length", withChecksum([]byte{0xff, 0xff, 0xff, 0x07}), ErrCorrupt},
+		//OpConstant 5，常量池为空
+		{"constant out of range", withChecksum([]byte{0, 0, 3, 0, 0, 5, 0, 0}), ErrCorrupt},
+		{"undefined opcode", withChecksum([]byte{0, 0, 1, 255, 0, 0}), ErrCorrupt},
+	}
+	for _, tt := range tests {
+		_, err := Decode(bytes.NewReader(tt.data))
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: wrong error. want=%v, got=%v", tt.name, tt.expected, err)
+		}
+	}
+
+	//任意截断的文件都应当被拒绝
+	for i := 0; i < len(valid); i++ {
+		if _, err := Decode(bytes.NewReader(valid[:i])); err == nil {
+			t.Fatalf("truncated file of %d bytes accepted", i)
+		}
+	}
+}
+
+func TestEncodeUnsupportedConstant(t *testing.T) {
+	bc := &compiler.Bytecode{Constants: []object.Object{&object.Boolean{Value: true}}}
+	if err := Encode(&bytes.Buffer{}, bc); err == nil {
+		t.Errorf("expected error for unsupported constant")
+	}
+}
+
+func TestDecodeInvalidOperands(t *testing.T) {
+	//outer捕获局部变量x创建inner的闭包，各用例替换其中一段指令
+	inner := &object.CompiledFunction{
+		Instructions: concat(code.Make(code.OpGetFree, 0), code.Make(code.OpReturnValue)),
+		FreeNames:    []string{"x"},
+	}
+	outer := &object.CompiledFunction{
+		Instructions: concat(
+			code.Make(code.OpGetLocal, 0),
+			code.Make(code.OpPop),
+			code.Make(code.OpCaptureLocal, 0),
+			code.Make(code.OpClosure, 0, 1),
+			code.Make(code.OpReturnValue),
+		),
+		NumLocals:  1,
+		LocalNames: []string{"x"},
+	}
+
+	tests := []struct {
+		name  string
+		main  []byte
+		inner []byte
+		outer []byte
+	}{
+		{name: "valid"},
+		{name: "jump past end", main: concat(code.Make(code.OpJump, 4))},
+		{name: "jump into operand", main: concat(code.Make(code.OpJump, 1), code.Make(code.OpNull))},
+		{name: "try handler past end", main: concat(code.Make(code.OpSetupTry, 100))},
+		{name: "global out of range", main: concat(code.Make(code.OpGetGlobal, 1))},
+		{name: "local in main", main: concat(code.Make(code.OpGetLocal, 0))},
+		{name: "builtin out of range", main: concat(code.Make(code.OpGetBuiltin, 255))},
+		{name: "local out of range", outer: concat(code.Make(code.OpSetLocal, 1), code.Make(code.OpReturn))},
+		{name: "free out of range", inner: concat(code.Make(code.OpGetFree, 1), code.Make(code.OpReturnValue))},
+		{name: "bound local jump", outer: concat(code.Make(code.OpGetBoundLocal, 0, 9), code.Make(code.OpReturn))},
+		{name: "wrong free count", outer: concat(code.Make(code.OpClosure, 0, 2), code.Make(code.OpReturnValue))},
+		{name: "jump to end of function", inner: concat(code.Make(code.OpJump, 3))},
+	}
+	for _, tt := range tests {
+		in, out := *inner, *outer
+		if tt.inner != nil {
+			in.Instructions = tt.inner
+		}
+		if tt.outer != nil {
+			out.Instructions = tt.outer
+		}
+		bc := &compiler.Bytecode{
+			Instructions: concat(code.Make(code.OpGetGlobal, 0), code.Make(code.OpPop)),
+			Constants:    []object.Object{&in, &out},
+			Globals:      []string{"a"},
+		}
+		if tt.main != nil {
+			bc.Instructions = tt.main
+		}
+		_, err := Decode(bytes.NewReader(encode(t, bc)))
+		if tt.name == "valid" {
+			if err != nil {
+				t.Fatalf("valid program rejected: %s", err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%s: wrong error. want=%v, got=%v", tt.name, ErrCorrupt, err)
+		}
+	}
+}
+
+func concat(ins ...[]byte) code.Instructions {
+	out := code.Instructions{}
+	for _, i := range ins {
+		out = append(out, i...)
+	}
+	return out
+}
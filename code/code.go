@@ -0,0 +1,250 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"monkey/token"
+	"sort"
+)
+
+//Instructions 字节码指令序列，每条指令由一个字节的操作码和若干操作数组成
+type Instructions []byte
+
+//String 反汇编，每行一条指令，格式为 <偏移量> <操作码> <操作数>
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+		i += 1 + read
+	}
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
+	}
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
+//Opcode 操作码
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota //将常量池中的常量压栈
+
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpLessThan
+
+	OpMinus
+	OpBang
+
+	OpPop
+
+	OpJump          //无条件跳转
+	OpJumpNotTruthy //弹出栈顶，为假时跳转
+
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	OpGetBuiltin
+	OpGetFree
+	//变量已绑定时压栈并跳转；尚未绑定时继续执行后面的指令，从外层作用域读取同名变量
+	OpGetBoundLocal
+	OpGetBoundFree
+
+	OpCaptureLocal //捕获当前函数的局部变量，供OpClosure使用
+	OpCaptureFree  //传递当前闭包捕获的变量，供OpClosure使用
+	OpClosure      //以常量池中的函数和栈上捕获的变量创建闭包
+
+	OpArray
+	OpHash
+	OpIndex
+
+	OpCall
+	OpReturnValue
+	OpReturn //返回null
+
+	OpIter     //将栈顶的数组、字符串或哈希替换为迭代器
+	OpIterNext //迭代器有下一个元素时压栈，否则弹出迭代器并跳转
+
+	OpSetupTry //注册异常处理的入口
+	OpPopTry   //注销最近注册的异常处理
+	OpThrow    //弹出栈顶的值作为错误抛出
+)
+
+//Definition 操作码的名称及各个操作数的字节数
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+
+	OpAdd: {"OpAdd", []int{}},
+	OpSub: {"OpSub", []int{}},
+	OpMul: {"OpMul", []int{}},
+	OpDiv: {"OpDiv", []int{}},
+	OpMod: {"OpMod", []int{}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpNull:  {"OpNull", []int{}},
+
+	OpEqual:       {"OpEqual", []int{}},
+	OpNotEqual:    {"OpNotEqual", []int{}},
+	OpGreaterThan: {"OpGreaterThan", []int{}},
+	OpLessThan:    {"OpLessThan", []int{}},
+
+	OpMinus: {"OpMinus", []int{}},
+	OpBang:  {"OpBang", []int{}},
+
+	OpPop: {"OpPop", []int{}},
+
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+
+	OpGetGlobal:  {"OpGetGlobal", []int{2}},
+	OpSetGlobal:  {"OpSetGlobal", []int{2}},
+	OpGetLocal:   {"OpGetLocal", []int{1}},
+	OpSetLocal:   {"OpSetLocal", []int{1}},
+	OpGetBuiltin: {"OpGetBuiltin", []int{1}},
+	OpGetFree:    {"OpGetFree", []int{1}},
+
+	OpGetBoundLocal: {"OpGetBoundLocal", []int{1, 2}},
+	OpGetBoundFree:  {"OpGetBoundFree", []int{1, 2}},
+
+	OpCaptureLocal: {"OpCaptureLocal", []int{1}},
+	OpCaptureFree:  {"OpCaptureFree", []int{1}},
+	OpClosure:      {"OpClosure", []int{2, 1}},
+
+	OpArray: {"OpArray", []int{2}},
+	OpHash:  {"OpHash", []int{2}},
+	OpIndex: {"OpIndex", []int{}},
+
+	OpCall:        {"OpCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+
+	OpIter:     {"OpIter", []int{}},
+	OpIterNext: {"OpIterNext", []int{2}},
+
+	OpSetupTry: {"OpSetupTry", []int{2}},
+	OpPopTry:   {"OpPopTry", []int{}},
+	OpThrow:    {"OpThrow", []int{}},
+}
+
+//Lookup 查找操作码的定义
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+//Fits 操作数能否用指定的字节数编码
+func Fits(operand, width int) bool {
+	return operand >= 0 && operand < 1<<(8*width)
+}
+
+//Make 编码一条指令，操作码未定义时返回空
+//超出宽度的操作数会被截断，调用方需要先用Fits检查
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+	return instruction
+}
+
+//ReadOperands 解码指令的操作数，返回操作数以及读取的字节数
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
+
+//Position 从Offset开始的指令对应的源码位置
+type Position struct {
+	Offset int
+	Pos    token.Position
+}
+
+//Positions 按偏移量升序排列的源码位置表，用于在运行时错误中给出出错位置
+type Positions []Position
+
+//Lookup 查找偏移量为offset的指令对应的源码位置
+func (p Positions) Lookup(offset int) token.Position {
+	i := sort.Search(len(p), func(i int) bool { return p[i].Offset > offset })
+	if i == 0 {
+		return token.Position{}
+	}
+	return p[i-1].Pos
+}
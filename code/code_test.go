@@ -0,0 +1,128 @@
+package code
+
+import (
+	"monkey/token"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+		{OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
+		{OpClosure, []int{65534, 255}, []byte{byte(OpClosure), 255, 254, 255}},
+	}
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+		if len(instruction) != len(tt.expected) {
+			t.Errorf("instruction has wrong length. want=%d, got=%d", len(tt.expected), len(instruction))
+			continue
+		}
+		for i, b := range tt.expected {
+			if instruction[i] != b {
+				t.Errorf("wrong byte at pos %d. want=%d, got=%d", i, b, instruction[i])
+			}
+		}
+	}
+}
+
+func TestFits(t *testing.T) {
+	tests := []struct {
+		operand, width int
+		expected       bool
+	}{
+		{255, 1, true},
+		{256, 1, false},
+		{65535, 2, true},
+		{65536, 2, false},
+		{-1, 2, false},
+	}
+	for _, tt := range tests {
+		if got := Fits(tt.operand, tt.width); got != tt.expected {
+			t.Errorf("Fits(%d, %d) wrong. want=%t, got=%t", tt.operand, tt.width, tt.expected, got)
+		}
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []Instructions{
+		Make(OpAdd),
+		Make(OpGetLocal, 1),
+		Make(OpConstant, 2),
+		Make(OpConstant, 65535),
+		Make(OpClosure, 65535, 255),
+		Make(OpIterNext, 3),
+	}
+	expected := `0000 OpAdd
+0001 OpGetLocal 1
+0003 OpConstant 2
+0006 OpConstant 65535
+0009 OpClosure 65535 255
+0013 OpIterNext 3
+`
+	concatted := Instructions{}
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+	if concatted.String() != expected {
+		t.Errorf("instructions wrongly formatted.\nwant=%q\ngot=%q", expected, concatted.String())
+	}
+}
+
+func TestReadOperands(t *testing.T) {
+	tests := []struct {
+		op        Opcode
+		operands  []int
+		bytesRead int
+	}{
+		{OpConstant, []int{65535}, 2},
+		{OpGetLocal, []int{255}, 1},
+		{OpClosure, []int{65535, 255}, 3},
+	}
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+		def, err := Lookup(byte(tt.op))
+		if err != nil {
+			t.Fatalf("definition not found: %q", err)
+		}
+		operandsRead, n := ReadOperands(def, instruction[1:])
+		if n != tt.bytesRead {
+			t.Fatalf("n wrong. want=%d, got=%d", tt.bytesRead, n)
+		}
+		for i, want := range tt.operands {
+			if operandsRead[i] != want {
+				t.Errorf("operand wrong. want=%d, got=%d", want, operandsRead[i])
+			}
+		}
+	}
+}
+
+func TestPositionsLookup(t *testing.T) {
+	positions := Positions{
+		{Offset: 0, Pos: token.Position{Line: 1, Column: 1}},
+		{Offset: 4, Pos: token.Position{Line: 2, Column: 3}},
+		{Offset: 9, Pos: token.Position{Line: 3, Column: 5}},
+	}
+	tests := []struct {
+		offset   int
+		expected string
+	}{
+		{0, "1:1"},
+		{3, "1:1"},
+		{4, "2:3"},
+		{8, "2:3"},
+		{20, "3:5"},
+	}
+	for _, tt := range tests {
+		if got := positions.Lookup(tt.offset).String(); got != tt.expected {
+			t.Errorf("wrong position for offset %d. want=%q, got=%q", tt.offset, tt.expected, got)
+		}
+	}
+	if (Positions{}).Lookup(0).IsValid() {
+		t.Errorf("empty positions should not return a valid position")
+	}
+}
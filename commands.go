@@ -0,0 +1,258 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"monkey/bytecode"
+	"monkey/compiler"
+	"monkey/dap"
+	"monkey/dump"
+	"monkey/explainer"
+	"monkey/format"
+	"monkey/lexer"
+	"monkey/lint"
+	"monkey/lsp"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//commands 子命令，返回值为退出码
+var commands = map[string]func(args []string) int{
+	"build":  build,
+	"fmt":    formatFiles,
+	"lint":   lintFiles,
+	"lsp":    languageServer,
+	"dap":    debugAdapter,
+	"tokens": dumpTokens,
+	"ast":    dumpAST,
+	"disasm": disassemble,
+}
+
+//build 将源文件编译为.mkc文件
+func build(args []string) int {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	output := flags.String("o", "", "输出文件，默认为源文件名加.mkc扩展名")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Println("usage: monkey build [-o file.mkc] file.mk")
+		return 2
+	}
+	source := flags.Arg(0)
+	if *output == "" {
+		*output = strings.TrimSuffix(source, filepath.Ext(source)) + bytecode.Extension
+	}
+	file, err := os.Open(source)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer file.Close()
+	bc := explainer.CompileFile(source, file, os.Stdout)
+	if bc == nil {
+		return 1
+	}
+	out, err := os.Create(*output)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if err := bytecode.Encode(out, bc); err != nil {
+		out.Close()
+		os.Remove(*output)
+		fmt.Println(err)
+		return 1
+	}
+	if err := out.Close(); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
+
+//formatFiles 格式化源文件，默认输出到标准输出
+//-l 只列出格式不一致的文件，-w 将结果写回文件
+func formatFiles(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	list := flags.Bool("l", false, "列出格式与标准格式不一致的文件")
+	write := flags.Bool("w", false, "将格式化的结果写回源文件")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		fmt.Println("usage: monkey fmt [-w] [-l] file.mk...")
+		return 2
+	}
+	code := 0
+	for _, filename := range flags.Args() {
+		source, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Println(err)
+			code = 1
+			continue
+		}
+		formatted, err := format.Source(filename, source)
+		if err != nil {
+			fmt.Println(err)
+			code = 1
+			continue
+		}
+		changed := string(formatted) != string(source)
+		if *list && changed {
+			fmt.Println(filename)
+		}
+		if *write && changed {
+			info, err := os.Stat(filename)
+			if err == nil {
+				err = os.WriteFile(filename, formatted, info.Mode().Perm())
+			}
+			if err != nil {
+				fmt.Println(err)
+				code = 1
+			}
+		}
+		if !*list && !*write {
+			os.Stdout.Write(formatted)
+		}
+	}
+	return code
+}
+
+//lintFiles 静态检查源文件，有结果时退出码为1
+//-disable 关闭指定的规则，-builtins 指定宿主程序注册的内置函数，-rules 列出所有规则
+func lintFiles(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	disable := flags.String("disable", "", "关闭的规则，用逗号分隔，如 L002,L003")
+	builtins := flags.String("builtins", "", "宿主程序注册的内置函数，用逗号分隔，不报告为未定义")
+	rules := flags.Bool("rules", false, "列出所有规则")
+	flags.Parse(args)
+	if *rules {
+		for _, rule := range lint.Rules {
+			fmt.Printf("%s %-22s %s\n", rule.ID, rule.Name, rule.Description)
+		}
+		return 0
+	}
+	if flags.NArg() == 0 {
+		fmt.Println("usage: monkey lint [-disable=L002,...] [-builtins=name,...] [-rules] file.mk...")
+		return 2
+	}
+	disabled := map[string]bool{}
+	for _, id := range strings.Split(*disable, ",") {
+		disabled[strings.TrimSpace(id)] = true
+	}
+	opts := lint.Options{}
+	for _, name := range strings.Split(*builtins, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			opts.Builtins = append(opts.Builtins, name)
+		}
+	}
+	code := 0
+	for _, filename := range flags.Args() {
+		source, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Println(err)
+			code = 1
+			continue
+		}
+		for _, d := range lint.SourceOptions(filename, source, opts) {
+			if disabled[d.Code] {
+				continue
+			}
+			fmt.Print(d.Render(string(source)))
+			code = 1
+		}
+	}
+	return code
+}
+
+//languageServer 启动语言服务器，通过标准输入输出通信，错误输出到标准错误
+func languageServer(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "usage: monkey lsp")
+		return 2
+	}
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+//debugAdapter 启动调试适配器，通过标准输入输出通信，程序的输出转换为output事件
+func debugAdapter(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "usage: monkey dap")
+		return 2
+	}
+	if err := dap.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+//dumpTokens 输出词法分析得到的token流
+func dumpTokens(args []string) int {
+	return withDumpFile("tokens", args, func(filename string, in io.Reader, asJSON bool) error {
+		source, err := io.ReadAll(in)
+		if err != nil {
+			return err
+		}
+		tokens := dump.Tokens(lexer.NewWithFilename(filename, string(source)))
+		return dump.WriteTokens(os.Stdout, tokens, asJSON)
+	})
+}
+
+//dumpAST 输出语法树
+func dumpAST(args []string) int {
+	return withDumpFile("ast", args, func(filename string, in io.Reader, asJSON bool) error {
+		program := explainer.ParseFile(filename, in, os.Stdout)
+		if program == nil {
+			return errFailed
+		}
+		return dump.WriteTree(os.Stdout, program, asJSON)
+	})
+}
+
+//disassemble 输出源文件或.mkc文件的反汇编清单
+func disassemble(args []string) int {
+	return withDumpFile("disasm", args, func(filename string, in io.Reader, asJSON bool) error {
+		var bc *compiler.Bytecode
+		if filepath.Ext(filename) == bytecode.Extension {
+			var err error
+			if bc, err = bytecode.Decode(in); err != nil {
+				return err
+			}
+		} else if bc = explainer.CompileFile(filename, in, os.Stdout); bc == nil {
+			return errFailed
+		}
+		return dump.WriteDisassembly(os.Stdout, bc, asJSON)
+	})
+}
+
+//errFailed 错误信息已经输出，只需要返回失败的退出码
+var errFailed = fmt.Errorf("failed")
+
+//withDumpFile 解析 [-json] file 参数，打开文件后交给dump执行
+func withDumpFile(name string, args []string, dump func(filename string, in io.Reader, asJSON bool) error) int {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "以JSON格式输出")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Printf("usage: monkey %s [-json] file\n", name)
+		return 2
+	}
+	filename := flags.Arg(0)
+	file, err := os.Open(filename)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer file.Close()
+	if err := dump(filename, file, *asJSON); err != nil {
+		if err != errFailed {
+			fmt.Println(err)
+		}
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,29 @@
+package compiler
+
+import "monkey/ast"
+
+//boundNames 函数体中通过let、赋值和for-in绑定的变量名，按出现顺序排列，不包含内层函数和catch的参数
+func boundNames(node ast.Node) []string {
+	names := []string{}
+	seen := map[string]bool{}
+	bind := func(ident *ast.Identifier) {
+		if ident != nil && !seen[ident.Value] {
+			seen[ident.Value] = true
+			names = append(names, ident.Value)
+		}
+	}
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral:
+			return n == node
+		case *ast.LetStatement:
+			bind(n.Name)
+		case *ast.AssignStatement:
+			bind(n.Name)
+		case *ast.ForInStatement:
+			bind(n.Variable)
+		}
+		return true
+	})
+	return names
+}
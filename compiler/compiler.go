@@ -0,0 +1,805 @@
+package compiler
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/code"
+	"monkey/evaluator"
+	"monkey/object"
+	"monkey/token"
+)
+
+//Compiler 将语法树编译为字节码
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+	scopes      []CompilationScope
+	scopeIndex  int
+	pos         token.Position //正在编译的节点的位置
+	err         error          //生成指令时发现的错误，如操作数超出编码宽度
+}
+
+//EmittedInstruction 已生成的指令
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+//CompilationScope 一个函数的编译状态
+type CompilationScope struct {
+	instructions        code.Instructions
+	positions           code.Positions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	blocks              []*block
+}
+
+//block 正在编译的循环或try语句，break、continue和return跳出时需要做清理
+type block struct {
+	loop      bool
+	label     string
+	iterator  bool  //for-in循环在栈上保存着迭代器
+	breaks    []int //待回填的break跳转
+	continues []int //待回填的continue跳转
+
+	handler bool                //try：注册了异常处理
+	finally *ast.BlockStatement //try：跳出时需要执行的finally
+}
+
+//Bytecode 编译的结果
+type Bytecode struct {
+	Instructions code.Instructions
+	Positions    code.Positions
+	Constants    []object.Object
+	Globals      []string //全局变量名，按下标排列
+}
+
+func New() *Compiler {
+	mainScope := CompilationScope{}
+	symbolTable := NewSymbolTable()
+	for i, name := range evaluator.BuiltinNames {
+		symbolTable.DefineBuiltin(i, name)
+	}
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: symbolTable,
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+	}
+}
+
+//NewWithState 沿用之前的符号表和常量池，用于REPL中逐行编译
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
+//SymbolTable 全局符号表
+func (c *Compiler) SymbolTable() *SymbolTable {
+	return c.symbolTable
+}
+
+func (c *Compiler) Compile(node ast.Node) (err error) {
+	pos := c.pos
+	if node.Pos().IsValid() {
+		c.pos = node.Pos()
+	}
+	defer func() {
+		c.pos = pos
+		if err == nil {
+			err = c.err
+		}
+	}()
+
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+	case *ast.InfixExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case "+":
+			c.emit(code.OpAdd)
+		case "-":
+			c.emit(code.OpSub)
+		case "*":
+			c.emit(code.OpMul)
+		case "/":
+			c.emit(code.OpDiv)
+		case "%":
+			c.emit(code.OpMod)
+		case ">":
+			c.emit(code.OpGreaterThan)
+		case "<":
+			c.emit(code.OpLessThan)
+		case "==":
+			c.emit(code.OpEqual)
+		case "!=":
+			c.emit(code.OpNotEqual)
+		default:
+			return fmt.Errorf("%s: unknown operator %s", node.Pos(), node.Operator)
+		}
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("%s: unknown operator %s", node.Pos(), node.Operator)
+		}
+	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			c.emit(code.OpConstant, c.addConstant(&object.BigInt{Value: node.Big}))
+		} else {
+			c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+		}
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+	case *ast.LetStatement:
+		//函数可以通过自己的名字递归调用，先定义再编译
+		var symbol Symbol
+		_, isFunction := node.Value.(*ast.FunctionLiteral)
+		if isFunction {
+			symbol = c.symbolTable.Define(node.Name.Value)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if !isFunction {
+			symbol = c.symbolTable.Define(node.Name.Value)
+		}
+		c.storeSymbol(symbol)
+	case *ast.AssignStatement:
+		if node.Name == nil {
+			return fmt.Errorf("%s: invalid assignment target", node.Pos())
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		//与let一样在当前作用域中赋值
+		symbol := c.symbolTable.Define(node.Name.Value)
+		c.storeSymbol(symbol)
+		c.loadSymbol(symbol)
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			//尚未定义的变量视为全局变量，运行到这里时仍未定义才报错
+			symbol = c.symbolTable.Global().Define(node.Value)
+		}
+		c.loadVariable(symbol)
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		for _, k := range ast.SortedKeys(node.Pairs) {
+			if err := c.Compile(k); err != nil {
+				return err
+			}
+			if err := c.Compile(node.Pairs[k]); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node)
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		if err := c.exitBlocks(0, true); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+	case *ast.CallExpression:
+		//quote只能在求值器或宏展开中使用
+		if ident, ok := node.Function.(*ast.Identifier); ok && ident.Value == "quote" {
+			return fmt.Errorf("%s: quote is not supported by the vm engine", node.Pos())
+		}
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+	case *ast.ForInStatement:
+		return c.compileForInStatement(node)
+	case *ast.BreakStatement:
+		return c.compileLoopControl(node.Label, true)
+	case *ast.ContinueStatement:
+		return c.compileLoopControl(node.Label, false)
+	case *ast.ThrowStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+	case *ast.TryExpression:
+		return c.compileTryExpression(node)
+	default:
+		return fmt.Errorf("%s: cannot compile %T", node.Pos(), node)
+	}
+	return nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+	if err := c.compileBlockValue(node.Consequence); err != nil {
+		return err
+	}
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileBlockValue(node.Alternative); err != nil {
+		return err
+	}
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+//compileBlockValue 编译作为表达式使用的语句块，最后一条表达式语句的值留在栈上，否则留下null
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+	if endsWithExpression(block) && c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+	return nil
+}
+
+func endsWithExpression(block *ast.BlockStatement) bool {
+	if len(block.Statements) == 0 {
+		return false
+	}
+	_, ok := block.Statements[len(block.Statements)-1].(*ast.ExpressionStatement)
+	return ok
+}
+
+//compileFunctionLiteral 编译函数
+//缺少的默认参数从前往后依次求值，调用时根据实参个数跳过已经传入的参数
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
+	c.enterScope()
+	for _, p := range node.Parameters {
+		c.symbolTable.Define(p.Value)
+	}
+	if node.Rest != nil {
+		c.symbolTable.Define(node.Rest.Value)
+	}
+	//与求值器一致，函数中的赋值执行之前，读取的是外层的同名变量
+	for _, name := range boundNames(node.Body) {
+		if _, ok := c.symbolTable.store[name]; !ok && c.symbolTable.Outer.defined(name) {
+			c.symbolTable.DefineLate(name)
+		}
+	}
+	var entries []int
+	for _, p := range node.Parameters {
+		def, ok := node.Defaults[p.Value]
+		if !ok {
+			continue
+		}
+		entries = append(entries, len(c.currentInstructions()))
+		if err := c.Compile(def); err != nil {
+			return err
+		}
+		symbol, _ := c.symbolTable.Resolve(p.Value)
+		c.storeSymbol(symbol)
+	}
+	if entries != nil {
+		entries = append(entries, len(c.currentInstructions()))
+	}
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	if endsWithExpression(node.Body) && c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.NumDefinitions()
+	localNames := c.symbolTable.Names()
+	positions := c.scopes[c.scopeIndex].positions
+	instructions := c.leaveScope()
+
+	freeNames := []string{}
+	for _, s := range freeSymbols {
+		freeNames = append(freeNames, s.Name)
+		if s.Scope == FreeScope {
+			c.emit(code.OpCaptureFree, s.Index)
+		} else {
+			c.emit(code.OpCaptureLocal, s.Index)
+		}
+	}
+	compiledFn := &object.CompiledFunction{
+		Instructions:  instructions,
+		Positions:     positions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		Rest:          node.Rest != nil,
+		Entries:       entries,
+		Name:          node.Name,
+		LocalNames:    localNames,
+		FreeNames:     freeNames,
+	}
+	c.emit(code.OpClosure, c.addConstant(compiledFn), len(freeSymbols))
+	return nil
+}
+
+//compileForStatement 编译 for (init; condition; post) { }
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	if node.Init != nil {
+		if err := c.Compile(node.Init); err != nil {
+			return err
+		}
+	}
+	loopStart := len(c.currentInstructions())
+	exitPos := -1
+	if node.Condition != nil {
+		if err := c.Compile(node.Condition); err != nil {
+			return err
+		}
+		exitPos = c.emit(code.OpJumpNotTruthy, 9999)
+	}
+	loop := &block{loop: true, label: labelName(node.Label)}
+	if err := c.compileLoopBody(loop, node.Body); err != nil {
+		return err
+	}
+	continueTarget := len(c.currentInstructions())
+	if node.Post != nil {
+		if err := c.Compile(node.Post); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	}
+	c.emit(code.OpJump, loopStart)
+	end := len(c.currentInstructions())
+	if exitPos != -1 {
+		c.changeOperand(exitPos, end)
+	}
+	c.patchJumps(loop.breaks, end)
+	c.patchJumps(loop.continues, continueTarget)
+	c.emitLoopValue()
+	return nil
+}
+
+//compileForInStatement 编译 for (x in iterable) { }，循环期间迭代器保存在栈上
+func (c *Compiler) compileForInStatement(node *ast.ForInStatement) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emit(code.OpIter)
+	loopStart := len(c.currentInstructions())
+	exitPos := c.emit(code.OpIterNext, 9999)
+	c.storeSymbol(c.symbolTable.Define(node.Variable.Value))
+	loop := &block{loop: true, label: labelName(node.Label), iterator: true}
+	if err := c.compileLoopBody(loop, node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, loopStart)
+	end := len(c.currentInstructions())
+	c.changeOperand(exitPos, end)
+	c.patchJumps(loop.breaks, end)
+	c.patchJumps(loop.continues, loopStart)
+	c.emitLoopValue()
+	return nil
+}
+
+//emitLoopValue 与求值器一致，循环语句的值为null
+func (c *Compiler) emitLoopValue() {
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+}
+
+func (c *Compiler) compileLoopBody(loop *block, body *ast.BlockStatement) error {
+	c.pushBlock(loop)
+	defer c.popBlock()
+	return c.Compile(body)
+}
+
+func labelName(label *ast.Identifier) string {
+	if label == nil {
+		return ""
+	}
+	return label.Value
+}
+
+//compileLoopControl 编译break和continue，跳出的try语句需要先执行finally
+func (c *Compiler) compileLoopControl(label *ast.Identifier, isBreak bool) error {
+	name := labelName(label)
+	blocks := c.scopes[c.scopeIndex].blocks
+	for i := len(blocks) - 1; i >= 0; i-- {
+		b := blocks[i]
+		if !b.loop || (name != "" && b.label != name) {
+			continue
+		}
+		if err := c.exitBlocks(i+1, false); err != nil {
+			return err
+		}
+		if isBreak {
+			if b.iterator {
+				c.emit(code.OpPop)
+			}
+			b.breaks = append(b.breaks, c.emit(code.OpJump, 9999))
+		} else {
+			b.continues = append(b.continues, c.emit(code.OpJump, 9999))
+		}
+		return nil
+	}
+	if isBreak {
+		return fmt.Errorf("%s: break outside loop", c.pos)
+	}
+	return fmt.Errorf("%s: continue outside loop", c.pos)
+}
+
+//exitBlocks 从内向外离开depth及以内的语句块
+//return时函数的栈和异常处理会一并丢弃，只需要执行finally
+func (c *Compiler) exitBlocks(depth int, isReturn bool) error {
+	blocks := c.scopes[c.scopeIndex].blocks
+	for i := len(blocks) - 1; i >= depth; i-- {
+		b := blocks[i]
+		if b.loop {
+			if b.iterator && !isReturn {
+				c.emit(code.OpPop)
+			}
+			continue
+		}
+		if b.handler && !isReturn {
+			c.emit(code.OpPopTry)
+		}
+		if b.finally != nil {
+			//finally中的跳转只能看到外层的语句块
+			c.scopes[c.scopeIndex].blocks = append([]*block{}, blocks[:i]...)
+			err := c.Compile(b.finally)
+			c.scopes[c.scopeIndex].blocks = blocks
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//compileTryExpression 编译try语句
+//
+//	OpSetupTry catch
+//	<try语句块>
+//	OpPopTry
+//	<finally>
+//	OpJump end
+//	catch:        (错误被压栈)
+//	OpSetupTry rethrow
+//	<绑定错误> <catch语句块>
+//	OpPopTry
+//	<finally>
+//	OpJump end
+//	rethrow:      (错误被压栈)
+//	<finally>
+//	OpThrow
+//	end:
+func (c *Compiler) compileTryExpression(node *ast.TryExpression) error {
+	handlerPos := c.emit(code.OpSetupTry, 9999)
+	if err := c.compileProtected(node.Block, node.Finally, true); err != nil {
+		return err
+	}
+	var endJumps []int
+	if err := c.compileFinally(node.Finally); err != nil {
+		return err
+	}
+	endJumps = append(endJumps, c.emit(code.OpJump, 9999))
+
+	rethrowPos := -1
+	if node.Catch != nil {
+		c.changeOperand(handlerPos, len(c.currentInstructions()))
+		if node.Finally != nil {
+			rethrowPos = c.emit(code.OpSetupTry, 9999)
+		}
+		//与求值器一致，catch的参数只在catch语句块中可见
+		c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+		if node.CatchParam != nil {
+			c.storeSymbol(c.symbolTable.DefineBlock(node.CatchParam.Value))
+		} else {
+			c.emit(code.OpPop)
+		}
+		err := c.compileProtected(node.Catch, node.Finally, node.Finally != nil)
+		c.symbolTable = c.symbolTable.Outer
+		if err != nil {
+			return err
+		}
+		if err := c.compileFinally(node.Finally); err != nil {
+			return err
+		}
+		endJumps = append(endJumps, c.emit(code.OpJump, 9999))
+	} else {
+		rethrowPos = handlerPos
+	}
+
+	if node.Finally != nil {
+		c.changeOperand(rethrowPos, len(c.currentInstructions()))
+		if err := c.Compile(node.Finally); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+	}
+	c.patchJumps(endJumps, len(c.currentInstructions()))
+	return nil
+}
+
+//compileProtected 编译受异常处理保护的语句块，正常结束时注销异常处理
+func (c *Compiler) compileProtected(body *ast.BlockStatement, finally *ast.BlockStatement, handler bool) error {
+	c.pushBlock(&block{handler: handler, finally: finally})
+	err := c.compileBlockValue(body)
+	c.popBlock()
+	if err != nil {
+		return err
+	}
+	if handler {
+		c.emit(code.OpPopTry)
+	}
+	return nil
+}
+
+//compileFinally 正常执行完try或catch后执行finally，finally的值被丢弃
+func (c *Compiler) compileFinally(finally *ast.BlockStatement) error {
+	if finally == nil {
+		return nil
+	}
+	return c.Compile(finally)
+}
+
+func (c *Compiler) pushBlock(b *block) {
+	c.scopes[c.scopeIndex].blocks = append(c.scopes[c.scopeIndex].blocks, b)
+}
+
+func (c *Compiler) popBlock() {
+	blocks := c.scopes[c.scopeIndex].blocks
+	c.scopes[c.scopeIndex].blocks = blocks[:len(blocks)-1]
+}
+
+func (c *Compiler) patchJumps(positions []int, target int) {
+	for _, pos := range positions {
+		c.changeOperand(pos, target)
+	}
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	}
+}
+
+//loadVariable 读取变量，变量可能尚未绑定时先检查，未绑定则读取外层的同名变量
+func (c *Compiler) loadVariable(s Symbol) {
+	if !c.symbolTable.IsLate(s) {
+		c.loadSymbol(s)
+		return
+	}
+	op := code.OpGetBoundLocal
+	if s.Scope == FreeScope {
+		op = code.OpGetBoundFree
+	}
+	pos := c.emit(op, s.Index, 9999)
+	fallback, ok := c.symbolTable.Fallback(s)
+	if !ok {
+		fallback = c.symbolTable.Global().Define(s.Name)
+	}
+	c.loadVariable(fallback)
+	c.replaceInstruction(pos, c.instruction(op, s.Index, len(c.currentInstructions())))
+}
+
+func (c *Compiler) storeSymbol(s Symbol) {
+	if s.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, s.Index)
+	} else {
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
+//Bytecode 返回主程序的字节码
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Positions:    c.scopes[c.scopeIndex].positions,
+		Constants:    c.constants,
+		Globals:      c.symbolTable.Global().Names(),
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+//emit 生成一条指令，返回指令的起始位置
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := c.instruction(op, operands...)
+	pos := c.addInstruction(ins)
+	c.setLastInstruction(op, pos)
+	return pos
+}
+
+//instruction 编码一条指令，操作数超出编码宽度时记录编译错误
+func (c *Compiler) instruction(op code.Opcode, operands ...int) []byte {
+	def, err := code.Lookup(byte(op))
+	if err != nil {
+		return []byte{}
+	}
+	for i, o := range operands {
+		if !code.Fits(o, def.OperandWidths[i]) && c.err == nil {
+			c.err = fmt.Errorf("%s: %s", c.pos, operandLimit(op, i))
+		}
+	}
+	return code.Make(op, operands...)
+}
+
+//operandLimit 操作数超出编码宽度时的错误信息
+func operandLimit(op code.Opcode, operand int) string {
+	switch op {
+	case code.OpConstant:
+		return "too many constants (limit 65536)"
+	case code.OpClosure:
+		if operand == 0 {
+			return "too many constants (limit 65536)"
+		}
+		return "too many free variables in function (limit 255)"
+	case code.OpGetGlobal, code.OpSetGlobal:
+		return "too many global variables (limit 65536)"
+	case code.OpGetLocal, code.OpSetLocal, code.OpCaptureLocal:
+		return "too many local variables in function (limit 256)"
+	case code.OpGetFree, code.OpCaptureFree:
+		return "too many free variables in function (limit 255)"
+	case code.OpGetBoundLocal:
+		if operand == 0 {
+			return "too many local variables in function (limit 256)"
+		}
+	case code.OpGetBoundFree:
+		if operand == 0 {
+			return "too many free variables in function (limit 255)"
+		}
+	case code.OpGetBuiltin:
+		return "too many builtins (limit 256)"
+	case code.OpArray:
+		return "too many elements in array literal (limit 65535)"
+	case code.OpHash:
+		return "too many pairs in hash literal (limit 32767)"
+	case code.OpCall:
+		return "too many arguments in call (limit 255)"
+	}
+	return "code too large: jump target beyond 65535 bytes"
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	scope := &c.scopes[c.scopeIndex]
+	posNewInstruction := len(scope.instructions)
+	if n := len(scope.positions); c.pos.IsValid() && (n == 0 || scope.positions[n-1].Pos != c.pos) {
+		scope.positions = append(scope.positions, code.Position{Offset: posNewInstruction, Pos: c.pos})
+	}
+	scope.instructions = append(scope.instructions, ins...)
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	scope := &c.scopes[c.scopeIndex]
+	last := scope.lastInstruction
+	scope.instructions = scope.instructions[:last.Position]
+	for len(scope.positions) > 0 && scope.positions[len(scope.positions)-1].Offset >= last.Position {
+		scope.positions = scope.positions[:len(scope.positions)-1]
+	}
+	scope.lastInstruction = scope.previousInstruction
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := c.instruction(op, operand)
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{})
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+	return instructions
+}
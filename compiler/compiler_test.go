@@ -0,0 +1,813 @@
+package compiler
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/code"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+type compilerTestCase struct {
+	input                string
+	expectedConstants    []interface{}
+	expectedInstructions []code.Instructions
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 + 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1; 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 % 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpMod),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "-1.5",
+			expectedConstants: []interface{}{1.5},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpMinus),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "99999999999999999999",
+			expectedConstants: []interface{}{"99999999999999999999"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 < 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLessThan),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "!true != false",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpBang),
+				code.Make(code.OpFalse),
+				code.Make(code.OpNotEqual),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "if (true) { 10 }; 3333;",
+			expectedConstants: []interface{}{10, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpJump, 11),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpPop),
+				// 0012
+				code.Make(code.OpConstant, 1),
+				// 0015
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "if (true) { let a = 10; } else { 20 }",
+			expectedConstants: []interface{}{10, 20},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 14),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpSetGlobal, 0),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpJump, 17),
+				// 0014
+				code.Make(code.OpConstant, 1),
+				// 0017
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let one = 1; let two = one; two;",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let one = 1; let one = 2;",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+		{
+			input:             "later; let later = 1;",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestAssignStatements(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let a = 1; a = 2;",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "let a = 1; fn() { a = 2; }",
+			expectedConstants: []interface{}{
+				1,
+				2,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestStringArrayHashAndIndex(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `"mon" + "key"`,
+			expectedConstants: []interface{}{"mon", "key"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "[1, 2][0]",
+			expectedConstants: []interface{}{1, 2, 0},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "{2: 3, 1: 2 + 3}",
+			expectedConstants: []interface{}{2, 3, 1, 2, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpAdd),
+				code.Make(code.OpHash, 4),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "fn() { return 5 + 10 }",
+			expectedConstants: []interface{}{
+				5,
+				10,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn() { 1; 2 }",
+			expectedConstants: []interface{}{
+				1,
+				2,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpPop),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn() { }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpReturn),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "let oneArg = fn(a) { a }; oneArg(24);",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+				24,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestBuiltins(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "len([]); push([], 1);",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetBuiltin, 2),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetBuiltin, 3),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpCall, 2),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "fn(a) { fn(b) { a + b } }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpCaptureLocal, 0),
+					code.Make(code.OpClosure, 0, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn(a) { fn(b) { fn(c) { a + b + c } } }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpGetFree, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpCaptureFree, 0),
+					code.Make(code.OpCaptureLocal, 0),
+					code.Make(code.OpClosure, 0, 2),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpCaptureLocal, 0),
+					code.Make(code.OpClosure, 1, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn() { let f = fn() { f() }; f }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpCall, 0),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpCaptureLocal, 0),
+					code.Make(code.OpClosure, 0, 1),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			//x在赋值之前读取的是全局变量
+			input: "let x = 1; fn() { let y = x; let x = 2; x }",
+			expectedConstants: []interface{}{
+				1,
+				2,
+				[]code.Instructions{
+					// 0000
+					code.Make(code.OpGetBoundLocal, 0, 7),
+					// 0004
+					code.Make(code.OpGetGlobal, 0),
+					// 0007
+					code.Make(code.OpSetLocal, 1),
+					// 0009
+					code.Make(code.OpConstant, 1),
+					// 0012
+					code.Make(code.OpSetLocal, 0),
+					// 0014
+					code.Make(code.OpGetBoundLocal, 0, 21),
+					// 0018
+					code.Make(code.OpGetGlobal, 0),
+					// 0021
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestDefaultAndRestParameters(t *testing.T) {
+	input := "fn(a, b = 2, c = a, ...rest) { rest }"
+	program := parse(input)
+	compiler := New()
+	if err := compiler.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	fn, ok := compiler.Bytecode().Constants[1].(*object.CompiledFunction)
+	if !ok {
+		t.Fatalf("constant is not CompiledFunction. got=%T", compiler.Bytecode().Constants[1])
+	}
+	expected := []code.Instructions{
+		// 0000 b的默认值
+		code.Make(code.OpConstant, 0),
+		code.Make(code.OpSetLocal, 1),
+		// 0005 c的默认值
+		code.Make(code.OpGetLocal, 0),
+		code.Make(code.OpSetLocal, 2),
+		// 0009 函数体
+		code.Make(code.OpGetLocal, 3),
+		code.Make(code.OpReturnValue),
+	}
+	if err := testInstructions(expected, fn.Instructions); err != nil {
+		t.Fatalf("testInstructions failed: %s", err)
+	}
+	if fmt.Sprint(fn.Entries) != "[0 5 9]" {
+		t.Errorf("wrong entries. got=%v", fn.Entries)
+	}
+	if fn.NumParameters != 3 || !fn.Rest || fn.NumLocals != 4 || fn.Required() != 1 {
+		t.Errorf("wrong function signature. got=%+v", fn)
+	}
+	if fmt.Sprint(fn.LocalNames) != "[a b c rest]" {
+		t.Errorf("wrong local names. got=%v", fn.LocalNames)
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "for (let i = 0; i < 3; i = i + 1) { if (i == 1) { continue } }",
+			expectedConstants: []interface{}{0, 3, 1, 1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLessThan),
+				// 0013
+				code.Make(code.OpJumpNotTruthy, 52),
+				// 0016
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpEqual),
+				code.Make(code.OpJumpNotTruthy, 33),
+				// 0026
+				code.Make(code.OpJump, 35),
+				code.Make(code.OpNull),
+				code.Make(code.OpJump, 34),
+				// 0033
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+				// 0035 post
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpAdd),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+				// 0049
+				code.Make(code.OpJump, 6),
+				// 0052 循环语句的值
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "for (x in [1]) { break }",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				// 0006
+				code.Make(code.OpIter),
+				// 0007
+				code.Make(code.OpIterNext, 20),
+				code.Make(code.OpSetGlobal, 0),
+				// 0013 break时弹出迭代器
+				code.Make(code.OpPop),
+				code.Make(code.OpJump, 20),
+				// 0017
+				code.Make(code.OpJump, 7),
+				// 0020
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "try { 1 } catch (e) { e }",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpSetupTry, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPopTry),
+				// 0007
+				code.Make(code.OpJump, 19),
+				// 0010 catch
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				// 0016
+				code.Make(code.OpJump, 19),
+				// 0019
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "try { throw 1 } finally { 2 }",
+			expectedConstants: []interface{}{1, 2, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpSetupTry, 16),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpThrow),
+				code.Make(code.OpNull),
+				code.Make(code.OpPopTry),
+				// 0009 finally
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpJump, 21),
+				// 0016 finally后重新抛出
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpPop),
+				code.Make(code.OpThrow),
+				// 0021
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestCompilerPositions(t *testing.T) {
+	program := parse("let a = 1;\nlet b = a +\n  c;")
+	compiler := New()
+	if err := compiler.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := compiler.Bytecode()
+	tests := []struct {
+		offset   int
+		expected string
+	}{
+		{0, "1:9"},  // OpConstant 1
+		{3, "1:1"},  // OpSetGlobal a
+		{6, "2:9"},  // OpGetGlobal a
+		{9, "3:3"},  // OpGetGlobal c
+		{12, "2:9"}, // OpAdd
+		{13, "2:1"}, // OpSetGlobal b
+	}
+	for _, tt := range tests {
+		if got := bytecode.Positions.Lookup(tt.offset).String(); got != tt.expected {
+			t.Errorf("wrong position at offset %d. want=%q, got=%q", tt.offset, tt.expected, got)
+		}
+	}
+	if fmt.Sprint(bytecode.Globals) != "[a c b]" {
+		t.Errorf("wrong global names. got=%v", bytecode.Globals)
+	}
+}
+
+func TestOperandLimits(t *testing.T) {
+	free := func(n int) string {
+		return "fn() {" + repeat(128, "let a%[2]s = true;") + "fn() {" + repeat(n-128, "let b%[2]s = true;") +
+			"fn() {" + repeat(128, "a%[2]s;") + repeat(n-128, "b%[2]s;") + "} } }"
+	}
+	tests := []struct {
+		name     string
+		input    func(n int) string
+		max      int //能够编译的最大规模
+		expected string
+	}{
+		{"locals", func(n int) string { return "fn() {" + repeat(n, "let v%[2]s = true;") + "}" },
+			256, "too many local variables in function (limit 256)"},
+		{"globals", func(n int) string { return repeat(n, "let g%[2]s = true;") },
+			65536, "too many global variables (limit 65536)"},
+		{"constants", func(n int) string { return repeat(n, "%[1]d;") },
+			65536, "too many constants (limit 65536)"},
+		{"arguments", func(n int) string { return "len(" + strings.Repeat(", true", n)[2:] + ")" },
+			255, "too many arguments in call (limit 255)"},
+		{"array", func(n int) string { return "[" + strings.Repeat(", true", n)[2:] + "]" },
+			65535, "too many elements in array literal (limit 65535)"},
+		{"hash", func(n int) string { return "{" + repeat(n, ", %[1]d: true")[2:] + "}" },
+			32767, "too many pairs in hash literal (limit 32767)"},
+		{"free variables", free, 255, "too many free variables in function (limit 255)"},
+		//条件1字节、跳转3字节、n条语句共2n-1字节、跳转3字节、null 1字节，最后的跳转目标为2n+7
+		{"jump", func(n int) string { return "if (true) {" + strings.Repeat("true;", n) + "}" },
+			32764, "code too large: jump target beyond 65535 bytes"},
+	}
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input(tt.max + 1)))
+		if p.ParseProgram(); len(p.Errors()) > 0 {
+			t.Fatalf("%s: parser errors: %v", tt.name, p.Errors()[0])
+		}
+		if err := New().Compile(parse(tt.input(tt.max))); err != nil {
+			t.Errorf("%s: unexpected error at the maximum: %s", tt.name, err)
+		}
+		err := New().Compile(parse(tt.input(tt.max + 1)))
+		//错误信息以出错节点的位置开头
+		if err == nil || !strings.HasPrefix(err.Error(), "1:") || !strings.HasSuffix(err.Error(), tt.expected) {
+			t.Errorf("%s: wrong error. want=%q, got=%v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+//repeat 重复n次format，参数为序号和由序号生成的变量名
+func repeat(n int, format string) string {
+	var out strings.Builder
+	for i := 0; i < n; i++ {
+		name := ""
+		for j := i; ; j /= 26 {
+			name += string(rune('a' + j%26))
+			if j < 26 {
+				break
+			}
+		}
+		fmt.Fprintf(&out, format, i, name)
+	}
+	return out.String()
+}
+
+func TestCompilerScopes(t *testing.T) {
+	compiler := New()
+	if compiler.scopeIndex != 0 {
+		t.Errorf("scopeIndex wrong. got=%d, want=%d", compiler.scopeIndex, 0)
+	}
+	globalSymbolTable := compiler.symbolTable
+	compiler.emit(code.OpMul)
+
+	compiler.enterScope()
+	if compiler.scopeIndex != 1 {
+		t.Errorf("scopeIndex wrong. got=%d, want=%d", compiler.scopeIndex, 1)
+	}
+	compiler.emit(code.OpSub)
+	if len(compiler.scopes[compiler.scopeIndex].instructions) != 1 {
+		t.Errorf("instructions length wrong. got=%d", len(compiler.scopes[compiler.scopeIndex].instructions))
+	}
+	if compiler.symbolTable.Outer != globalSymbolTable {
+		t.Errorf("compiler did not enclose symbolTable")
+	}
+
+	compiler.leaveScope()
+	if compiler.scopeIndex != 0 {
+		t.Errorf("scopeIndex wrong. got=%d, want=%d", compiler.scopeIndex, 0)
+	}
+	if compiler.symbolTable != globalSymbolTable {
+		t.Errorf("compiler did not restore global symbol table")
+	}
+	compiler.emit(code.OpAdd)
+	last := compiler.scopes[compiler.scopeIndex].lastInstruction
+	previous := compiler.scopes[compiler.scopeIndex].previousInstruction
+	if last.Opcode != code.OpAdd || previous.Opcode != code.OpMul {
+		t.Errorf("wrong emitted instructions. last=%d, previous=%d", last.Opcode, previous.Opcode)
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func runCompilerTests(t *testing.T, tests []compilerTestCase) {
+	t.Helper()
+	for _, tt := range tests {
+		program := parse(tt.input)
+		compiler := New()
+		if err := compiler.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		bytecode := compiler.Bytecode()
+		if err := testInstructions(tt.expectedInstructions, bytecode.Instructions); err != nil {
+			t.Fatalf("testInstructions failed for %q: %s", tt.input, err)
+		}
+		if err := testConstants(tt.expectedConstants, bytecode.Constants); err != nil {
+			t.Fatalf("testConstants failed for %q: %s", tt.input, err)
+		}
+	}
+}
+
+func concatInstructions(s []code.Instructions) code.Instructions {
+	out := code.Instructions{}
+	for _, ins := range s {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+func testInstructions(expected []code.Instructions, actual code.Instructions) error {
+	concatted := concatInstructions(expected)
+	if len(actual) != len(concatted) {
+		return fmt.Errorf("wrong instructions length.\nwant=%q\ngot =%q", concatted, actual)
+	}
+	for i, ins := range concatted {
+		if actual[i] != ins {
+			return fmt.Errorf("wrong instruction at %d.\nwant=%q\ngot =%q", i, concatted, actual)
+		}
+	}
+	return nil
+}
+
+func testConstants(expected []interface{}, actual []object.Object) error {
+	if len(expected) != len(actual) {
+		return fmt.Errorf("wrong number of constants. got=%d, want=%d", len(actual), len(expected))
+	}
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			result, ok := actual[i].(*object.Integer)
+			if !ok || result.Value != int64(constant) {
+				return fmt.Errorf("constant %d - wrong integer. want=%d, got=%+v", i, constant, actual[i])
+			}
+		case float64:
+			result, ok := actual[i].(*object.Float)
+			if !ok || result.Value != constant {
+				return fmt.Errorf("constant %d - wrong float. want=%g, got=%+v", i, constant, actual[i])
+			}
+		case string:
+			if actual[i].Inspect() != constant {
+				return fmt.Errorf("constant %d - wrong value. want=%q, got=%q", i, constant, actual[i].Inspect())
+			}
+		case []code.Instructions:
+			fn, ok := actual[i].(*object.CompiledFunction)
+			if !ok {
+				return fmt.Errorf("constant %d - not a function: %T", i, actual[i])
+			}
+			if err := testInstructions(constant, fn.Instructions); err != nil {
+				return fmt.Errorf("constant %d - testInstructions failed: %s", i, err)
+			}
+		}
+	}
+	return nil
+}
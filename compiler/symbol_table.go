@@ -0,0 +1,224 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope  SymbolScope = "GLOBAL"
+	LocalScope   SymbolScope = "LOCAL"
+	BuiltinScope SymbolScope = "BUILTIN"
+	FreeScope    SymbolScope = "FREE"
+)
+
+//Symbol 编译期确定的变量信息，Index为变量在所属作用域中的下标
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+//SymbolTable 符号表，每个函数对应一个，Outer指向外层函数的符号表
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+	//FreeSymbols 捕获的外层变量，按捕获顺序排列，保存的是外层作用域中的符号
+	FreeSymbols []Symbol
+
+	//late 执行到赋值语句才绑定的局部变量，绑定前读取的是外层的同名变量
+	late map[string]bool
+	//fallbacks 局部变量未绑定时代替它的外层变量
+	fallbacks map[Symbol]Symbol
+
+	//block 语句块的作用域，只有DefineBlock定义的变量属于它，其余变量定义在外层作用域中
+	block bool
+	//blockNames 其中的语句块作用域定义的变量名，按下标记录
+	blockNames map[int]string
+}
+
+func NewSymbolTable() *SymbolTable {
+	s := make(map[string]Symbol)
+	return &SymbolTable{store: s, late: map[string]bool{}, fallbacks: map[Symbol]Symbol{}}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+//NewBlockSymbolTable 创建语句块的作用域，如catch语句块，语句块的变量保存在所属函数（或全局）的作用域中
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewEnclosedSymbolTable(outer)
+	s.block = true
+	return s
+}
+
+//owner 保存变量的作用域，即最近的非语句块作用域
+func (s *SymbolTable) owner() *SymbolTable {
+	for s.block {
+		s = s.Outer
+	}
+	return s
+}
+
+//DefineBlock 定义只在语句块中可见的变量
+func (s *SymbolTable) DefineBlock(name string) Symbol {
+	owner := s.owner()
+	symbol := Symbol{Name: name, Index: owner.numDefinitions, Scope: LocalScope}
+	if owner.Outer == nil {
+		symbol.Scope = GlobalScope
+	}
+	owner.numDefinitions++
+	if owner.blockNames == nil {
+		owner.blockNames = map[int]string{}
+	}
+	owner.blockNames[symbol.Index] = name
+	s.store[name] = symbol
+	return symbol
+}
+
+//Define 在当前作用域中定义变量，同名变量已在当前作用域定义时复用原来的下标
+//语句块作用域中只有DefineBlock定义的变量属于语句块，其余变量定义在外层作用域中
+func (s *SymbolTable) Define(name string) Symbol {
+	if s.block {
+		if symbol, ok := s.store[name]; ok {
+			return symbol
+		}
+		return s.Outer.Define(name)
+	}
+	scope := LocalScope
+	if s.Outer == nil {
+		scope = GlobalScope
+	}
+	if symbol, ok := s.store[name]; ok && symbol.Scope == scope {
+		return symbol
+	}
+	symbol := Symbol{Name: name, Index: s.numDefinitions, Scope: scope}
+	s.store[name] = symbol
+	s.numDefinitions++
+	return symbol
+}
+
+//DefineLate 定义执行到赋值语句才绑定的局部变量，外层作用域有同名变量时才需要
+func (s *SymbolTable) DefineLate(name string) Symbol {
+	symbol := s.Define(name)
+	s.late[name] = true
+	return symbol
+}
+
+//DefineBuiltin 定义内置函数，index为内置函数的下标
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+//defineFree 记录捕获的外层变量
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Scope: FreeScope}
+	s.store[original.Name] = symbol
+	return symbol
+}
+
+//Resolve 查找变量，外层函数的局部变量会被记录为当前函数捕获的变量
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	if !ok && s.block {
+		return s.Outer.Resolve(name)
+	}
+	if !ok && s.Outer != nil {
+		symbol, ok = s.Outer.Resolve(name)
+		if !ok {
+			return symbol, ok
+		}
+		if symbol.Scope == GlobalScope || symbol.Scope == BuiltinScope {
+			return symbol, ok
+		}
+		return s.defineFree(symbol), true
+	}
+	return symbol, ok
+}
+
+//IsLate 变量是否可能在绑定之前被读取
+func (s *SymbolTable) IsLate(symbol Symbol) bool {
+	if s.block {
+		if _, ok := s.store[symbol.Name]; ok {
+			return false
+		}
+		return s.Outer.IsLate(symbol)
+	}
+	switch symbol.Scope {
+	case LocalScope:
+		return s.late[symbol.Name]
+	case FreeScope:
+		return s.Outer.IsLate(s.FreeSymbols[symbol.Index])
+	}
+	return false
+}
+
+//Fallback 变量未绑定时读取的外层变量，外层变量同样可能未绑定
+func (s *SymbolTable) Fallback(symbol Symbol) (Symbol, bool) {
+	if s.block {
+		return s.Outer.Fallback(symbol)
+	}
+	if fallback, ok := s.fallbacks[symbol]; ok {
+		return fallback, true
+	}
+	var fallback Symbol
+	var ok bool
+	switch symbol.Scope {
+	case LocalScope:
+		fallback, ok = s.Outer.Resolve(symbol.Name)
+	case FreeScope:
+		fallback, ok = s.Outer.Fallback(s.FreeSymbols[symbol.Index])
+	}
+	if !ok {
+		return fallback, false
+	}
+	if fallback.Scope == LocalScope || fallback.Scope == FreeScope {
+		//与同名的局部变量并存，只记录捕获，不覆盖store中的符号
+		s.FreeSymbols = append(s.FreeSymbols, fallback)
+		fallback = Symbol{Name: symbol.Name, Index: len(s.FreeSymbols) - 1, Scope: FreeScope}
+	}
+	s.fallbacks[symbol] = fallback
+	return fallback, true
+}
+
+//defined 变量是否已在当前或外层作用域中定义，不记录捕获
+func (s *SymbolTable) defined(name string) bool {
+	for ; s != nil; s = s.Outer {
+		if _, ok := s.store[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+//Global 最外层的符号表
+func (s *SymbolTable) Global() *SymbolTable {
+	for s.Outer != nil {
+		s = s.Outer
+	}
+	return s
+}
+
+//NumDefinitions 当前作用域中定义的变量个数，包括其中语句块作用域定义的变量
+func (s *SymbolTable) NumDefinitions() int {
+	return s.numDefinitions
+}
+
+//Names 当前作用域中定义的变量名，按下标排列
+func (s *SymbolTable) Names() []string {
+	names := make([]string, s.numDefinitions)
+	for name, symbol := range s.store {
+		if symbol.Scope == LocalScope || symbol.Scope == GlobalScope {
+			names[symbol.Index] = name
+		}
+	}
+	for index, name := range s.blockNames {
+		names[index] = name
+	}
+	return names
+}
@@ -0,0 +1,132 @@
+package compiler
+
+import "testing"
+
+func TestDefine(t *testing.T) {
+	expected := map[string]Symbol{
+		"a": {Name: "a", Scope: GlobalScope, Index: 0},
+		"b": {Name: "b", Scope: GlobalScope, Index: 1},
+		"c": {Name: "c", Scope: LocalScope, Index: 0},
+		"d": {Name: "d", Scope: LocalScope, Index: 1},
+	}
+	global := NewSymbolTable()
+	if a := global.Define("a"); a != expected["a"] {
+		t.Errorf("expected a=%+v, got=%+v", expected["a"], a)
+	}
+	if b := global.Define("b"); b != expected["b"] {
+		t.Errorf("expected b=%+v, got=%+v", expected["b"], b)
+	}
+	if a := global.Define("a"); a != expected["a"] {
+		t.Errorf("redefining a should reuse its index. got=%+v", a)
+	}
+	local := NewEnclosedSymbolTable(global)
+	if c := local.Define("c"); c != expected["c"] {
+		t.Errorf("expected c=%+v, got=%+v", expected["c"], c)
+	}
+	if d := local.Define("d"); d != expected["d"] {
+		t.Errorf("expected d=%+v, got=%+v", expected["d"], d)
+	}
+	if local.Global() != global {
+		t.Errorf("Global() did not return the outermost table")
+	}
+}
+
+func TestResolveLocalAndBuiltins(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.DefineBuiltin(0, "len")
+	local := NewEnclosedSymbolTable(global)
+	local.Define("c")
+	expected := []Symbol{
+		{Name: "a", Scope: GlobalScope, Index: 0},
+		{Name: "len", Scope: BuiltinScope, Index: 0},
+		{Name: "c", Scope: LocalScope, Index: 0},
+	}
+	for _, sym := range expected {
+		result, ok := local.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", sym.Name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+	if len(local.FreeSymbols) != 0 {
+		t.Errorf("globals and builtins should not be captured. got=%+v", local.FreeSymbols)
+	}
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	first := NewEnclosedSymbolTable(global)
+	first.Define("c")
+	second := NewEnclosedSymbolTable(first)
+	second.Define("e")
+
+	tests := []struct {
+		name     string
+		expected Symbol
+	}{
+		{"a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{"c", Symbol{Name: "c", Scope: FreeScope, Index: 0}},
+		{"e", Symbol{Name: "e", Scope: LocalScope, Index: 0}},
+	}
+	for _, tt := range tests {
+		result, ok := second.Resolve(tt.name)
+		if !ok {
+			t.Errorf("name %s not resolvable", tt.name)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", tt.name, tt.expected, result)
+		}
+	}
+	if len(second.FreeSymbols) != 1 || second.FreeSymbols[0] != (Symbol{Name: "c", Scope: LocalScope, Index: 0}) {
+		t.Errorf("wrong free symbols. got=%+v", second.FreeSymbols)
+	}
+	if _, ok := second.Resolve("x"); ok {
+		t.Errorf("undefined name should not be resolvable")
+	}
+	//捕获的变量被重新定义后成为局部变量
+	if c := second.Define("c"); c != (Symbol{Name: "c", Scope: LocalScope, Index: 1}) {
+		t.Errorf("redefined free variable should become local. got=%+v", c)
+	}
+	if names := second.Names(); len(names) != 2 || names[0] != "e" || names[1] != "c" {
+		t.Errorf("wrong names. got=%v", names)
+	}
+}
+
+func TestBlockScope(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("e")
+	local := NewEnclosedSymbolTable(global)
+	local.Define("a")
+	block := NewBlockSymbolTable(local)
+	e := block.DefineBlock("e")
+	if expected := (Symbol{Name: "e", Scope: LocalScope, Index: 1}); e != expected {
+		t.Errorf("expected e=%+v, got=%+v", expected, e)
+	}
+	if b := block.Define("b"); b != (Symbol{Name: "b", Scope: LocalScope, Index: 2}) {
+		t.Errorf("b should be defined in the function scope. got=%+v", b)
+	}
+	if resolved, _ := block.Resolve("e"); resolved != e {
+		t.Errorf("e should resolve to the block symbol. got=%+v", resolved)
+	}
+	if resolved, _ := local.Resolve("e"); resolved != (Symbol{Name: "e", Scope: GlobalScope, Index: 0}) {
+		t.Errorf("e should not be visible outside the block. got=%+v", resolved)
+	}
+	if _, ok := local.Resolve("b"); !ok {
+		t.Errorf("b should be visible outside the block")
+	}
+	if local.NumDefinitions() != 3 {
+		t.Errorf("wrong number of definitions. got=%d", local.NumDefinitions())
+	}
+	if names := local.Names(); names[1] != "e" {
+		t.Errorf("block names should be listed. got=%v", names)
+	}
+	if len(local.FreeSymbols) != 0 || len(block.FreeSymbols) != 0 {
+		t.Errorf("block symbols should not be captured")
+	}
+}
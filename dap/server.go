@@ -0,0 +1,367 @@
+package dap
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/debugger"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// dap/server.go
+//
+//调试适配器，通过标准输入输出与编辑器交换DAP消息。程序只有一个线程，编号为1。
+//调试过程：initialize → launch → setBreakpoints → configurationDone，之后程序开始执行
+
+//threadID 唯一线程的编号
+const threadID = 1
+
+//Server 调试适配器
+type Server struct {
+	in *bufio.Reader
+
+	mu  sync.Mutex //保护out和seq，事件在其他goroutine中发出
+	out io.Writer
+	seq int
+
+	debugger    *debugger.Debugger
+	source      string //程序的路径
+	stopOnEntry bool
+	breakpoints []int //launch之前设置的断点
+	started     bool
+	exited      chan struct{}
+
+	handles []func() []Variable //variablesReference-1对应的变量，每次继续执行后清空
+}
+
+//NewServer 从in读取请求，响应和事件写入out
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{in: bufio.NewReader(in), out: out, exited: make(chan struct{})}
+}
+
+//Run 处理请求直到收到disconnect请求或输入结束，结束时终止正在调试的程序
+func (s *Server) Run() error {
+	defer s.terminate()
+	for {
+		msg, err := ReadMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Type != "request" {
+			continue
+		}
+		body, err := s.request(msg)
+		if err := s.respond(msg, body, err); err != nil {
+			return err
+		}
+		if msg.Command == "initialize" {
+			s.send(&Message{Type: "event", Event: "initialized"}, nil)
+		}
+		if msg.Command == "disconnect" {
+			return nil
+		}
+	}
+}
+
+//Output 发出output事件，category为stdout或stderr
+func (s *Server) Output(category, output string) error {
+	return s.send(&Message{Type: "event", Event: "output"}, &OutputEventBody{Category: category, Output: output})
+}
+
+//outputWriter 程序的输出转换为output事件
+type outputWriter struct {
+	server   *Server
+	category string
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	if err := w.server.Output(w.category, string(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *Server) send(msg *Message, body interface{}) error {
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		msg.Body = data
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	msg.Seq = s.seq
+	return WriteMessage(s.out, msg)
+}
+
+func (s *Server) respond(req *Message, body interface{}, err error) error {
+	msg := &Message{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: err == nil}
+	if err != nil {
+		msg.Message = err.Error()
+		body = nil
+	}
+	return s.send(msg, body)
+}
+
+func (s *Server) request(msg *Message) (interface{}, error) {
+	switch msg.Command {
+	case "initialize":
+		return &Capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsEvaluateForHovers:        true,
+			SupportsTerminateRequest:         true,
+		}, nil
+	case "launch":
+		args := &LaunchArguments{}
+		if err := json.Unmarshal(msg.Arguments, args); err != nil {
+			return nil, err
+		}
+		return nil, s.launch(args)
+	case "setBreakpoints":
+		args := &SetBreakpointsArguments{}
+		if err := json.Unmarshal(msg.Arguments, args); err != nil {
+			return nil, err
+		}
+		return s.setBreakpoints(args), nil
+	case "configurationDone":
+		if s.debugger == nil {
+			return nil, fmt.Errorf("no program launched")
+		}
+		if !s.started {
+			s.started = true
+			s.debugger.Start(s.stopOnEntry)
+			go s.forwardEvents()
+		}
+		return nil, nil
+	case "threads":
+		return &ThreadsResponseBody{Threads: []Thread{{ID: threadID, Name: "main"}}}, nil
+	}
+
+	if !s.started {
+		if msg.Command == "disconnect" || msg.Command == "terminate" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s: program is not running", msg.Command)
+	}
+	switch msg.Command {
+	case "stackTrace":
+		return s.stackTrace()
+	case "scopes":
+		args := &ScopesArguments{}
+		if err := json.Unmarshal(msg.Arguments, args); err != nil {
+			return nil, err
+		}
+		return s.scopes(args.FrameID)
+	case "variables":
+		args := &VariablesArguments{}
+		if err := json.Unmarshal(msg.Arguments, args); err != nil {
+			return nil, err
+		}
+		if args.VariablesReference <= 0 || args.VariablesReference > len(s.handles) {
+			return nil, fmt.Errorf("invalid variablesReference %d", args.VariablesReference)
+		}
+		return &VariablesResponseBody{Variables: s.handles[args.VariablesReference-1]()}, nil
+	case "evaluate":
+		args := &EvaluateArguments{}
+		if err := json.Unmarshal(msg.Arguments, args); err != nil {
+			return nil, err
+		}
+		return s.evaluate(args)
+	case "continue":
+		return &ContinueResponseBody{AllThreadsContinued: true}, s.resume(s.debugger.Continue)
+	case "next":
+		return nil, s.resume(s.debugger.StepOver)
+	case "stepIn":
+		return nil, s.resume(s.debugger.StepIn)
+	case "stepOut":
+		return nil, s.resume(s.debugger.StepOut)
+	case "pause":
+		s.debugger.Pause()
+		return nil, nil
+	case "terminate", "disconnect":
+		s.terminate()
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported request: %s", msg.Command)
+}
+
+//launch 解析程序并展开宏，等到configurationDone时再开始执行
+func (s *Server) launch(args *LaunchArguments) error {
+	if s.debugger != nil {
+		return fmt.Errorf("program already launched")
+	}
+	source, err := os.ReadFile(args.Program)
+	if err != nil {
+		return err
+	}
+	p := parser.New(lexer.NewWithFilename(args.Program, string(source)))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		messages := []string{}
+		for _, d := range p.Diagnostics() {
+			messages = append(messages, d.Error())
+		}
+		return fmt.Errorf("%s", strings.Join(messages, "\n"))
+	}
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, errObj := evaluator.ExpandMacrosContext(context.Background(), program, macroEnv, evaluator.Limits{})
+	if errObj != nil {
+		return fmt.Errorf("%s", errObj.Inspect())
+	}
+	s.source = args.Program
+	s.stopOnEntry = args.StopOnEntry
+	env := object.NewEnvironment()
+	env.SetOutput(&outputWriter{s, "stdout"}, &outputWriter{s, "stderr"})
+	s.debugger = debugger.New(expanded.(*ast.Program), env)
+	s.debugger.SetBreakpoints(s.breakpoints)
+	return nil
+}
+
+func (s *Server) setBreakpoints(args *SetBreakpointsArguments) *SetBreakpointsResponseBody {
+	lines := []int{}
+	for _, bp := range args.Breakpoints {
+		lines = append(lines, bp.Line)
+	}
+	body := &SetBreakpointsResponseBody{Breakpoints: []Breakpoint{}}
+	if s.debugger == nil {
+		s.breakpoints = lines
+		for _, line := range lines {
+			body.Breakpoints = append(body.Breakpoints, Breakpoint{Verified: true, Line: line})
+		}
+		return body
+	}
+	for _, bp := range s.debugger.SetBreakpoints(lines) {
+		body.Breakpoints = append(body.Breakpoints, Breakpoint{Verified: bp.Verified, Line: bp.Line})
+	}
+	return body
+}
+
+//forwardEvents 将调试器的事件转换为DAP事件
+func (s *Server) forwardEvents() {
+	defer close(s.exited)
+	for event := range s.debugger.Events() {
+		switch event.Kind {
+		case debugger.Stopped:
+			s.send(&Message{Type: "event", Event: "stopped"}, &StoppedEventBody{Reason: event.Reason, ThreadID: threadID, AllThreadsStopped: true})
+		case debugger.Exited:
+			code := 0
+			if err, ok := event.Result.(*object.Error); ok {
+				code = 1
+				if err.Message != debugger.ErrTerminated.Error() {
+					s.Output("stderr", err.StackTrace())
+				}
+			}
+			s.send(&Message{Type: "event", Event: "exited"}, &ExitedEventBody{ExitCode: code})
+			s.send(&Message{Type: "event", Event: "terminated"}, nil)
+		}
+	}
+}
+
+//resume 继续执行之前清空变量的引用
+func (s *Server) resume(command func() error) error {
+	s.handles = nil
+	return command()
+}
+
+//terminate 终止程序并等待程序结束
+func (s *Server) terminate() {
+	if !s.started {
+		return
+	}
+	s.debugger.Terminate()
+	<-s.exited
+}
+
+func (s *Server) stackTrace() (interface{}, error) {
+	frames, err := s.debugger.StackTrace()
+	if err != nil {
+		return nil, err
+	}
+	body := &StackTraceResponseBody{StackFrames: []StackFrame{}, TotalFrames: len(frames)}
+	for i, frame := range frames {
+		body.StackFrames = append(body.StackFrames, StackFrame{
+			ID:     i,
+			Name:   frame.Name,
+			Source: &Source{Path: s.source},
+			Line:   frame.Pos.Line,
+			Column: frame.Pos.Column,
+		})
+	}
+	return body, nil
+}
+
+func (s *Server) scopes(frameID int) (interface{}, error) {
+	scopes, err := s.debugger.Scopes(frameID)
+	if err != nil {
+		return nil, err
+	}
+	body := &ScopesResponseBody{Scopes: []Scope{}}
+	for _, scope := range scopes {
+		variables := scope.Variables
+		ref := s.handle(func() []Variable {
+			result := []Variable{}
+			for _, v := range variables {
+				result = append(result, s.variable(v.Name, v.Value))
+			}
+			return result
+		})
+		body.Scopes = append(body.Scopes, Scope{Name: scope.Name, VariablesReference: ref})
+	}
+	return body, nil
+}
+
+func (s *Server) evaluate(args *EvaluateArguments) (interface{}, error) {
+	result, err := s.debugger.Evaluate(args.Expression, args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+	v := s.variable("", result)
+	return &EvaluateResponseBody{Result: v.Value, Type: v.Type, VariablesReference: v.VariablesReference}, nil
+}
+
+//handle 登记可以展开的变量，返回variablesReference
+func (s *Server) handle(variables func() []Variable) int {
+	s.handles = append(s.handles, variables)
+	return len(s.handles)
+}
+
+//variable 数组和哈希可以展开查看其中的元素
+func (s *Server) variable(name string, value object.Object) Variable {
+	v := Variable{Name: name, Value: value.Inspect(), Type: string(value.Type())}
+	switch value := value.(type) {
+	case *object.Array:
+		v.VariablesReference = s.handle(func() []Variable {
+			result := []Variable{}
+			for i, element := range value.Elements {
+				result = append(result, s.variable(fmt.Sprintf("[%d]", i), element))
+			}
+			return result
+		})
+	case *object.Hash:
+		v.VariablesReference = s.handle(func() []Variable {
+			result := []Variable{}
+			for _, pair := range value.Pairs {
+				result = append(result, s.variable(pair.Key.Inspect(), pair.Value))
+			}
+			sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+			return result
+		})
+	}
+	return v
+}
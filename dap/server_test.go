@@ -0,0 +1,288 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+//client 脚本化的客户端，通过管道与服务器通信
+type client struct {
+	t      *testing.T
+	w      io.Writer
+	r      *bufio.Reader
+	seq    int
+	events []*Message //等待读取的事件
+	done   chan error
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &client{t: t, w: clientOut, r: bufio.NewReader(clientIn), done: make(chan error, 1)}
+	go func() {
+		err := NewServer(serverIn, serverOut).Run()
+		serverOut.Close()
+		c.done <- err
+	}()
+	return c
+}
+
+func (c *client) read() *Message {
+	c.t.Helper()
+	msg, err := ReadMessage(c.r)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+//request 发送请求并等待响应，期间收到的事件留给event读取
+func (c *client) request(command string, args interface{}, body interface{}) *Message {
+	c.t.Helper()
+	c.seq++
+	msg := &Message{Seq: c.seq, Type: "request", Command: command}
+	if args != nil {
+		data, err := json.Marshal(args)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		msg.Arguments = data
+	}
+	if err := WriteMessage(c.w, msg); err != nil {
+		c.t.Fatal(err)
+	}
+	for {
+		resp := c.read()
+		if resp.Type == "event" {
+			c.events = append(c.events, resp)
+			continue
+		}
+		if resp.RequestSeq != c.seq || resp.Command != command {
+			c.t.Fatalf("wrong response for %s. got=%+v", command, resp)
+		}
+		if resp.Success && body != nil {
+			if err := json.Unmarshal(resp.Body, body); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return resp
+	}
+}
+
+//mustRequest 请求必须成功
+func (c *client) mustRequest(command string, args interface{}, body interface{}) {
+	c.t.Helper()
+	if resp := c.request(command, args, body); !resp.Success {
+		c.t.Fatalf("%s failed: %s", command, resp.Message)
+	}
+}
+
+//event 等待指定的事件，跳过之前的其他事件
+func (c *client) event(name string, body interface{}) {
+	c.t.Helper()
+	for {
+		var msg *Message
+		if len(c.events) > 0 {
+			msg, c.events = c.events[0], c.events[1:]
+		} else {
+			msg = c.read()
+		}
+		if msg.Type != "event" {
+			c.t.Fatalf("unexpected %s while waiting for %s event", msg.Type, name)
+		}
+		if msg.Event != name {
+			continue
+		}
+		if body != nil {
+			if err := json.Unmarshal(msg.Body, body); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return
+	}
+}
+
+func (c *client) expectStop(reason string, line int) {
+	c.t.Helper()
+	stopped := &StoppedEventBody{}
+	c.event("stopped", stopped)
+	if stopped.Reason != reason || stopped.ThreadID != threadID {
+		c.t.Fatalf("wrong stopped event. want reason %s, got=%+v", reason, stopped)
+	}
+	trace := &StackTraceResponseBody{}
+	c.mustRequest("stackTrace", map[string]int{"threadId": threadID}, trace)
+	if trace.StackFrames[0].Line != line {
+		c.t.Fatalf("wrong line. want=%d, got=%+v", line, trace.StackFrames[0])
+	}
+}
+
+const source = `let scale = 10;
+let add = fn(a, b) {
+  let sum = [a, b, {"total": a + b}];
+  sum[2]["total"] * scale
+};
+let result = add(1, 2);
+let done = true;
+`
+
+//launch 完成初始化和配置
+func launch(t *testing.T, input string, stopOnEntry bool, breakpoints ...int) (*client, string) {
+	path := filepath.Join(t.TempDir(), "main.mk")
+	if err := os.WriteFile(path, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(t)
+	caps := &Capabilities{}
+	c.mustRequest("initialize", map[string]string{"adapterID": "monkey"}, caps)
+	if !caps.SupportsConfigurationDoneRequest {
+		t.Fatalf("wrong capabilities. got=%+v", caps)
+	}
+	c.event("initialized", nil)
+	c.mustRequest("launch", &LaunchArguments{Program: path, StopOnEntry: stopOnEntry}, nil)
+	args := &SetBreakpointsArguments{Source: Source{Path: path}}
+	for _, line := range breakpoints {
+		args.Breakpoints = append(args.Breakpoints, SourceBreakpoint{Line: line})
+	}
+	c.mustRequest("setBreakpoints", args, nil)
+	c.mustRequest("configurationDone", nil, nil)
+	return c, path
+}
+
+func (c *client) disconnect() {
+	c.t.Helper()
+	c.mustRequest("disconnect", nil, nil)
+	if err := <-c.done; err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func TestSession(t *testing.T) {
+	c, path := launch(t, source, false, 4)
+	c.expectStop("breakpoint", 4)
+
+	trace := &StackTraceResponseBody{}
+	c.mustRequest("stackTrace", map[string]int{"threadId": threadID}, trace)
+	expected := []StackFrame{
+		{ID: 0, Name: "add(1, 2)", Source: &Source{Path: path}, Line: 4, Column: 3},
+		{ID: 1, Name: "main", Source: &Source{Path: path}, Line: 6, Column: 1},
+	}
+	if !reflect.DeepEqual(trace.StackFrames, expected) {
+		t.Errorf("wrong stack trace.\nwant=%+v\ngot=%+v", expected, trace.StackFrames)
+	}
+
+	scopes := &ScopesResponseBody{}
+	c.mustRequest("scopes", &ScopesArguments{FrameID: 0}, scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[1].Name != "Globals" {
+		t.Fatalf("wrong scopes. got=%+v", scopes.Scopes)
+	}
+	locals := &VariablesResponseBody{}
+	c.mustRequest("variables", &VariablesArguments{VariablesReference: scopes.Scopes[0].VariablesReference}, locals)
+	names := []string{}
+	for _, v := range locals.Variables {
+		names = append(names, v.Name+"="+v.Value)
+	}
+	if !reflect.DeepEqual(names, []string{"a=1", "b=2", "sum=[1, 2, {total: 3}]"}) {
+		t.Errorf("wrong locals. got=%v", names)
+	}
+
+	sum := locals.Variables[2]
+	elements := &VariablesResponseBody{}
+	c.mustRequest("variables", &VariablesArguments{VariablesReference: sum.VariablesReference}, elements)
+	if len(elements.Variables) != 3 || elements.Variables[2].Name != "[2]" || elements.Variables[2].Type != "HASH" {
+		t.Fatalf("wrong elements. got=%+v", elements.Variables)
+	}
+	pairs := &VariablesResponseBody{}
+	c.mustRequest("variables", &VariablesArguments{VariablesReference: elements.Variables[2].VariablesReference}, pairs)
+	if len(pairs.Variables) != 1 || pairs.Variables[0].Name != "total" || pairs.Variables[0].Value != "3" {
+		t.Errorf("wrong pairs. got=%+v", pairs.Variables)
+	}
+
+	result := &EvaluateResponseBody{}
+	c.mustRequest("evaluate", &EvaluateArguments{Expression: "a * scale", FrameID: 0}, result)
+	if result.Result != "10" || result.Type != "INTEGER" {
+		t.Errorf("wrong evaluate result. got=%+v", result)
+	}
+	if resp := c.request("evaluate", &EvaluateArguments{Expression: "let = ", FrameID: 0}, nil); resp.Success {
+		t.Errorf("expected evaluate to fail")
+	}
+
+	c.mustRequest("stepOut", map[string]int{"threadId": threadID}, nil)
+	c.expectStop("step", 7)
+	c.mustRequest("continue", map[string]int{"threadId": threadID}, nil)
+	exited := &ExitedEventBody{}
+	c.event("exited", exited)
+	if exited.ExitCode != 0 {
+		t.Errorf("wrong exit code. got=%d", exited.ExitCode)
+	}
+	c.event("terminated", nil)
+	c.disconnect()
+}
+
+func TestStepping(t *testing.T) {
+	c, _ := launch(t, source, true)
+	c.expectStop("entry", 1)
+	c.mustRequest("next", map[string]int{"threadId": threadID}, nil)
+	c.expectStop("step", 2)
+	c.mustRequest("next", map[string]int{"threadId": threadID}, nil)
+	c.expectStop("step", 6)
+	c.mustRequest("stepIn", map[string]int{"threadId": threadID}, nil)
+	c.expectStop("step", 3)
+	c.disconnect()
+}
+
+func TestOutput(t *testing.T) {
+	c, _ := launch(t, "puts(1, \"two\");", false)
+	outputs := []string{}
+	for len(outputs) < 2 {
+		output := &OutputEventBody{}
+		c.event("output", output)
+		if output.Category != "stdout" {
+			t.Errorf("wrong category. got=%q", output.Category)
+		}
+		outputs = append(outputs, output.Output)
+	}
+	if !reflect.DeepEqual(outputs, []string{"1\n", "two\n"}) {
+		t.Errorf("wrong output. got=%q", outputs)
+	}
+	c.event("exited", nil)
+	c.disconnect()
+}
+
+func TestRuntimeError(t *testing.T) {
+	c, _ := launch(t, "let x = 1;\nx + true;\n", false)
+	output := &OutputEventBody{}
+	c.event("output", output)
+	if output.Category != "stderr" || output.Output[:len("ERROR: type mismatch")] != "ERROR: type mismatch" {
+		t.Errorf("wrong output. got=%+v", output)
+	}
+	exited := &ExitedEventBody{}
+	c.event("exited", exited)
+	if exited.ExitCode != 1 {
+		t.Errorf("wrong exit code. got=%d", exited.ExitCode)
+	}
+	c.disconnect()
+}
+
+func TestLaunchErrors(t *testing.T) {
+	c := newClient(t)
+	c.mustRequest("initialize", nil, nil)
+	c.event("initialized", nil)
+	if resp := c.request("launch", &LaunchArguments{Program: "missing.mk"}, nil); resp.Success {
+		t.Errorf("expected launch of a missing file to fail")
+	}
+	path := filepath.Join(t.TempDir(), "bad.mk")
+	os.WriteFile(path, []byte("let = 1;"), 0644)
+	resp := c.request("launch", &LaunchArguments{Program: path}, nil)
+	if resp.Success || resp.Message != path+":1:5: expected next token to be IDENT, got = instead" {
+		t.Errorf("wrong launch response. got=%+v", resp)
+	}
+	if resp := c.request("stackTrace", nil, nil); resp.Success {
+		t.Errorf("expected stackTrace to fail before launch")
+	}
+	c.disconnect()
+}
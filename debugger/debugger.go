@@ -0,0 +1,374 @@
+package debugger
+
+import (
+	"context"
+	"fmt"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/token"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// debugger/debugger.go
+//
+//基于求值钩子的调试器：程序在单独的goroutine中执行，钩子在每个语句之前检查断点和单步条件，
+//需要暂停时发出Stopped事件并等待下一条命令。暂停期间可以查看调用栈、变量，以及在暂停的帧中求值表达式。
+//同一行上的多个语句只暂停一次
+
+//EventKind 事件类型
+type EventKind int
+
+const (
+	Stopped EventKind = iota + 1 //程序暂停
+	Exited                       //程序执行结束
+)
+
+//暂停的原因，与DAP协议中stopped事件的reason一致
+const (
+	ReasonEntry      = "entry"
+	ReasonBreakpoint = "breakpoint"
+	ReasonStep       = "step"
+	ReasonPause      = "pause"
+)
+
+//Event 调试器发出的事件
+type Event struct {
+	Kind   EventKind
+	Reason string         //暂停的原因
+	Pos    token.Position //暂停的位置
+	Result object.Object  //程序的执行结果，出错时为*object.Error
+}
+
+//Frame 调用栈中的一帧
+type Frame struct {
+	Name string         //函数调用，如 add(1, 2)，顶层为 main
+	Pos  token.Position //当前执行的语句的位置
+	env  *object.Environment
+}
+
+//Variable 变量及其值
+type Variable struct {
+	Name  string
+	Value object.Object
+}
+
+//Scope 帧中可以访问的一层环境
+type Scope struct {
+	Name      string //Locals为帧自身的环境，Globals为最外层环境，其余为Closure
+	Variables []Variable
+}
+
+//Breakpoint 断点，Verified为false表示该行没有语句，不会暂停
+type Breakpoint struct {
+	Line     int
+	Verified bool
+}
+
+//ErrTerminated 调试器结束程序时返回的错误
+var ErrTerminated = fmt.Errorf("program terminated by debugger")
+
+//ErrNotPaused 程序没有暂停时无法执行的命令返回的错误
+var ErrNotPaused = fmt.Errorf("program is not paused")
+
+type mode int
+
+const (
+	modeRun mode = iota
+	modeEntry
+	modePause
+	modeStepIn
+	modeStepOver
+	modeStepOut
+)
+
+//Debugger 调试一个程序，所有方法都可以在其他goroutine中调用
+type Debugger struct {
+	program *ast.Program
+	env     *object.Environment
+	events  chan Event
+	resume  chan mode
+
+	mu          sync.Mutex
+	lines       map[int]bool //有语句开始的行
+	breakpoints map[int]bool
+	mode        mode
+	stepDepth   int     //单步开始时的调用深度
+	lastLine    int     //上一个语句所在的行
+	lastDepth   int     //上一个语句所在的调用深度
+	frames      []Frame //每一层调用当前执行的语句，最外层在前
+	paused      bool
+	terminated  bool
+	evaluating  bool //正在暂停的帧中求值表达式，不检查断点
+}
+
+//New 创建调试器，env为程序执行的环境，为nil时使用新的环境
+func New(program *ast.Program, env *object.Environment) *Debugger {
+	if env == nil {
+		env = object.NewEnvironment()
+	}
+	d := &Debugger{
+		program:     program,
+		env:         env,
+		events:      make(chan Event, 1),
+		resume:      make(chan mode),
+		lines:       map[int]bool{},
+		breakpoints: map[int]bool{},
+	}
+	ast.Inspect(program, func(n ast.Node) bool {
+		if isStatement(n) {
+			d.lines[n.Pos().Line] = true
+		}
+		return true
+	})
+	return d
+}
+
+//Events 事件，程序结束后关闭
+func (d *Debugger) Events() <-chan Event {
+	return d.events
+}
+
+//SetBreakpoints 用lines替换所有断点
+func (d *Debugger) SetBreakpoints(lines []int) []Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints = map[int]bool{}
+	result := []Breakpoint{}
+	for _, line := range lines {
+		d.breakpoints[line] = true
+		result = append(result, Breakpoint{Line: line, Verified: d.lines[line]})
+	}
+	return result
+}
+
+//Start 在新的goroutine中执行程序，stopOnEntry为true时在第一个语句之前暂停
+func (d *Debugger) Start(stopOnEntry bool) {
+	if stopOnEntry {
+		d.mode = modeEntry
+	}
+	d.env.SetHook(d)
+	go func() {
+		result := evaluator.EvalContext(context.Background(), d.program, d.env, evaluator.Limits{})
+		d.env.SetHook(nil)
+		d.events <- Event{Kind: Exited, Result: result}
+		close(d.events)
+	}()
+}
+
+//Continue 继续执行到下一个断点
+func (d *Debugger) Continue() error {
+	return d.command(modeRun)
+}
+
+//StepIn 执行到下一行，进入函数调用
+func (d *Debugger) StepIn() error {
+	return d.command(modeStepIn)
+}
+
+//StepOver 执行到当前函数中的下一行，不进入函数调用
+func (d *Debugger) StepOver() error {
+	return d.command(modeStepOver)
+}
+
+//StepOut 执行到返回调用者之后的下一行
+func (d *Debugger) StepOut() error {
+	return d.command(modeStepOut)
+}
+
+func (d *Debugger) command(m mode) error {
+	d.mu.Lock()
+	if !d.paused {
+		d.mu.Unlock()
+		return ErrNotPaused
+	}
+	d.paused = false
+	d.mu.Unlock()
+	d.resume <- m
+	return nil
+}
+
+//Pause 在下一个语句之前暂停
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.paused {
+		d.mode = modePause
+	}
+}
+
+//Terminate 结束程序，程序以ErrTerminated对应的错误结束
+func (d *Debugger) Terminate() {
+	d.mu.Lock()
+	d.terminated = true
+	paused := d.paused
+	d.paused = false
+	d.mu.Unlock()
+	if paused {
+		d.resume <- modeRun
+	}
+}
+
+//Before 实现object.Hook，在语句之前检查是否需要暂停
+func (d *Debugger) Before(node ast.Node, env *object.Environment) *object.Error {
+	d.mu.Lock()
+	if d.evaluating {
+		d.mu.Unlock()
+		return nil
+	}
+	if d.terminated {
+		d.mu.Unlock()
+		return &object.Error{Kind: object.GENERIC_ERROR, Message: ErrTerminated.Error()}
+	}
+	if !isStatement(node) {
+		d.mu.Unlock()
+		return nil
+	}
+	depth := 0
+	frame := Frame{Name: "main", Pos: node.Pos(), env: env}
+	if f := env.Frame(); f != nil {
+		depth = f.Depth
+		frame.Name = f.String()
+	}
+	if depth < len(d.frames) {
+		d.frames = d.frames[:depth+1]
+		d.frames[depth] = frame
+	} else {
+		d.frames = append(d.frames, frame)
+	}
+	line := node.Pos().Line
+	newLine := line != d.lastLine || depth != d.lastDepth
+	d.lastLine, d.lastDepth = line, depth
+
+	reason := ""
+	switch {
+	case d.mode == modeEntry:
+		reason = ReasonEntry
+	case d.mode == modePause:
+		reason = ReasonPause
+	case !newLine:
+	case d.mode == modeStepIn,
+		d.mode == modeStepOver && depth <= d.stepDepth,
+		d.mode == modeStepOut && depth < d.stepDepth:
+		reason = ReasonStep
+	case d.breakpoints[line]:
+		reason = ReasonBreakpoint
+	}
+	if reason == "" {
+		d.mu.Unlock()
+		return nil
+	}
+	d.mode = modeRun
+	d.paused = true
+	d.mu.Unlock()
+
+	d.events <- Event{Kind: Stopped, Reason: reason, Pos: node.Pos()}
+	m := <-d.resume
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.terminated {
+		return &object.Error{Kind: object.GENERIC_ERROR, Message: ErrTerminated.Error()}
+	}
+	d.mode = m
+	d.stepDepth = depth
+	return nil
+}
+
+//StackTrace 暂停时的调用栈，最内层的调用在前
+func (d *Debugger) StackTrace() ([]Frame, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.paused {
+		return nil, ErrNotPaused
+	}
+	frames := make([]Frame, 0, len(d.frames))
+	for i := len(d.frames) - 1; i >= 0; i-- {
+		frames = append(frames, d.frames[i])
+	}
+	return frames, nil
+}
+
+//frameEnv 第i帧（最内层为0）的环境
+func (d *Debugger) frameEnv(i int) (*object.Environment, error) {
+	if !d.paused {
+		return nil, ErrNotPaused
+	}
+	if i < 0 || i >= len(d.frames) {
+		return nil, fmt.Errorf("invalid frame %d", i)
+	}
+	return d.frames[len(d.frames)-1-i].env, nil
+}
+
+//Scopes 第i帧（最内层为0）中从内到外的各层环境及其中的变量
+func (d *Debugger) Scopes(i int) ([]Scope, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	env, err := d.frameEnv(i)
+	if err != nil {
+		return nil, err
+	}
+	scopes := []Scope{}
+	for e := env; e != nil; e = e.Outer() {
+		scope := Scope{Name: "Closure"}
+		switch {
+		case e.Outer() == nil:
+			scope.Name = "Globals"
+		case e == env:
+			scope.Name = "Locals"
+		}
+		for _, name := range e.Names() {
+			value, _ := e.Get(name)
+			scope.Variables = append(scope.Variables, Variable{Name: name, Value: value})
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, nil
+}
+
+//Evaluate 在第i帧（最内层为0）的环境中求值表达式，表达式中的let和赋值会修改该环境
+func (d *Debugger) Evaluate(expression string, i int) (object.Object, error) {
+	d.mu.Lock()
+	env, err := d.frameEnv(i)
+	if err != nil {
+		d.mu.Unlock()
+		return nil, err
+	}
+	d.evaluating = true
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		d.evaluating = false
+		d.mu.Unlock()
+	}()
+
+	p := parser.New(lexer.New(expression))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		messages := []string{}
+		for _, diag := range p.Diagnostics() {
+			messages = append(messages, diag.Message)
+		}
+		return nil, fmt.Errorf("%s", strings.Join(messages, "; "))
+	}
+	result := evaluator.EvalContext(context.Background(), program, env, evaluator.Limits{})
+	if result == nil {
+		result = evaluator.NULL
+	}
+	return result, nil
+}
+
+//isStatement 暂停的位置：语句块中的语句，语句块本身和程序除外
+func isStatement(node ast.Node) bool {
+	switch node.(type) {
+	case *ast.Program, *ast.BlockStatement:
+		return false
+	case ast.Statement:
+		return !reflect.ValueOf(node).IsNil()
+	}
+	return false
+}
@@ -0,0 +1,218 @@
+package debugger
+
+import (
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"reflect"
+	"testing"
+)
+
+const source = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let x = 1;
+let y = add(x, 2);
+let z = y * 2;
+z`
+
+func start(t *testing.T, input string, breakpoints []int, stopOnEntry bool) *Debugger {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		t.Fatalf("parser errors: %v", p.Diagnostics())
+	}
+	d := New(program, nil)
+	d.SetBreakpoints(breakpoints)
+	d.Start(stopOnEntry)
+	return d
+}
+
+//expectStop 等待暂停事件，检查原因和行号
+func expectStop(t *testing.T, d *Debugger, reason string, line int) {
+	t.Helper()
+	event := <-d.Events()
+	if event.Kind != Stopped || event.Reason != reason || event.Pos.Line != line {
+		t.Fatalf("wrong event. want=stopped %s at line %d, got=%+v", reason, line, event)
+	}
+}
+
+func expectExit(t *testing.T, d *Debugger) object.Object {
+	t.Helper()
+	event := <-d.Events()
+	if event.Kind != Exited {
+		t.Fatalf("expected exited event. got=%+v", event)
+	}
+	if _, ok := <-d.Events(); ok {
+		t.Fatalf("expected events to be closed")
+	}
+	return event.Result
+}
+
+func TestBreakpoints(t *testing.T) {
+	d := start(t, source, []int{2, 7}, false)
+	expectStop(t, d, ReasonBreakpoint, 2)
+	if err := d.Continue(); err != nil {
+		t.Fatal(err)
+	}
+	expectStop(t, d, ReasonBreakpoint, 7)
+	d.Continue()
+	result := expectExit(t, d)
+	if i, ok := result.(*object.Integer); !ok || i.Value != 6 {
+		t.Errorf("wrong result. got=%v", result)
+	}
+	if err := d.Continue(); err != ErrNotPaused {
+		t.Errorf("expected ErrNotPaused. got=%v", err)
+	}
+}
+
+func TestUnboundedRecursion(t *testing.T) {
+	d := start(t, "let f = fn() { f() }; f()", nil, false)
+	result := expectExit(t, d)
+	err, ok := result.(*object.Error)
+	if !ok || err.Kind != object.CALL_DEPTH_ERROR {
+		t.Fatalf("expected CallDepthError. got=%v", result)
+	}
+}
+
+func TestVerifiedBreakpoints(t *testing.T) {
+	p := parser.New(lexer.New(source))
+	d := New(p.ParseProgram(), nil)
+	got := d.SetBreakpoints([]int{1, 4, 6})
+	expected := []Breakpoint{{1, true}, {4, false}, {6, true}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong breakpoints. want=%v, got=%v", expected, got)
+	}
+}
+
+func TestStepping(t *testing.T) {
+	tests := []struct {
+		step  func(d *Debugger) error
+		line  int
+		frame string
+	}{
+		{(*Debugger).StepOver, 5, "main"},
+		{(*Debugger).StepOver, 6, "main"},
+		{(*Debugger).StepIn, 2, "add(1, 2)"},
+		{(*Debugger).StepOver, 3, "add(1, 2)"},
+		{(*Debugger).StepOut, 7, "main"},
+		{(*Debugger).StepIn, 8, "main"},
+	}
+	d := start(t, source, nil, true)
+	expectStop(t, d, ReasonEntry, 1)
+	for _, tt := range tests {
+		if err := tt.step(d); err != nil {
+			t.Fatal(err)
+		}
+		expectStop(t, d, ReasonStep, tt.line)
+		frames, err := d.StackTrace()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frames[0].Name != tt.frame || frames[0].Pos.Line != tt.line {
+			t.Errorf("wrong top frame. want=%s at line %d, got=%+v", tt.frame, tt.line, frames[0])
+		}
+	}
+	d.StepOver()
+	expectExit(t, d)
+}
+
+func TestScopesAndEvaluate(t *testing.T) {
+	input := `let base = 10;
+let make = fn(n) {
+  fn(m) {
+    let total = base + n + m;
+    total
+  }
+};
+make(1)(2);`
+	d := start(t, input, []int{5}, false)
+	expectStop(t, d, ReasonBreakpoint, 5)
+
+	frames, err := d.StackTrace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 || frames[0].Name != "<anonymous>(2)" || frames[1].Name != "main" || frames[1].Pos.Line != 8 {
+		t.Fatalf("wrong frames. got=%+v", frames)
+	}
+
+	scopes, err := d.Scopes(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := [][]string{}
+	for _, scope := range scopes {
+		vars := []string{scope.Name}
+		for _, v := range scope.Variables {
+			vars = append(vars, v.Name+"="+v.Value.Inspect())
+		}
+		names = append(names, vars)
+	}
+	expected := [][]string{
+		{"Locals", "m=2", "total=13"},
+		{"Closure", "n=1"},
+		{"Globals", "base=10", "make=" + mustGet(t, d, "make").Inspect()},
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("wrong scopes.\nwant=%v\ngot=%v", expected, names)
+	}
+
+	tests := []struct {
+		expression string
+		frame      int
+		expected   string
+	}{
+		{"total * 2", 0, "26"},
+		{"n + m", 0, "3"},
+		{"base", 1, "10"},
+		{"m", 1, "ERROR: identifier not found: m"},
+		{"let base = 20; base", 1, "20"},
+	}
+	for _, tt := range tests {
+		result, err := d.Evaluate(tt.expression, tt.frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, got=%s", tt.expression, tt.expected, result.Inspect())
+		}
+	}
+	if _, err := d.Evaluate("let = 1", 0); err == nil {
+		t.Errorf("expected parse error")
+	}
+	if _, err := d.Evaluate("1", 2); err == nil {
+		t.Errorf("expected invalid frame error")
+	}
+
+	d.Continue()
+	result := expectExit(t, d)
+	if result.Inspect() != "13" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}
+
+func mustGet(t *testing.T, d *Debugger, name string) object.Object {
+	value, ok := d.env.Get(name)
+	if !ok {
+		t.Fatalf("%s not found", name)
+	}
+	return value
+}
+
+func TestPauseAndTerminate(t *testing.T) {
+	d := start(t, "let i = 0;\nfor (;;) {\n  i = i + 1;\n}", nil, false)
+	d.Pause()
+	//暂停的位置取决于调用Pause时程序执行到哪里
+	if event := <-d.Events(); event.Kind != Stopped || event.Reason != ReasonPause {
+		t.Fatalf("expected stopped pause event. got=%+v", event)
+	}
+	d.Terminate()
+	result := expectExit(t, d)
+	err, ok := result.(*object.Error)
+	if !ok || err.Message != ErrTerminated.Error() {
+		t.Errorf("expected terminated error. got=%v", result)
+	}
+}
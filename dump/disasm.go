@@ -0,0 +1,195 @@
+package dump
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/object"
+	"strings"
+)
+
+//Listing 编译结果的反汇编清单
+type Listing struct {
+	Globals   []string    `json:"globals"`
+	Constants []Constant  `json:"constants"`
+	Main      *Function   `json:"main"`
+	Functions []*Function `json:"functions"`
+}
+
+//Constant 常量池中的一项
+type Constant struct {
+	Index int    `json:"index"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+//Function 一个函数的指令，主程序的Constant为-1
+type Function struct {
+	Constant      int           `json:"constant"`
+	Name          string        `json:"name"`
+	NumParameters int           `json:"numParameters"`
+	NumLocals     int           `json:"numLocals"`
+	Locals        []string      `json:"locals"`
+	Free          []string      `json:"free"`
+	Instructions  []Instruction `json:"instructions"`
+}
+
+//Instruction 一条指令及其注释：常量的值、变量名或跳转目标，以及对应的源码位置
+type Instruction struct {
+	Offset   int    `json:"offset"`
+	Op       string `json:"op"`
+	Operands []int  `json:"operands"`
+	Comment  string `json:"comment,omitempty"`
+	Pos      string `json:"pos,omitempty"`
+}
+
+//Disassemble 反汇编主程序以及常量池中的全部函数
+func Disassemble(bc *compiler.Bytecode) (*Listing, error) {
+	listing := &Listing{Globals: bc.Globals, Constants: []Constant{}, Functions: []*Function{}}
+	if listing.Globals == nil {
+		listing.Globals = []string{}
+	}
+	for i, c := range bc.Constants {
+		listing.Constants = append(listing.Constants, Constant{Index: i, Type: string(c.Type()), Value: c.Inspect()})
+	}
+	main := &object.CompiledFunction{Instructions: bc.Instructions, Positions: bc.Positions}
+	var err error
+	if listing.Main, err = disassembleFunction(bc, -1, main); err != nil {
+		return nil, err
+	}
+	for i, c := range bc.Constants {
+		fn, ok := c.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+		f, err := disassembleFunction(bc, i, fn)
+		if err != nil {
+			return nil, err
+		}
+		listing.Functions = append(listing.Functions, f)
+	}
+	return listing, nil
+}
+
+func disassembleFunction(bc *compiler.Bytecode, index int, fn *object.CompiledFunction) (*Function, error) {
+	f := &Function{
+		Constant:      index,
+		Name:          fn.Name,
+		NumParameters: fn.NumParameters,
+		NumLocals:     fn.NumLocals,
+		Locals:        fn.LocalNames,
+		Free:          fn.FreeNames,
+		Instructions:  []Instruction{},
+	}
+	if f.Locals == nil {
+		f.Locals = []string{}
+	}
+	if f.Free == nil {
+		f.Free = []string{}
+	}
+	ins := fn.Instructions
+	for i := 0; i < len(ins); {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s at %04d", err, i)
+		}
+		operands, read := code.ReadOperands(def, ins[i+1:])
+		instruction := Instruction{
+			Offset:   i,
+			Op:       def.Name,
+			Operands: operands,
+			Comment:  comment(bc, fn, code.Opcode(ins[i]), operands),
+		}
+		if pos := fn.Positions.Lookup(i); pos.IsValid() {
+			instruction.Pos = pos.String()
+		}
+		f.Instructions = append(f.Instructions, instruction)
+		i += 1 + read
+	}
+	return f, nil
+}
+
+//comment 指令的注释
+func comment(bc *compiler.Bytecode, fn *object.CompiledFunction, op code.Opcode, operands []int) string {
+	switch op {
+	case code.OpConstant, code.OpClosure:
+		if operands[0] < len(bc.Constants) {
+			return bc.Constants[operands[0]].Inspect()
+		}
+	case code.OpGetGlobal, code.OpSetGlobal:
+		return name(bc.Globals, operands[0])
+	case code.OpGetLocal, code.OpSetLocal, code.OpCaptureLocal:
+		return name(fn.LocalNames, operands[0])
+	case code.OpGetFree, code.OpCaptureFree:
+		return name(fn.FreeNames, operands[0])
+	case code.OpGetBoundLocal:
+		return fmt.Sprintf("%s, bound -> %04d", name(fn.LocalNames, operands[0]), operands[1])
+	case code.OpGetBoundFree:
+		return fmt.Sprintf("%s, bound -> %04d", name(fn.FreeNames, operands[0]), operands[1])
+	case code.OpGetBuiltin:
+		return name(evaluator.BuiltinNames, operands[0])
+	case code.OpJump, code.OpJumpNotTruthy, code.OpIterNext, code.OpSetupTry:
+		return fmt.Sprintf("-> %04d", operands[0])
+	}
+	return ""
+}
+
+func name(names []string, index int) string {
+	if index < len(names) {
+		return names[index]
+	}
+	return "?"
+}
+
+//WriteDisassembly 输出反汇编清单，每条指令后附带注释和源码位置
+func WriteDisassembly(w io.Writer, bc *compiler.Bytecode, asJSON bool) error {
+	listing, err := Disassemble(bc)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return writeJSON(w, listing)
+	}
+	var out bytes.Buffer
+	out.WriteString("== constants ==\n")
+	for _, c := range listing.Constants {
+		fmt.Fprintf(&out, "%4d %-17s %s\n", c.Index, c.Type, c.Value)
+	}
+	out.WriteString("== globals ==\n")
+	for i, g := range listing.Globals {
+		fmt.Fprintf(&out, "%4d %s\n", i, g)
+	}
+	out.WriteString("== main ==\n")
+	writeInstructions(&out, listing.Main)
+	for _, f := range listing.Functions {
+		name := f.Name
+		if name == "" {
+			name = "<anonymous>"
+		}
+		fmt.Fprintf(&out, "== constant %d: fn %s (params %d, locals [%s], free [%s]) ==\n",
+			f.Constant, name, f.NumParameters, strings.Join(f.Locals, ", "), strings.Join(f.Free, ", "))
+		writeInstructions(&out, f)
+	}
+	_, err = w.Write(out.Bytes())
+	return err
+}
+
+func writeInstructions(out *bytes.Buffer, f *Function) {
+	for _, ins := range f.Instructions {
+		text := fmt.Sprintf("%04d %s", ins.Offset, ins.Op)
+		for _, o := range ins.Operands {
+			text += fmt.Sprintf(" %d", o)
+		}
+		line := fmt.Sprintf("%-28s", text)
+		if ins.Comment != "" {
+			line += " ; " + ins.Comment
+		}
+		if ins.Pos != "" {
+			line = fmt.Sprintf("%-56s %s", line, ins.Pos)
+		}
+		out.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+}
@@ -0,0 +1,128 @@
+package dump
+
+import (
+	"bytes"
+	"encoding/json"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/lexer"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+// dump/dump_test.go
+
+func TestWriteTokens(t *testing.T) {
+	tokens := Tokens(lexer.New("let x = 5;"))
+	var out bytes.Buffer
+	if err := WriteTokens(&out, tokens, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := `1:1        LET        "let"
+1:5        IDENT      "x"
+1:7        =          "="
+1:9        INT        "5"
+1:10       ;          ";"
+1:11       EOF        ""
+`
+	if out.String() != expected {
+		t.Errorf("wrong output.\nwant=%q\ngot=%q", expected, out.String())
+	}
+
+	out.Reset()
+	if err := WriteTokens(&out, tokens, true); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []Token
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid json: %s", err)
+	}
+	if len(decoded) != 6 || decoded[1] != (Token{Type: "IDENT", Literal: "x", Pos: "1:5", End: "1:6"}) {
+		t.Errorf("wrong tokens. got=%+v", decoded)
+	}
+}
+
+func TestWriteTree(t *testing.T) {
+	program := parser.New(lexer.New(`let f = fn(a, b = 1) { a }; {"k": f(2)}[true]`)).ParseProgram()
+	var out bytes.Buffer
+	if err := WriteTree(&out, program, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := `Program 1:1
+  Statements[0]: LetStatement 1:1
+    Name: Identifier 1:5 Value="f"
+    Value: FunctionLiteral 1:9 Name="f"
+      Parameters[0]: Identifier 1:12 Value="a"
+      Parameters[1]: Identifier 1:15 Value="b"
+      Defaults[0]: Pair Key="b"
+        Value: IntegerLiteral 1:19 Value=1
+      Body: BlockStatement 1:22
+        Statements[0]: ExpressionStatement 1:24
+          Expression: Identifier 1:24 Value="a"
+  Statements[1]: ExpressionStatement 1:29
+    Expression: IndexExpression 1:29
+      Left: HashLiteral 1:29
+        Pairs[0]: Pair
+          Key: StringLiteral 1:30 Value="k"
+          Value: CallExpression 1:35
+            Function: Identifier 1:35 Value="f"
+            Arguments[0]: IntegerLiteral 1:37 Value=2
+      Index: Boolean 1:41 Value=true
+`
+	if out.String() != expected {
+		t.Errorf("wrong output.\nwant=%s\ngot=%s", expected, out.String())
+	}
+
+	out.Reset()
+	if err := WriteTree(&out, program, true); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ast.DecodeProgramJSON(out.Bytes())
+	if err != nil {
+		t.Fatalf("invalid json: %s", err)
+	}
+	if decoded.String() != program.String() {
+		t.Errorf("wrong program. want=%q, got=%q", program.String(), decoded.String())
+	}
+}
+
+func TestWriteDisassembly(t *testing.T) {
+	program := parser.New(lexer.New("let x = 1; let f = fn(a) { a + x }; f(len([]))")).ParseProgram()
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := WriteDisassembly(&out, comp.Bytecode(), false); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"0000 OpConstant 0            ; 1                         1:9",
+		"0003 OpSetGlobal 0           ; x                         1:1",
+		"OpGetBuiltin 2          ; len",
+		"== constant 1: fn f (params 1, locals [a], free []) ==",
+		"0000 OpGetLocal 0            ; a                         1:28",
+		"0002 OpGetGlobal 0           ; x                         1:32",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("listing does not contain %q.\n%s", line, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := WriteDisassembly(&out, comp.Bytecode(), true); err != nil {
+		t.Fatal(err)
+	}
+	var listing Listing
+	if err := json.Unmarshal(out.Bytes(), &listing); err != nil {
+		t.Fatalf("invalid json: %s", err)
+	}
+	if len(listing.Functions) != 1 || listing.Functions[0].Name != "f" {
+		t.Fatalf("wrong functions. got=%+v", listing.Functions)
+	}
+	first := listing.Main.Instructions[0]
+	if first.Op != "OpConstant" || first.Comment != "1" || first.Pos != "1:9" {
+		t.Errorf("wrong instruction. got=%+v", first)
+	}
+}
@@ -0,0 +1,52 @@
+package dump
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"monkey/lexer"
+	"monkey/token"
+)
+
+//Token JSON输出中的一个token
+type Token struct {
+	Type    token.TokenType `json:"type"`
+	Literal string          `json:"literal"`
+	Pos     string          `json:"pos"`
+	End     string          `json:"end"`
+}
+
+//Tokens 读取lexer中的全部token，包括最后的EOF
+func Tokens(l *lexer.Lexer) []token.Token {
+	tokens := []token.Token{}
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			return tokens
+		}
+	}
+}
+
+//WriteTokens 输出token流，每行一个token：位置、类型、字面量
+func WriteTokens(w io.Writer, tokens []token.Token, asJSON bool) error {
+	if asJSON {
+		out := make([]Token, 0, len(tokens))
+		for _, tok := range tokens {
+			out = append(out, Token{Type: tok.Type, Literal: tok.Literal, Pos: tok.Pos.String(), End: tok.End.String()})
+		}
+		return writeJSON(w, out)
+	}
+	for _, tok := range tokens {
+		if _, err := fmt.Fprintf(w, "%-10s %-10s %q\n", tok.Pos, tok.Type, tok.Literal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
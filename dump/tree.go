@@ -0,0 +1,169 @@
+package dump
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"monkey/ast"
+	"monkey/token"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+//Node 语法树节点的通用表示，字段按结构体中的定义顺序排列
+type Node struct {
+	Type   string
+	Pos    token.Position
+	Fields []Field
+}
+
+//Field 节点的字段，Value为字符串、数字、布尔值、*Node或[]interface{}
+type Field struct {
+	Name  string
+	Value interface{}
+}
+
+var (
+	tokenType = reflect.TypeOf(token.Token{})
+	bigType   = reflect.TypeOf(&big.Int{})
+	nodeType  = reflect.TypeOf((*ast.Node)(nil)).Elem()
+)
+
+//Tree 通过反射将语法树转换为通用的节点，新增的节点类型不需要修改这里
+func Tree(node ast.Node) *Node {
+	v := reflect.ValueOf(node)
+	if node == nil || v.IsNil() {
+		return nil
+	}
+	elem := v.Elem()
+	n := &Node{Type: elem.Type().Name(), Pos: node.Pos()}
+	for i := 0; i < elem.NumField(); i++ {
+		f := elem.Type().Field(i)
+		if f.Type == tokenType || f.PkgPath != "" {
+			continue
+		}
+		if value := treeValue(elem.Field(i)); value != nil {
+			n.Fields = append(n.Fields, Field{Name: f.Name, Value: value})
+		}
+	}
+	return n
+}
+
+//treeValue 转换字段的值，空的节点、切片和映射返回nil
+func treeValue(v reflect.Value) interface{} {
+	if v.Type() == bigType {
+		if v.IsNil() {
+			return nil
+		}
+		return v.Interface().(*big.Int).String()
+	}
+	if v.Type().Implements(nodeType) || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		return Tree(v.Interface().(ast.Node))
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return nil
+		}
+		items := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, treeValue(v.Index(i)))
+		}
+		return items
+	case reflect.Map:
+		if v.Len() == 0 {
+			return nil
+		}
+		//映射的键值对按键排序，保证输出是确定的
+		pairs := make([]interface{}, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			pairs = append(pairs, &Node{Type: "Pair", Fields: []Field{
+				{Name: "Key", Value: treeValue(key)},
+				{Name: "Value", Value: treeValue(v.MapIndex(key))},
+			}})
+		}
+		sort.Slice(pairs, func(i, j int) bool {
+			return pairKeyLess(pairs[i].(*Node).Fields[0].Value, pairs[j].(*Node).Fields[0].Value)
+		})
+		return pairs
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float64:
+		return v.Interface()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+//pairKeyLess 节点按在源码中的位置排序，其他的值按字符串形式排序
+func pairKeyLess(a, b interface{}) bool {
+	na, okA := a.(*Node)
+	nb, okB := b.(*Node)
+	if okA && okB {
+		return na.Pos.Offset < nb.Pos.Offset
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+func (n *Node) String() string {
+	if !n.Pos.IsValid() {
+		return n.Type
+	}
+	return n.Type + " " + n.Pos.String()
+}
+
+//WriteTree 以缩进的树形结构输出语法树，每行一个节点：字段名、节点类型、位置以及标量字段
+//JSON格式与ast.EncodeJSON相同，可以用ast.DecodeJSON读回
+func WriteTree(w io.Writer, node ast.Node, asJSON bool) error {
+	var out bytes.Buffer
+	if asJSON {
+		data, err := ast.EncodeJSON(node)
+		if err != nil {
+			return err
+		}
+		if err := json.Indent(&out, data, "", "  "); err != nil {
+			return err
+		}
+		out.WriteString("\n")
+	} else {
+		writeNode(&out, "", Tree(node), 0)
+	}
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+func writeNode(out *bytes.Buffer, label string, n *Node, depth int) {
+	out.WriteString(strings.Repeat("  ", depth))
+	out.WriteString(label)
+	out.WriteString(n.String())
+	var children []Field
+	for _, f := range n.Fields {
+		switch value := f.Value.(type) {
+		case *Node, []interface{}:
+			children = append(children, f)
+		case string:
+			fmt.Fprintf(out, " %s=%q", f.Name, value)
+		default:
+			fmt.Fprintf(out, " %s=%v", f.Name, value)
+		}
+	}
+	out.WriteString("\n")
+	for _, f := range children {
+		switch value := f.Value.(type) {
+		case *Node:
+			writeNode(out, f.Name+": ", value, depth+1)
+		case []interface{}:
+			for i, item := range value {
+				label := fmt.Sprintf("%s[%d]: ", f.Name, i)
+				if child, ok := item.(*Node); ok {
+					writeNode(out, label, child, depth+1)
+				} else {
+					fmt.Fprintf(out, "%s%s%v\n", strings.Repeat("  ", depth+1), label, item)
+				}
+			}
+		}
+	}
+}
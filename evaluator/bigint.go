@@ -0,0 +1,95 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+	"monkey/object"
+)
+
+//isInteger 是否是整数（Integer或BigInt）
+func isInteger(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Integer, *object.BigInt:
+		return true
+	}
+	return false
+}
+
+//toBigInt 将整数转换为big.Int，返回的值可以直接修改
+func toBigInt(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value)
+	case *object.BigInt:
+		return new(big.Int).Set(obj.Value)
+	}
+	return new(big.Int)
+}
+
+//newInteger 能用int64表示时返回Integer，否则返回BigInt
+func newInteger(value *big.Int) object.Object {
+	if value.IsInt64() {
+		return &object.Integer{Value: value.Int64()}
+	}
+	return &object.BigInt{Value: value}
+}
+
+//evalBigIntInfixExpression 任意精度的整数运算
+func evalBigIntInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	leftVal := toBigInt(left)
+	rightVal := toBigInt(right)
+	switch operator {
+	case "+":
+		return newInteger(leftVal.Add(leftVal, rightVal))
+	case "-":
+		return newInteger(leftVal.Sub(leftVal, rightVal))
+	case "*":
+		return newInteger(leftVal.Mul(leftVal, rightVal))
+	case "/":
+		if rightVal.Sign() == 0 {
+			return newArithmeticError("division by zero")
+		}
+		//Quo与int64的除法一样向零取整
+		return newInteger(leftVal.Quo(leftVal, rightVal))
+	case "%":
+		if rightVal.Sign() == 0 {
+			return newArithmeticError("modulo by zero")
+		}
+		//Rem的结果与被除数同号，与int64的取模一致
+		return newInteger(leftVal.Rem(leftVal, rightVal))
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	default:
+		return newTypeError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+//addOverflows a+b是否超出int64范围
+func addOverflows(a, b int64) bool {
+	sum := a + b
+	return (b > 0 && sum < a) || (b < 0 && sum > a)
+}
+
+//subOverflows a-b是否超出int64范围
+func subOverflows(a, b int64) bool {
+	diff := a - b
+	return (b > 0 && diff > a) || (b < 0 && diff < a)
+}
+
+//mulOverflows a*b是否超出int64范围
+func mulOverflows(a, b int64) bool {
+	if a == 0 || b == 0 {
+		return false
+	}
+	if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return true
+	}
+	product := a * b
+	return product/b != a
+}
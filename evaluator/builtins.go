@@ -2,13 +2,42 @@ package evaluator
 
 import (
 	"fmt"
+	"io"
 	"monkey/object"
+	"os"
+	"sort"
 )
 
+//BuiltinNames 按名称排序的内置函数名，编译后的代码通过下标引用内置函数
+var BuiltinNames = builtinNames()
+
+func builtinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//puts 每个参数输出一行
+func puts(out io.Writer, args []object.Object) object.Object {
+	for _, arg := range args {
+		fmt.Fprintln(out, arg.Inspect())
+	}
+	return NULL
+}
+
+//GetBuiltin 按名称查找内置函数
+func GetBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
 var builtins = map[string]*object.Builtin{
 	"len": &object.Builtin{Fn: func(args ...object.Object) object.Object {
 		if len(args) != 1 {
-			return newError("wrong number of arguments. got=%d, want=1",
+			return newArgumentError("wrong number of arguments. got=%d, want=1",
 				len(args))
 		}
 
@@ -18,28 +47,27 @@ var builtins = map[string]*object.Builtin{
 		case *object.String:
 			return &object.Integer{Value: int64(len(arg.Value))}
 		default:
-			return newError("argument to `len` not supported, got %s",
+			return newTypeError("argument to `len` not supported, got %s",
 				args[0].Type())
 		}
 	},
 	},
 	"puts": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
-			for _, arg := range args {
-				fmt.Println(arg.Inspect())
-			}
-
-			return NULL
+			return puts(os.Stdout, args)
+		},
+		EnvFn: func(env *object.Environment, args ...object.Object) object.Object {
+			return puts(env.Output().Stdout, args)
 		},
 	},
 	"first": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
+				return newArgumentError("wrong number of arguments. got=%d, want=1",
 					len(args))
 			}
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `first` must be ARRAY, got %s",
+				return newTypeError("argument to `first` must be ARRAY, got %s",
 					args[0].Type())
 			}
 
@@ -54,11 +82,11 @@ var builtins = map[string]*object.Builtin{
 	"last": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
+				return newArgumentError("wrong number of arguments. got=%d, want=1",
 					len(args))
 			}
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `last` must be ARRAY, got %s",
+				return newTypeError("argument to `last` must be ARRAY, got %s",
 					args[0].Type())
 			}
 
@@ -74,11 +102,11 @@ var builtins = map[string]*object.Builtin{
 	"rest": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
+				return newArgumentError("wrong number of arguments. got=%d, want=1",
 					len(args))
 			}
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `rest` must be ARRAY, got %s",
+				return newTypeError("argument to `rest` must be ARRAY, got %s",
 					args[0].Type())
 			}
 
@@ -96,11 +124,11 @@ var builtins = map[string]*object.Builtin{
 	"push": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=2",
+				return newArgumentError("wrong number of arguments. got=%d, want=2",
 					len(args))
 			}
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `push` must be ARRAY, got %s",
+				return newTypeError("argument to `push` must be ARRAY, got %s",
 					args[0].Type())
 			}
 
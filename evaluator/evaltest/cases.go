@@ -0,0 +1,361 @@
+package evaltest
+
+import "monkey/object"
+
+// evaluator/evaltest/cases.go
+//
+//用例按求值器的测试函数分组，新增的分组需要加入All
+
+var HashIndexExpressions = []Case{
+	{`{"foo": 5}["foo"]`, 5},
+	{`{"foo": 5}["bar"]`, nil},
+	{`let key = "foo"; {"foo": 5}[key]`, 5},
+	{`{}["foo"]`, nil},
+	{`{5: 5}[5]`, 5},
+	{`{true: 5}[true]`, 5},
+	{`{false: 5}[false]`, 5},
+}
+
+var HashLiterals = []Case{
+	{`let two = "two";
+    {
+        "one": 10 - 9,
+        two: 1 + 1,
+        "thr" + "ee": 6 / 2,
+        4: 4,
+        true: 5,
+        false: 6
+    }`, Value{object.HASH_OBJ, "{false: 6, true: 5, 4: 4, one: 1, three: 3, two: 2}"}},
+}
+
+var ArrayIndexExpression = []Case{
+	{"[1, 2, 3][0]", 1},
+	{"[1, 2, 3][1]", 2},
+	{"[1, 2, 3][2]", 3},
+	{"let i = 0; [1][i];", 1},
+	{"[1, 2, 3][1 + 1];", 3},
+	{"let myArray = [1, 2, 3]; myArray[2];", 3},
+	{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+	{"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]", 2},
+	{"[1, 2, 3][3]", nil},
+	{"[1, 2, 3][-1]", nil},
+}
+
+var ArrayLiterals = []Case{
+	{"[1, 2 * 2 , 3 + 3]", Value{object.ARRAY_OBJ, "[1, 4, 6]"}},
+}
+
+var BuiltinFunctions = []Case{
+	{`len("")`, 0},
+	{`len("four")`, 4},
+	{`len("hello world")`, 11},
+	{`len(1)`, Error("argument to `len` not supported, got INTEGER")},
+	{`len("one", "two")`, Error("wrong number of arguments. got=2, want=1")},
+}
+
+var ForStatements = []Case{
+	{"let sum = 0; for (let i = 0; i < 5; i = i + 1) { sum = sum + i; }; sum", 10},
+	{"let i = 0; for (; i < 3; ) { i = i + 1 }; i", 3},
+	{"for (let i = 0; i < 3; i = i + 1) { }", nil},
+	{"let f = fn() { for (;;) { return 7; } }; f()", 7},
+	{"let sum = 0; for (x in [1, 2, 3]) { sum = sum + x; }; sum", 6},
+	{`let s = ""; for (c in "abc") { s = c + s; }; s`, "cba"},
+	{`let s = ""; for (k in {"b": 1, "a": 2}) { s = s + k; }; s`, "ab"},
+	{`let s = []; for (k in {10.5: 1, 9.5: 2, 2: 3, 99999999999999999999: 4, -1: 5}) { s = push(s, k) }; s`,
+		Value{object.ARRAY_OBJ, "[-1, 2, 9.5, 10.5, 99999999999999999999]"}},
+	{"for (x in 1) { }", Error("not iterable: INTEGER")},
+	{"for (let i = 0; i < 3; i = i + 1) { true + 1; }", Error("type mismatch: BOOLEAN + INTEGER")},
+	{"let n = 0; for (let i = 0; i < 100000; i = i + 1) { n = n + 1 }; n", 100000},
+}
+
+var LoopControl = []Case{
+	{"let n = 0; for (let i = 0; i < 10; i = i + 1) { if (i == 5) { break; } n = n + 1; }; n", 5},
+	{"let n = 0; for (let i = 0; i < 10; i = i + 1) { if (i < 8) { continue; } n = n + 1; }; n", 2},
+	{"let n = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue; } if (x == 4) { break; } n = n + x; }; n", 4},
+	{`
+let n = 0;
+outer: for (let i = 0; i < 3; i = i + 1) {
+	for (let j = 0; j < 3; j = j + 1) {
+		if (j == 1) { continue outer; }
+		if (i == 2) { break outer; }
+		n = n + 1;
+	}
+	n = n + 100;
+}
+n`, 2},
+	{"let f = fn() { for (;;) { for (;;) { return 3; } } }; f()", 3},
+}
+
+var Strings = []Case{
+	{`"Hello" + " "+"World!"`, "Hello World!"},
+	{`"Hello World!"`, "Hello World!"},
+}
+
+var Closures = []Case{
+	{`
+let newAdder = fn(x) {
+  fn(y) { x + y };
+};
+let addTwo = newAdder(2);
+addTwo(2);
+`, 4},
+}
+
+var FunctionApplication = []Case{
+	{"let identity = fn(x) { x; }; identity(5);", 5},
+	{"let identity = fn(x) { return x; }; identity(5);", 5},
+	{"let double = fn(x) { x * 2; }; double(5);", 10},
+	{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+	{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+	{"fn(x) { x; }(5)", 5},
+}
+
+//Recursion 深层递归，虚拟机的值栈和调用帧按需扩大
+var Recursion = []Case{
+	{"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(5000)", 0},
+	{"let f = fn(n) { let g = fn() { n }; if (n == 0) { g() } else { f(n - 1) + g() } }; f(3000)", 4501500},
+}
+
+var LetStatements = []Case{
+	{"let a = 5; a;", 5},
+	{"let a = 5 * 5; a;", 25},
+	{"let a = 5; let b = a; b;", 5},
+	{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+}
+
+var ErrorHandling = []Case{
+	{"5 + true;", Error("type mismatch: INTEGER + BOOLEAN")},
+	{`{"b": 5 + true, "a": -true}`, Error("type mismatch: INTEGER + BOOLEAN")},
+	{"5 + true; 5;", Error("type mismatch: INTEGER + BOOLEAN")},
+	{"-true", Error("unknown operator: -BOOLEAN")},
+	{"true + false;", Error("unknown operator: BOOLEAN + BOOLEAN")},
+	{"5; true + false; 5", Error("unknown operator: BOOLEAN + BOOLEAN")},
+	{"if (10 > 1) { true + false; }", Error("unknown operator: BOOLEAN + BOOLEAN")},
+	{`
+if (10 > 1) {
+  if (10 > 1) {
+    return true + false;
+  }
+
+  return 1;
+}
+`, Error("unknown operator: BOOLEAN + BOOLEAN")},
+	{"foobar", Error("identifier not found: foobar")},
+	{`"Hello" - "World"`, Error("unknown operator: STRING - STRING")},
+	{`{"name": "Monkey"}[fn(x) { x }];`, Error("unusable as hash key: FUNCTION")},
+}
+
+var ErrorStackTrace = Case{`let inner = fn(x) { x + true };
+let outer = fn(y) { inner(y) };
+outer(1)`, Error("type mismatch: INTEGER + BOOLEAN")}
+
+var BuiltinErrorStackTrace = Case{`let f = fn() { let g = fn(a) { first(a) }; g(1) };
+f()`, Error("argument to `first` must be ARRAY, got INTEGER")}
+
+var ThrowKeepsStackTrace = Case{`let f = fn() { throw "boom" };
+let g = fn() { try { f() } catch (e) { throw e } };
+g()`, Error("boom")}
+
+var TryCatch = []Case{
+	{`try { 1 } catch (e) { 2 }`, 1},
+	{`try { first(1) } catch (e) { 2 }`, 2},
+	{`try { throw "boom"; 1 } catch (e) { e["message"] }`, "boom"},
+	{`try { throw 42 } catch (e) { e["value"] }`, 42},
+	{`try { push(1, 2) } catch (e) { e["type"] }`, "TypeError"},
+	{`try { first() } catch (e) { e["type"] }`, "ArgumentError"},
+	{`try { foo } catch (e) { e["type"] + ": " + e["message"] }`, "NameError: identifier not found: foo"},
+	{`try { {"a": 1}[fn(){}] } catch { "caught" }`, "caught"},
+	{`let f = fn() { throw "deep" }; let g = fn() { f() }; try { g() } catch (e) { e["message"] }`, "deep"},
+	{`let n = 0; try { throw "x" } catch (e) { n = 1 } finally { n = n + 10 }; n`, 11},
+	{`let n = 0; try { n = 1 } finally { n = n + 10 }; n`, 11},
+	{`try { throw "x" } finally { 1 }`, Error("x")},
+	{`try { throw "x" } catch (e) { throw e }`, Error("x")},
+	{`let f = fn() { try { return 1 } finally { 2 } }; f()`, 1},
+	{`let f = fn() { try { return 1 } finally { return 2 } }; f()`, 2},
+	{`let n = 0; for (x in [1, 2, 3]) { try { if (x == 2) { throw "skip" } n = n + x } catch { continue } }; n`, 4},
+	{`let e = 5; try { 1 / 0 } catch (e) { 3 }; e`, 5},
+	{`let f = fn() { let e = 5; try { throw 1 } catch (e) { e = 2; e }; e }; f()`, 5},
+	{`let g = try { throw "x" } catch (e) { fn() { e["message"] } }; g()`, "x"},
+	{`let f = fn() { let g = 0; try { throw "y" } catch (e) { g = fn() { e["message"] } }; g() }; f()`, "y"},
+}
+
+var ReturnStatements = []Case{
+	{"return 10;", 10},
+	{"return 10; 9", 10},
+	{"return 2*5; 9;", 10},
+	{"9; return 2*5; 9;", 10},
+	{`
+if (10 >1) {
+	if (10>1){
+		return 10;
+	}
+	return 1;
+}
+`, 10},
+}
+
+var IfElseExpressions = []Case{
+	{"if (true) { 10 }", 10},
+	{"if (false) { 10 }", nil},
+	{"if (1) { 10 }", 10},
+	{"if (1 < 2) { 10 }", 10},
+	{"if (1 > 2) { 10 }", nil},
+	{"if (1 > 2) { 10 } else { 20 }", 20},
+	{"if (1 < 2) { 10 } else { 20 }", 10},
+}
+
+var BangOperator = []Case{
+	{"!true", false},
+	{"!false", true},
+	{"!5", false},
+	{"!!true", true},
+	{"!!false", false},
+	{"!!5", true},
+}
+
+var BooleanExpressions = []Case{
+	{"true", true},
+	{"false", false},
+	{"1 < 2", true},
+	{"1 > 2", false},
+	{"1 < 1", false},
+	{"1 > 1", false},
+	{"1 == 1", true},
+	{"1 != 1", false},
+	{"1 == 2", false},
+	{"1 != 2", true},
+	{"true == true", true},
+	{"false == false", true},
+	{"true == false", false},
+	{"true != false", true},
+	{"false != true", true},
+	{"(1 < 2) == true", true},
+	{"(1 < 2) == false", false},
+	{"(1 > 2) == true", false},
+	{"(1 > 2) == false", true},
+}
+
+var IntegerExpressions = []Case{
+	{"5", 5},
+	{"10", 10},
+	{"-5", -5},
+	{"-10", -10},
+
+	{"5+5+5+5-10", 10},
+	{"2*2*2*2*2", 32},
+	{"-50 + 100 + -50", 0},
+	{"5 * 2 + 10", 20},
+	{"5 + 2 * 10", 25},
+	{"20 + 2 * -10", 0},
+	{"50 / 2 * 2 + 10", 60},
+	{"2 * (5 + 10)", 30},
+	{"3 * 3 * 3 + 10", 37},
+	{"3 * (3 * 3) + 10", 37},
+	{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+}
+
+var FloatExpressions = []Case{
+	{"1.5", 1.5},
+	{"-2.5", -2.5},
+	{"1.5 + 1.5", 3.0},
+	{"1 + 0.5", 1.5},
+	{"0.5 * 4", 2.0},
+	{"200 * 0.15", 30.0},
+	{"7 / 2.0", 3.5},
+	{"7 / 2", 3},
+	{"1e3 - 1", 999.0},
+	{"1 < 1.5", true},
+	{"2.5 > 3", false},
+	{"1 == 1.0", true},
+	{"1.0 != 1", false},
+	{`{1: "one"}[1.0]`, "one"},
+	{"1.5 + true", Error("type mismatch: FLOAT + BOOLEAN")},
+}
+
+var BigIntExpressions = []Case{
+	{"9223372036854775807 + 1", Value{object.BIGINT_OBJ, "9223372036854775808"}},
+	{"-9223372036854775807 - 2", Value{object.BIGINT_OBJ, "-9223372036854775809"}},
+	{"4294967296 * 4294967296", Value{object.BIGINT_OBJ, "18446744073709551616"}},
+	{"-9223372036854775807 - 1", Value{object.INTEGER_OBJ, "-9223372036854775808"}},
+	{"-(-9223372036854775807 - 1)", Value{object.BIGINT_OBJ, "9223372036854775808"}},
+	{"(-9223372036854775807 - 1) / -1", Value{object.BIGINT_OBJ, "9223372036854775808"}},
+	{"99999999999999999999", Value{object.BIGINT_OBJ, "99999999999999999999"}},
+	{"99999999999999999999 - 99999999999999999990", 9},
+	{"(9223372036854775807 + 1) / 2", Value{object.INTEGER_OBJ, "4611686018427387904"}},
+	{"99999999999999999999 > 1", true},
+	{"99999999999999999999 == 99999999999999999999", true},
+	{"99999999999999999999 * 0.5", 5e19},
+	{`{99999999999999999999: "big"}[99999999999999999999]`, "big"},
+	{"let fact = fn(n) { if (n < 2) { return 1; } n * fact(n - 1) }; fact(25)", Value{object.BIGINT_OBJ, "15511210043330985984000000"}},
+}
+
+var FunctionArguments = []Case{
+	{"let add = fn(a, b = 10) { a + b }; add(1)", 11},
+	{"let add = fn(a, b = 10) { a + b }; add(1, 2)", 3},
+	{"let f = fn(a, b = a * 2) { b }; f(3)", 6},
+	{"let f = fn(first, ...rest) { rest }; f(1, 2, 3)", Value{object.ARRAY_OBJ, "[2, 3]"}},
+	{"let f = fn(first, ...rest) { rest }; f(1)", Value{object.ARRAY_OBJ, "[]"}},
+	{"let f = fn(...args) { len(args) }; f()", 0},
+	{"let f = fn(a, b = 2, ...rest) { [a, b, rest] }; f(1, 5, 6)", Value{object.ARRAY_OBJ, "[1, 5, [6]]"}},
+	{"fn(a, b) { a }(1)", Error("wrong number of arguments. got=1, want=2")},
+	{"fn(a) { a }(1, 2)", Error("wrong number of arguments. got=2, want=1")},
+	{"fn(a, b = 1) { a }()", Error("wrong number of arguments. got=0, want=1 to 2")},
+	{"fn(a, ...b) { a }()", Error("wrong number of arguments. got=0, want=at least 1")},
+	{"fn(a = b) { a }()", Error("identifier not found: b")},
+}
+
+var ArithmeticErrors = []Case{
+	{"5 / 0", Error("division by zero")},
+	{"5 % 0", Error("modulo by zero")},
+	{"5.5 / 0", Error("division by zero")},
+	{"5 % 0.0", Error("modulo by zero")},
+	{"99999999999999999999 / 0", Error("division by zero")},
+	{"99999999999999999999 % 0", Error("modulo by zero")},
+	{"let f = fn(x) { 10 / x }; f(0)", Error("division by zero")},
+}
+
+var ModuloOperator = []Case{
+	{"7 % 3", 1},
+	{"-7 % 3", -1},
+	{"(-9223372036854775807 - 1) % -1", 0},
+	{"7.5 % 2", 1.5},
+	{"99999999999999999999 % 10", 9},
+	{"try { 1 / 0 } catch (e) { e[\"type\"] }", "ArithmeticError"},
+}
+
+//All 所有的用例
+var All = concat(
+	HashIndexExpressions,
+	HashLiterals,
+	ArrayIndexExpression,
+	ArrayLiterals,
+	BuiltinFunctions,
+	ForStatements,
+	LoopControl,
+	Strings,
+	Closures,
+	FunctionApplication,
+	Recursion,
+	LetStatements,
+	ErrorHandling,
+	[]Case{ErrorStackTrace, BuiltinErrorStackTrace, ThrowKeepsStackTrace},
+	TryCatch,
+	ReturnStatements,
+	IfElseExpressions,
+	BangOperator,
+	BooleanExpressions,
+	IntegerExpressions,
+	FloatExpressions,
+	BigIntExpressions,
+	FunctionArguments,
+	ArithmeticErrors,
+	ModuloOperator,
+)
+
+func concat(groups ...[]Case) []Case {
+	var all []Case
+	for _, g := range groups {
+		all = append(all, g...)
+	}
+	return all
+}
@@ -0,0 +1,85 @@
+//Package evaltest 求值器和虚拟机共用的测试用例，虚拟机的执行结果必须与求值器完全一致
+package evaltest
+
+import (
+	"fmt"
+	"monkey/object"
+	"strings"
+)
+
+//Case 一个测试用例，Expected为期望的结果：
+//int、float64、bool和string分别对应Integer、Float、Boolean和String，nil对应null，
+//Error为错误信息，Value按类型和Inspect比较其他的值
+type Case struct {
+	Input    string
+	Expected interface{}
+}
+
+//Error 期望的错误信息
+type Error string
+
+//Value 期望的值的类型和Inspect，哈希表的键值对按键排序
+type Value struct {
+	Type    object.ObjectType
+	Inspect string
+}
+
+//Check 检查结果是否符合期望，不符合时返回说明
+func Check(obj object.Object, expected interface{}) error {
+	if obj == nil {
+		return fmt.Errorf("nil result")
+	}
+	var want Value
+	switch expected := expected.(type) {
+	case int:
+		want = Value{object.INTEGER_OBJ, fmt.Sprint(expected)}
+	case float64:
+		want = Value{object.FLOAT_OBJ, (&object.Float{Value: expected}).Inspect()}
+	case bool:
+		want = Value{object.BOOLEAN_OBJ, fmt.Sprint(expected)}
+	case string:
+		want = Value{object.STRING_OBJ, expected}
+	case nil:
+		want = Value{object.NULL_OBJ, "null"}
+	case Error:
+		errObj, ok := obj.(*object.Error)
+		if !ok {
+			return fmt.Errorf("object is not Error. got=%s (%s)", obj.Type(), Inspect(obj))
+		}
+		if errObj.Message != string(expected) {
+			return fmt.Errorf("wrong error message. want=%q, got=%q", expected, errObj.Message)
+		}
+		return nil
+	case Value:
+		want = expected
+	default:
+		return fmt.Errorf("unsupported expectation %T", expected)
+	}
+	if obj.Type() != want.Type {
+		return fmt.Errorf("wrong type. want=%s, got=%s (%s)", want.Type, obj.Type(), Inspect(obj))
+	}
+	if got := Inspect(obj); got != want.Inspect {
+		return fmt.Errorf("wrong value. want=%s, got=%s", want.Inspect, got)
+	}
+	return nil
+}
+
+//Inspect 与obj.Inspect相同，但哈希表的键值对按键排序，便于比较
+func Inspect(obj object.Object) string {
+	switch obj := obj.(type) {
+	case *object.Hash:
+		pairs := []string{}
+		for _, pair := range obj.SortedPairs() {
+			pairs = append(pairs, Inspect(pair.Key)+": "+Inspect(pair.Value))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	case *object.Array:
+		elements := []string{}
+		for _, el := range obj.Elements {
+			elements = append(elements, Inspect(el))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	default:
+		return obj.Inspect()
+	}
+}
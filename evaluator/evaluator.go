@@ -2,8 +2,12 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"monkey/ast"
 	"monkey/object"
+	"reflect"
+	"strings"
 )
 
 var (
@@ -12,7 +16,40 @@ var (
 	FALSE = &object.Boolean{Value: false}
 )
 
-func Eval(node ast.Node, env *object.Environment) object.Object {
+//Eval 对语法树求值。不限制函数调用的层数，无限递归会耗尽Go的栈使进程崩溃，执行用户的程序时应使用EvalContext
+func Eval(node ast.Node, env *object.Environment) (result object.Object) {
+	//求值过程中的Go panic由最内层的节点转换为Monkey错误，不会让整个进程崩溃
+	defer func() {
+		if r := recover(); r != nil {
+			result = newInternalError(node, r)
+		}
+	}()
+	if hook := env.Hook(); hook != nil && node != nil {
+		if err := hook.Before(node, env); err != nil {
+			return err
+		}
+	}
+	result = eval(node, env)
+	//错误沿语法树向上传递，由最内层的节点记录出错位置
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() && node != nil {
+		err.Pos = node.Pos()
+	}
+	return result
+}
+
+//newInternalError 将求值node时发生的panic转换为错误
+func newInternalError(node ast.Node, r interface{}) *object.Error {
+	err := &object.Error{Kind: object.INTERNAL_ERROR}
+	if node == nil || reflect.ValueOf(node).IsNil() {
+		err.Message = fmt.Sprintf("internal error: %v", r)
+		return err
+	}
+	err.Message = fmt.Sprintf("internal error while evaluating %s: %v", strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast."), r)
+	err.Pos = node.Pos()
+	return err
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
 		return evalProgram(node, env)
@@ -20,7 +57,12 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return Eval(node.Expression, env)
 	case *ast.IntegerLiteral:
 		//todo 优化-127~128
+		if node.Big != nil {
+			return &object.BigInt{Value: node.Big}
+		}
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.PrefixExpression:
@@ -61,17 +103,14 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Body: body, Env: env}
+		return &object.Function{Name: node.Name, Parameters: params, Defaults: node.Defaults, Rest: node.Rest, Body: body, Env: env}
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
-		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
+		if isCallTo(node, "quote") {
+			return evalQuote(node, env)
 		}
-		return applyFunction(function, args)
+		return evalCallExpression(node, env)
+	case *ast.MacroLiteral:
+		return newError("macros must be defined with a top-level let statement")
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 	case *ast.ArrayLiteral:
@@ -94,6 +133,18 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalHashLiteral(node, env)
 	case *ast.AssignStatement:
 		return evalAssignStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.ForInStatement:
+		return evalForInStatement(node, env)
+	case *ast.BreakStatement:
+		return &object.Break{Label: labelName(node.Label)}
+	case *ast.ContinueStatement:
+		return &object.Continue{Label: labelName(node.Label)}
+	case *ast.ThrowStatement:
+		return evalThrowStatement(node, env)
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
 	}
 
 	return nil
@@ -111,16 +162,169 @@ func evalAssignStatement(node *ast.AssignStatement, env *object.Environment) obj
 	return evaluated
 }
 
+//evalForStatement 执行for循环，循环体与外层共享同一个环境
+func evalForStatement(node *ast.ForStatement, env *object.Environment) object.Object {
+	if node.Init != nil {
+		init := Eval(node.Init, env)
+		if isError(init) {
+			return init
+		}
+	}
+	for {
+		if node.Condition != nil {
+			condition := Eval(node.Condition, env)
+			if isError(condition) {
+				return condition
+			}
+			if !isTruthy(condition) {
+				break
+			}
+		}
+		result := Eval(node.Body, env)
+		if exit, out := loopSignal(result, node.Label); exit {
+			if out != nil {
+				return out
+			}
+			break
+		}
+		if node.Post != nil {
+			post := Eval(node.Post, env)
+			if isError(post) {
+				return post
+			}
+		}
+	}
+	return NULL
+}
+
+//evalForInStatement 依次将可迭代对象的元素绑定到循环变量上并执行循环体
+func evalForInStatement(node *ast.ForInStatement, env *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+	items, err := iterableItems(iterable)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		env.Set(node.Variable.Value, item)
+		result := Eval(node.Body, env)
+		if exit, out := loopSignal(result, node.Label); exit {
+			if out != nil {
+				return out
+			}
+			break
+		}
+	}
+	return NULL
+}
+
+//iterableItems 取出for-in循环依次访问的元素：数组的元素、字符串的字符、哈希排序后的键
+func iterableItems(iterable object.Object) ([]object.Object, *object.Error) {
+	var items []object.Object
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		items = iterable.Elements
+	case *object.String:
+		for _, r := range iterable.Value {
+			items = append(items, &object.String{Value: string(r)})
+		}
+	case *object.Hash:
+		for _, pair := range iterable.SortedPairs() {
+			items = append(items, pair.Key)
+		}
+	default:
+		return nil, newTypeError("not iterable: %s", iterable.Type())
+	}
+	return items, nil
+}
+
+//loopSignal 处理一轮循环体的执行结果
+//返回是否结束循环，以及需要继续向外层传递的结果（返回值、错误、外层循环的信号）
+func loopSignal(result object.Object, label *ast.Identifier) (bool, object.Object) {
+	switch result := result.(type) {
+	case *object.Break:
+		if result.Label == "" || result.Label == labelName(label) {
+			return true, nil
+		}
+		return true, result
+	case *object.Continue:
+		if result.Label == "" || result.Label == labelName(label) {
+			return false, nil
+		}
+		return true, result
+	}
+	if isTerminal(result) {
+		return true, result
+	}
+	return false, nil
+}
+
+func labelName(label *ast.Identifier) string {
+	if label == nil {
+		return ""
+	}
+	return label.Value
+}
+
+//evalThrowStatement 将抛出的值包装为错误，已捕获的错误原样重新抛出
+func evalThrowStatement(node *ast.ThrowStatement, env *object.Environment) object.Object {
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+	return throwValue(val)
+}
+
+//throwValue 将抛出的值转换为错误
+func throwValue(val object.Object) *object.Error {
+	switch val := val.(type) {
+	case *object.ErrorValue:
+		return val.Err
+	case *object.String:
+		return &object.Error{Kind: object.GENERIC_ERROR, Message: val.Value, Value: val}
+	default:
+		return &object.Error{Kind: object.GENERIC_ERROR, Message: val.Inspect(), Value: val}
+	}
+}
+
+//evalTryExpression 执行try语句块，出错时执行catch，最后总是执行finally
+//finally中的return、break以及错误会覆盖之前的结果，取消求值和超出求值限制的错误不会被catch捕获
+func evalTryExpression(node *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(node.Block, env)
+	if err, ok := result.(*object.Error); ok && node.Catch != nil && !err.Fatal() {
+		//catch的参数只在catch语句块中可见，不影响外层的同名变量
+		catchEnv := env
+		if node.CatchParam != nil {
+			catchEnv = object.NewBlockEnvironment(env, node.CatchParam.Value, &object.ErrorValue{Err: err})
+		}
+		result = Eval(node.Catch, catchEnv)
+	}
+	if node.Finally != nil {
+		finally := Eval(node.Finally, env)
+		if isTerminal(finally) {
+			return finally
+		}
+	}
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
-	for keyNode, valueNode := range node.Pairs {
+	//按源码顺序求值，键和值中的副作用与虚拟机一致
+	for _, keyNode := range ast.SortedKeys(node.Pairs) {
+		valueNode := node.Pairs[keyNode]
 		key := Eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
 		hashKey, ok := key.(object.Hashable)
 		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+			return newTypeError("unusable as hash key: %s", key.Type())
 		}
 		value := Eval(valueNode, env)
 		if isError(value) {
@@ -138,8 +342,10 @@ func evalIndexExpression(left object.Object, index object.Object) object.Object
 		return evalArrayIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.ERROR_VALUE_OBJ && index.Type() == object.STRING_OBJ:
+		return evalErrorValueIndexExpression(left, index)
 	default:
-		return newError("index operator not supported: %s", left.Type())
+		return newTypeError("index operator not supported: %s", left.Type())
 	}
 }
 
@@ -147,7 +353,7 @@ func evalHashIndexExpression(hash object.Object, index object.Object) object.Obj
 	hashObject := hash.(*object.Hash)
 	key, ok := index.(object.Hashable)
 	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+		return newTypeError("unusable as hash key: %s", index.Type())
 	}
 	pair, ok := hashObject.Pairs[key.HashKey()]
 	if !ok {
@@ -156,6 +362,24 @@ func evalHashIndexExpression(hash object.Object, index object.Object) object.Obj
 	return pair.Value
 }
 
+//evalErrorValueIndexExpression 读取捕获的错误的属性
+func evalErrorValueIndexExpression(errValue object.Object, index object.Object) object.Object {
+	err := errValue.(*object.ErrorValue).Err
+	switch index.(*object.String).Value {
+	case "type":
+		return &object.String{Value: err.Kind}
+	case "message":
+		return &object.String{Value: err.Message}
+	case "value":
+		if err.Value != nil {
+			return err.Value
+		}
+		return &object.String{Value: err.Message}
+	default:
+		return NULL
+	}
+}
+
 func evalArrayIndexExpression(array object.Object, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
 	idx := index.(*object.Integer).Value
@@ -166,16 +390,88 @@ func evalArrayIndexExpression(array object.Object, index object.Object) object.O
 	return arrayObject.Elements[idx]
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+//evalCallExpression 执行函数调用，调用过程记录在调用栈中
+func evalCallExpression(node *ast.CallExpression, env *object.Environment) object.Object {
+	function := Eval(node.Function, env)
+	if isError(function) {
+		return function
+	}
+	args := evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+	frame := &object.Frame{
+		Function: functionName(node.Function, function),
+		Pos:      node.Pos(),
+		Args:     args,
+		Parent:   env.Frame(),
+		Depth:    callDepth(env.Frame()),
+	}
+	result := applyFunction(function, args, frame, env)
+	//最内层的调用记录出错时的调用栈
+	if err, ok := result.(*object.Error); ok && err.Stack == nil {
+		err.Stack = frame.Stack()
+	}
+	return result
+}
+
+//functionName 调用栈中展示的函数名，优先使用let绑定的名称，其次是调用时使用的标识符
+func functionName(callee ast.Expression, fn object.Object) string {
+	if fn, ok := fn.(*object.Function); ok && fn.Name != "" {
+		return fn.Name
+	}
+	if ident, ok := callee.(*ast.Identifier); ok {
+		return ident.Value
+	}
+	return ""
+}
+
+//callDepth 在parent中调用函数时新一帧的嵌套层数
+func callDepth(parent *object.Frame) int {
+	if parent == nil {
+		return 1
+	}
+	return parent.Depth + 1
+}
+
+//ApplyFunction 从Go代码中调用函数或内置函数，name为调用栈中展示的函数名，env为调用者的环境
+func ApplyFunction(name string, fn object.Object, args []object.Object, env *object.Environment) (result object.Object) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = newInternalError(nil, r)
+		}
+	}()
+	if f, ok := fn.(*object.Function); ok && f.Name != "" {
+		name = f.Name
+	}
+	frame := &object.Frame{Function: name, Args: args, Parent: env.Frame(), Depth: callDepth(env.Frame())}
+	result = applyFunction(fn, args, frame, env)
+	if err, ok := result.(*object.Error); ok && err.Stack == nil {
+		err.Stack = frame.Stack()
+	}
+	if result == nil {
+		result = NULL
+	}
+	return result
+}
+
+//applyFunction 调用函数，env为调用者的环境
+func applyFunction(fn object.Object, args []object.Object, frame *object.Frame, env *object.Environment) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
+		extendedEnv, err := extendFunctionEnv(fn, args, frame)
+		if err != nil {
+			return err
+		}
 		evaluated := Eval(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
+		if fn.EnvFn != nil {
+			return fn.EnvFn(env, args...)
+		}
 		return fn.Fn(args...)
 	default:
-		return newError("not a function: %s", fn.Type())
+		return newTypeError("not a function: %s", fn.Type())
 	}
 }
 
@@ -186,12 +482,50 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
-	env := object.NewEncloseEnvironment(fn.Env)
+//extendFunctionEnv 绑定实参，缺少的参数使用默认值，多余的实参收集到剩余参数中
+func extendFunctionEnv(fn *object.Function, args []object.Object, frame *object.Frame) (*object.Environment, object.Object) {
+	required := 0
+	for _, param := range fn.Parameters {
+		if _, ok := fn.Defaults[param.Value]; !ok {
+			required++
+		}
+	}
+	if len(args) < required || (fn.Rest == nil && len(args) > len(fn.Parameters)) {
+		return nil, newArgumentError("wrong number of arguments. got=%d, want=%s", len(args), arity(fn, required))
+	}
+	env := object.NewCallEnvironment(fn.Env, frame)
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) {
+			env.Set(param.Value, args[paramIdx])
+			continue
+		}
+		//默认值在函数环境中求值，可以引用前面的参数
+		value := Eval(fn.Defaults[param.Value], env)
+		if isError(value) {
+			return nil, value
+		}
+		env.Set(param.Value, value)
+	}
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
+	}
+	return env, nil
+}
+
+//arity 描述函数可以接受的参数个数
+func arity(fn *object.Function, required int) string {
+	switch {
+	case fn.Rest != nil:
+		return fmt.Sprintf("at least %d", required)
+	case required == len(fn.Parameters):
+		return fmt.Sprintf("%d", required)
+	default:
+		return fmt.Sprintf("%d to %d", required, len(fn.Parameters))
 	}
-	return env
 }
 
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
@@ -210,10 +544,13 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
+	if builtin, ok := env.Builtin(node.Value); ok {
+		return builtin
+	}
 	if builtin, ok := builtins[node.Value]; ok {
 		return builtin
 	}
-	return newError("identifier not found: " + node.Value)
+	return newNameError("identifier not found: " + node.Value)
 }
 
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
@@ -247,6 +584,11 @@ func evalInfixExpression(operator string, left object.Object, right object.Objec
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isInteger(left) && isInteger(right):
+		return evalBigIntInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		//整数与浮点数混合运算时，整数提升为浮点数
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
@@ -254,15 +596,71 @@ func evalInfixExpression(operator string, left object.Object, right object.Objec
 	case operator == "!=":
 		return nativeBoolToBooleanObject(left != right)
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newTypeError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newTypeError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+//isNumber 是否是数值类型
+func isNumber(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Integer, *object.BigInt, *object.Float:
+		return true
+	}
+	return false
+}
+
+//toFloat 将数值转换为浮点数
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInt:
+		f, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return f
+	case *object.Float:
+		return obj.Value
+	}
+	return 0
+}
+
+func evalFloatInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return newArithmeticError("division by zero")
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newArithmeticError("modulo by zero")
+		}
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newTypeError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
 func evalStringInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	if operator != "+" {
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newTypeError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
@@ -274,13 +672,38 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	rightVal := right.(*object.Integer).Value
 	switch operator {
 	case "+":
+		if addOverflows(leftVal, rightVal) {
+			return evalBigIntInfixExpression(operator, left, right)
+		}
 		return &object.Integer{Value: leftVal + rightVal}
 	case "-":
+		if subOverflows(leftVal, rightVal) {
+			return evalBigIntInfixExpression(operator, left, right)
+		}
 		return &object.Integer{Value: leftVal - rightVal}
 	case "*":
+		if mulOverflows(leftVal, rightVal) {
+			return evalBigIntInfixExpression(operator, left, right)
+		}
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newArithmeticError("division by zero")
+		}
+		//MinInt64 / -1 的结果超出int64范围
+		if leftVal == math.MinInt64 && rightVal == -1 {
+			return evalBigIntInfixExpression(operator, left, right)
+		}
 		return &object.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newArithmeticError("modulo by zero")
+		}
+		//MinInt64 % -1 为0，直接计算不会溢出
+		if rightVal == -1 {
+			return &object.Integer{Value: 0}
+		}
+		return &object.Integer{Value: leftVal % rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
@@ -290,7 +713,7 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	case "!=":
 		return nativeBoolToBooleanObject(leftVal != rightVal)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newTypeError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
@@ -301,16 +724,24 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 	case "-":
 		return evalMinusOperatorExpression(right)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return newTypeError("unknown operator: %s%s", operator, right.Type())
 	}
 }
 
 func evalMinusOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return newInteger(new(big.Int).Neg(big.NewInt(right.Value)))
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInt:
+		return newInteger(new(big.Int).Neg(right.Value))
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newTypeError("unknown operator: -%s", right.Type())
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 func evalBangOperatorExpression(right object.Object) object.Object {
@@ -362,18 +793,42 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	var result object.Object
 	for _, statement := range block.Statements {
 		result = Eval(statement, env)
-		if result != nil {
-			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
-				return result
-			}
+		if isTerminal(result) {
+			return result
 		}
 	}
 	return result
 }
 
+//isTerminal 是否需要中断当前语句块的执行
+func isTerminal(obj object.Object) bool {
+	if obj != nil {
+		switch obj.Type() {
+		case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+			return true
+		}
+	}
+	return false
+}
+
 func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+	return &object.Error{Kind: object.GENERIC_ERROR, Message: fmt.Sprintf(format, a...)}
+}
+
+func newTypeError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Kind: object.TYPE_ERROR, Message: fmt.Sprintf(format, a...)}
+}
+
+func newNameError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Kind: object.NAME_ERROR, Message: fmt.Sprintf(format, a...)}
+}
+
+func newArithmeticError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Kind: object.ARITHMETIC_ERROR, Message: fmt.Sprintf(format, a...)}
+}
+
+func newArgumentError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Kind: object.ARGUMENT_ERROR, Message: fmt.Sprintf(format, a...)}
 }
 
 func isError(obj object.Object) bool {
@@ -1,222 +1,58 @@
 package evaluator
 
 import (
+	"fmt"
+	"monkey/ast"
+	"monkey/evaluator/evaltest"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"monkey/token"
+	"strings"
 	"testing"
 )
 
 // evaluator/evaluator_test.go
 
 func TestHashIndexExpressions(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected interface{}
-	}{
-		{
-			`{"foo": 5}["foo"]`,
-			5,
-		},
-		{
-			`{"foo": 5}["bar"]`,
-			nil,
-		},
-		{
-			`let key = "foo"; {"foo": 5}[key]`,
-			5,
-		},
-		{
-			`{}["foo"]`,
-			nil,
-		},
-		{
-			`{5: 5}[5]`,
-			5,
-		},
-		{
-			`{true: 5}[true]`,
-			5,
-		},
-		{
-			`{false: 5}[false]`,
-			5,
-		},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		integer, ok := tt.expected.(int)
-		if ok {
-			testIntegerObject(t, evaluated, int64(integer))
-		} else {
-			testNullObject(t, evaluated)
-		}
-	}
+	runCases(t, evaltest.HashIndexExpressions)
 }
 func TestHashLiterals(t *testing.T) {
-	input := `let two = "two";
-    {
-        "one": 10 - 9,
-        two: 1 + 1,
-        "thr" + "ee": 6 / 2,
-        4: 4,
-        true: 5,
-        false: 6
-    }`
-
-	evaluated := testEval(input)
-	result, ok := evaluated.(*object.Hash)
-	if !ok {
-		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
-	}
-
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		TRUE.HashKey():                             5,
-		FALSE.HashKey():                            6,
-	}
-
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
-	}
-
-	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
-		if !ok {
-			t.Errorf("no pair for given key in Pairs")
-		}
-
-		testIntegerObject(t, pair.Value, expectedValue)
-	}
+	runCases(t, evaltest.HashLiterals)
 }
 
 func TestArrayIndexExpression(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected interface{}
-	}{
-		{"[1, 2, 3][0]", 1},
-		{"[1, 2, 3][1]", 2},
-		{"[1, 2, 3][2]", 3},
-		{"let i = 0; [1][i];", 1},
-		{"[1, 2, 3][1 + 1];", 3},
-		{"let myArray = [1, 2, 3]; myArray[2];", 3},
-		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
-		{"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]", 2},
-		{"[1, 2, 3][3]", nil},
-		{"[1, 2, 3][-1]", nil},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		integer, ok := tt.expected.(int)
-		if ok {
-			testIntegerObject(t, evaluated, int64(integer))
-		} else {
-			testNullObject(t, evaluated)
-		}
-	}
-
+	runCases(t, evaltest.ArrayIndexExpression)
 }
 func TestArrayLiterals(t *testing.T) {
-	input := "[1, 2 * 2 , 3 + 3]"
-	evaluated := testEval(input)
-	result, ok := evaluated.(*object.Array)
-	if !ok {
-		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
-	}
-	if len(result.Elements) != 3 {
-		t.Fatalf("array has wrong num os elements. got=%d", len(result.Elements))
-	}
-	testIntegerObject(t, result.Elements[0], 1)
-	testIntegerObject(t, result.Elements[1], 4)
-	testIntegerObject(t, result.Elements[2], 6)
+	runCases(t, evaltest.ArrayLiterals)
 }
 func TestBuiltinFunctions(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected interface{}
-	}{
-		{`len("")`, 0},
-		{`len("four")`, 4},
-		{`len("hello world")`, 11},
-		{`len(1)`, "argument to `len` not supported, got INTEGER"},
-		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
-	}
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		switch expected := tt.expected.(type) {
-		case int:
-			testIntegerObject(t, evaluated, int64(expected))
-		case string:
-			errObj, ok := evaluated.(*object.Error)
-			if !ok {
-				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
-				continue
-			}
-			if errObj.Message != expected {
-				t.Errorf("wrong error message. expeted=%q, got=%q", expected, errObj.Message)
-			}
-		}
-	}
+	runCases(t, evaltest.BuiltinFunctions)
 }
 
-func TestStringConcatenation(t *testing.T) {
-	input := `"Hello" + " "+"World!"`
-	evaluated := testEval(input)
-	str, ok := evaluated.(*object.String)
-	if !ok {
-		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
-	}
-	if str.Value != "Hello World!" {
-		t.Errorf("String has wrong value. got=%s", str.Value)
-	}
+func TestForStatements(t *testing.T) {
+	runCases(t, evaltest.ForStatements)
 }
 
-func TestStringLiteral(t *testing.T) {
-	input := `"Hello World!"`
-	evaluated := testEval(input)
-	str, ok := evaluated.(*object.String)
-	if !ok {
-		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
-	}
-	if str.Value != "Hello World!" {
-		t.Errorf("String has wrong value. got=%s", str.Value)
-	}
+func TestLoopControl(t *testing.T) {
+	runCases(t, evaltest.LoopControl)
 }
 
-func TestClosures(t *testing.T) {
-	input := `
-let newAdder = fn(x) {
-  fn(y) { x + y };
-};
-let addTwo = newAdder(2);
-addTwo(2);
-`
+func TestStrings(t *testing.T) {
+	runCases(t, evaltest.Strings)
+}
 
-	testIntegerObject(t, testEval(input), 4)
+func TestRecursion(t *testing.T) {
+	runCases(t, evaltest.Recursion)
 }
 
-func TestFunctionApplication(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"let identity = fn(x) { x; }; identity(5);", 5},
-		{"let identity = fn(x) { return x; }; identity(5);", 5},
-		{"let double = fn(x) { x * 2; }; double(5);", 10},
-		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
-		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
-		{"fn(x) { x; }(5)", 5},
-	}
+func TestClosures(t *testing.T) {
+	runCases(t, evaltest.Closures)
+}
 
-	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
-	}
+func TestFunctionApplication(t *testing.T) {
+	runCases(t, evaltest.FunctionApplication)
 }
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2; };"
@@ -238,221 +74,208 @@ func TestFunctionObject(t *testing.T) {
 	}
 }
 func TestLetStatements(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"let a = 5; a;", 5},
-		{"let a = 5 * 5; a;", 25},
-		{"let a = 5; let b = a; b;", 5},
-		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
-	}
-	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
-	}
+	runCases(t, evaltest.LetStatements)
 }
 
 func TestErrorHandling(t *testing.T) {
-	tests := []struct {
-		input           string
-		expectedMessage string
+	runCases(t, evaltest.ErrorHandling)
+}
+
+func TestErrorStackTrace(t *testing.T) {
+	evaluated := testEval(evaltest.ErrorStackTrace.Input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Pos.String() != "1:21" {
+		t.Errorf("wrong error position. want=%q, got=%q", "1:21", errObj.Pos.String())
+	}
+	expected := []struct {
+		frame string
+		pos   string
 	}{
-		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
-		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
-		{"-true", "unknown operator: -BOOLEAN"},
-		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
-		{"5; true + false; 5", "unknown operator: BOOLEAN + BOOLEAN"},
-		{"if (10 > 1) { true + false; }", "unknown operator: BOOLEAN + BOOLEAN"},
-		{`
-if (10 > 1) {
-  if (10 > 1) {
-    return true + false;
-  }
-
-  return 1;
-}
-`, "unknown operator: BOOLEAN + BOOLEAN"},
-		{"foobar", "identifier not found: foobar"},
-		{`"Hello" - "World"`, "unknown operator: STRING - STRING"},
-		{
-			`{"name": "Monkey"}[fn(x) { x }];`,
-			"unusable as hash key: FUNCTION",
-		},
+		{"inner(1)", "2:21"},
+		{"outer(1)", "3:1"},
 	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-
-		errObj, ok := evaluated.(*object.Error)
-		if !ok {
-			t.Errorf("no error object returned. got=%T(%+v)",
-				evaluated, evaluated)
-			continue
+	if len(errObj.Stack) != len(expected) {
+		t.Fatalf("wrong stack depth. want=%d, got=%d", len(expected), len(errObj.Stack))
+	}
+	for i, tt := range expected {
+		frame := errObj.Stack[i]
+		if frame.String() != tt.frame {
+			t.Errorf("stack[%d] wrong. want=%q, got=%q", i, tt.frame, frame.String())
 		}
-
-		if errObj.Message != tt.expectedMessage {
-			t.Errorf("wrong error message. expected=%q, got=%q",
-				tt.expectedMessage, errObj.Message)
+		if frame.Pos.String() != tt.pos {
+			t.Errorf("stack[%d] wrong position. want=%q, got=%q", i, tt.pos, frame.Pos.String())
 		}
 	}
 }
 
-func TestReturnStatements(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"return 10;", 10},
-		{"return 10; 9", 10},
-		{"return 2*5; 9;", 10},
-		{"9; return 2*5; 9;", 10},
-		{`
-if (10 >1) {
-	if (10>1){
-		return 10;
+func TestBuiltinErrorStackTrace(t *testing.T) {
+	evaluated := testEval(evaltest.BuiltinErrorStackTrace.Input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
 	}
-	return 1;
-}
-`, 10},
+	expected := []string{"first(1)", "g(1)", "f()"}
+	if len(errObj.Stack) != len(expected) {
+		t.Fatalf("wrong stack depth. want=%d, got=%d", len(expected), len(errObj.Stack))
 	}
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testIntegerObject(t, evaluated, tt.expected)
+	for i, frame := range expected {
+		if errObj.Stack[i].String() != frame {
+			t.Errorf("stack[%d] wrong. want=%q, got=%q", i, frame, errObj.Stack[i].String())
+		}
 	}
 }
-func TestIfElseExpressions(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected interface{}
-	}{
-		{"if (true) { 10 }", 10},
-		{"if (false) { 10 }", nil},
-		{"if (1) { 10 }", 10},
-		{"if (1 < 2) { 10 }", 10},
-		{"if (1 > 2) { 10 }", nil},
-		{"if (1 > 2) { 10 } else { 20 }", 20},
-		{"if (1 < 2) { 10 } else { 20 }", 10},
-	}
 
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		integer, ok := tt.expected.(int)
-		if ok {
-			testIntegerObject(t, evaluated, int64(integer))
-		} else {
-			testNullObject(t, evaluated)
-		}
-	}
+func TestTryCatch(t *testing.T) {
+	runCases(t, evaltest.TryCatch)
 }
 
-func testNullObject(t *testing.T, obj object.Object) bool {
-	if obj != NULL {
-		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
-		return false
+func TestThrowKeepsStackTrace(t *testing.T) {
+	evaluated := testEval(evaltest.ThrowKeepsStackTrace.Input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
 	}
-	return true
+	if errObj.Pos.String() != "1:16" {
+		t.Errorf("wrong error position. got=%q", errObj.Pos.String())
+	}
+	if len(errObj.Stack) != 2 || errObj.Stack[0].Function != "f" {
+		t.Errorf("wrong stack. got=%+v", errObj.Stack)
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	runCases(t, evaltest.ReturnStatements)
+}
+func TestIfElseExpressions(t *testing.T) {
+	runCases(t, evaltest.IfElseExpressions)
 }
 
 func TestBangOperator(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected bool
-	}{
-		{"!true", false},
-		{"!false", true},
-		{"!5", false},
-		{"!!true", true},
-		{"!!false", false},
-		{"!!5", true},
-	}
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testBooleanObject(t, evaluated, tt.expected)
-	}
+	runCases(t, evaltest.BangOperator)
 }
 
 func TestEvalBooleanExpression(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected bool
-	}{
-		{"true", true},
-		{"false", false},
-		{"1 < 2", true},
-		{"1 > 2", false},
-		{"1 < 1", false},
-		{"1 > 1", false},
-		{"1 == 1", true},
-		{"1 != 1", false},
-		{"1 == 2", false},
-		{"1 != 2", true},
-		{"true == true", true},
-		{"false == false", true},
-		{"true == false", false},
-		{"true != false", true},
-		{"false != true", true},
-		{"(1 < 2) == true", true},
-		{"(1 < 2) == false", false},
-		{"(1 > 2) == true", false},
-		{"(1 > 2) == false", true},
+	runCases(t, evaltest.BooleanExpressions)
+}
+
+func TestEvalIntegerExpression(t *testing.T) {
+	runCases(t, evaltest.IntegerExpressions)
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	runCases(t, evaltest.FloatExpressions)
+}
+
+func TestEvalBigIntExpression(t *testing.T) {
+	runCases(t, evaltest.BigIntExpressions)
+}
+
+func TestFunctionArguments(t *testing.T) {
+	runCases(t, evaltest.FunctionArguments)
+	errObj, ok := testEval("fn(a, b) { a }(1)").(*object.Error)
+	if !ok || errObj.Kind != object.ARGUMENT_ERROR {
+		t.Errorf("arity error should be an ArgumentError. got=%+v", errObj)
 	}
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testBooleanObjectDebug(t, tt.input, evaluated, tt.expected)
+}
+
+func TestArithmeticErrors(t *testing.T) {
+	runCases(t, evaltest.ArithmeticErrors)
+	for _, tt := range evaltest.ArithmeticErrors {
+		errObj, ok := testEval(tt.Input).(*object.Error)
+		if ok && errObj.Kind != object.ARITHMETIC_ERROR {
+			t.Errorf("wrong error kind for %q. want=%s, got=%s", tt.Input, object.ARITHMETIC_ERROR, errObj.Kind)
+		}
 	}
 }
 
-func testBooleanObjectDebug(t *testing.T, input string, obj object.Object, expected bool) bool {
-	result, ok := obj.(*object.Boolean)
+func TestModuloOperator(t *testing.T) {
+	runCases(t, evaltest.ModuloOperator)
+}
+
+func TestEvalRecoversPanics(t *testing.T) {
+	//不完整的语法树会在求值时触发panic
+	program := &ast.Program{Statements: []ast.Statement{
+		&ast.ExpressionStatement{Expression: &ast.PrefixExpression{
+			Token:    token.Token{Type: token.MINUS, Literal: "-", Pos: token.Position{Line: 3, Column: 7}},
+			Operator: "-",
+		}},
+	}}
+	evaluated := Eval(program, object.NewEnvironment())
+	errObj, ok := evaluated.(*object.Error)
 	if !ok {
-		t.Errorf("object is not Boolean. input=%s. got=%T (%+v)", input, obj, obj)
-		return false
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
 	}
-	if result.Value != expected {
-		t.Errorf("object has wrong value. input=%s. got=%t, want=%t", input, result.Value, expected)
-		return false
+	if errObj.Kind != object.INTERNAL_ERROR {
+		t.Errorf("wrong error kind. want=%s, got=%s", object.INTERNAL_ERROR, errObj.Kind)
 	}
-	return true
-}
-func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
-	result, ok := obj.(*object.Boolean)
-	if !ok {
-		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
-		return false
+	if !strings.HasPrefix(errObj.Message, "internal error while evaluating") {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
 	}
-	if result.Value != expected {
-		t.Errorf("object has wrong value. got=%t, want=%t", result.Value, expected)
-		return false
+	if errObj.Pos.String() != "3:7" {
+		t.Errorf("wrong error position. got=%q", errObj.Pos.String())
 	}
-	return true
 }
 
-func TestEvalIntegerExpression(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"5", 5},
-		{"10", 10},
-		{"-5", -5},
-		{"-10", -10},
-
-		{"5+5+5+5-10", 10},
-		{"2*2*2*2*2", 32},
-		{"-50 + 100 + -50", 0},
-		{"5 * 2 + 10", 20},
-		{"5 + 2 * 10", 25},
-		{"20 + 2 * -10", 0},
-		{"20 + 2 * -10", 0},
-		{"50 / 2 * 2 + 10", 60},
-		{"2 * (5 + 10)", 30},
-		{"3 * 3 * 3 + 10", 37},
-		{"3 * (3 * 3) + 10", 37},
-		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+//recordingHook 记录每个节点，遇到名为stop的标识符时返回错误
+type recordingHook struct {
+	nodes []string
+}
+
+func (h *recordingHook) Before(node ast.Node, env *object.Environment) *object.Error {
+	h.nodes = append(h.nodes, strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")+" "+node.String())
+	if ident, ok := node.(*ast.Identifier); ok && ident.Value == "stop" {
+		return newError("stopped at %s", node.Pos())
 	}
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testIntegerObject(t, evaluated, tt.expected)
+	return nil
+}
+
+func TestHook(t *testing.T) {
+	hook := &recordingHook{}
+	env := object.NewEnvironment()
+	env.SetHook(hook)
+	program := parser.New(lexer.New("let f = fn(a) { a * 2 }; f(1);")).ParseProgram()
+	testIntegerObject(t, Eval(program, env), 2)
+	expected := []string{
+		"Program let f = fn(a)(a * 2);f(1)",
+		"LetStatement let f = fn(a)(a * 2);",
+		"FunctionLiteral fn(a)(a * 2)",
+		"ExpressionStatement f(1)",
+		"CallExpression f(1)",
+		"Identifier f",
+		"IntegerLiteral 1",
+		"BlockStatement (a * 2)",
+		"ExpressionStatement (a * 2)",
+		"InfixExpression (a * 2)",
+		"Identifier a",
+		"IntegerLiteral 2",
+	}
+	if strings.Join(hook.nodes, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong nodes.\nwant=%q\ngot=%q", expected, hook.nodes)
+	}
+
+	evaluated := Eval(parser.New(lexer.New("let x = 1;\nlet y = x + stop;\nlet z = 3;")).ParseProgram(), env)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "stopped at 2:13" {
+		t.Fatalf("expected error from hook. got=%v", evaluated)
+	}
+	if _, ok := env.Get("z"); ok {
+		t.Errorf("evaluation continued after hook error")
+	}
+
+	env.SetHook(nil)
+	testIntegerObject(t, Eval(parser.New(lexer.New("let stop = 1; stop")).ParseProgram(), env), 1)
+}
+
+//runCases 对求值器的用例逐一求值，检查结果
+func runCases(t *testing.T, cases []evaltest.Case) {
+	t.Helper()
+	for _, tt := range cases {
+		if err := evaltest.Check(testEval(tt.Input), tt.Expected); err != nil {
+			t.Errorf("%q: %s", tt.Input, err)
+		}
 	}
 }
 
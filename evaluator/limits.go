@@ -0,0 +1,106 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"monkey/ast"
+	"monkey/object"
+)
+
+//DefaultMaxDepth 没有设置MaxDepth时函数调用最多嵌套的层数，避免无限递归耗尽Go的栈使进程崩溃
+const DefaultMaxDepth = 10000
+
+//Limits 求值的限制
+type Limits struct {
+	MaxSteps int //最多求值的步数，每个语句和表达式计一步，为0时不限制
+	MaxDepth int //函数调用最多嵌套的层数，为0时使用DefaultMaxDepth，小于0时不限制
+}
+
+//EvalContext 与Eval相同，但ctx被取消或超时、步数或调用层数超出limits时停止求值，
+//分别返回CancelledError、StepLimitError和CallDepthError，这些错误不能被try捕获。
+//取消在每次函数调用和进入语句块（包括每轮循环）时检查
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment, limits Limits) object.Object {
+	return withLimits(ctx, env, limits, func() object.Object {
+		return Eval(node, env)
+	})
+}
+
+//ExpandMacrosContext 与ExpandMacros相同，但宏的求值按EvalContext的方式限制，步数与之后的求值分别计算
+func ExpandMacrosContext(ctx context.Context, program ast.Node, env *object.Environment, limits Limits) (ast.Node, *object.Error) {
+	var expanded ast.Node
+	var expandErr *object.Error
+	result := withLimits(ctx, env, limits, func() object.Object {
+		expanded, expandErr = ExpandMacros(program, env)
+		return nil
+	})
+	if err, ok := result.(*object.Error); ok {
+		return nil, err
+	}
+	return expanded, expandErr
+}
+
+//ApplyFunctionContext 与ApplyFunction相同，但按EvalContext的方式限制求值
+func ApplyFunctionContext(ctx context.Context, name string, fn object.Object, args []object.Object, env *object.Environment, limits Limits) object.Object {
+	return withLimits(ctx, env, limits, func() object.Object {
+		return ApplyFunction(name, fn, args, env)
+	})
+}
+
+//withLimits 求值期间在env上设置检查限制的钩子，已有的钩子在检查之后照常调用
+func withLimits(ctx context.Context, env *object.Environment, limits Limits, eval func() object.Object) object.Object {
+	if err := ctx.Err(); err != nil {
+		return newCancelledError(err)
+	}
+	if limits.MaxDepth == 0 {
+		limits.MaxDepth = DefaultMaxDepth
+	}
+	previous := env.Hook()
+	env.SetHook(&limiter{ctx: ctx, limits: limits, next: previous})
+	defer env.SetHook(previous)
+	return eval()
+}
+
+//limiter 检查取消和求值限制的钩子
+type limiter struct {
+	ctx    context.Context
+	limits Limits
+	steps  int
+	next   object.Hook
+}
+
+func (l *limiter) Before(node ast.Node, env *object.Environment) *object.Error {
+	l.steps++
+	if l.limits.MaxSteps > 0 && l.steps > l.limits.MaxSteps {
+		return &object.Error{Kind: object.STEP_LIMIT_ERROR, Message: fmt.Sprintf("step limit of %d exceeded", l.limits.MaxSteps)}
+	}
+	switch node.(type) {
+	case *ast.CallExpression:
+		if err := l.cancelled(); err != nil {
+			return err
+		}
+		if l.limits.MaxDepth > 0 && callDepth(env.Frame()) > l.limits.MaxDepth {
+			return &object.Error{Kind: object.CALL_DEPTH_ERROR, Message: fmt.Sprintf("maximum call depth of %d exceeded", l.limits.MaxDepth)}
+		}
+	case *ast.BlockStatement:
+		if err := l.cancelled(); err != nil {
+			return err
+		}
+	}
+	if l.next != nil {
+		return l.next.Before(node, env)
+	}
+	return nil
+}
+
+func (l *limiter) cancelled() *object.Error {
+	select {
+	case <-l.ctx.Done():
+		return newCancelledError(l.ctx.Err())
+	default:
+		return nil
+	}
+}
+
+func newCancelledError(err error) *object.Error {
+	return &object.Error{Kind: object.CANCELLED_ERROR, Message: "evaluation cancelled: " + err.Error()}
+}
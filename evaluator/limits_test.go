@@ -0,0 +1,121 @@
+package evaluator
+
+import (
+	"context"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+	"time"
+)
+
+func TestEvalContextLimits(t *testing.T) {
+	tests := []struct {
+		input   string
+		limits  Limits
+		kind    string
+		message string
+	}{
+		{`let f = fn() { f() }; f()`, Limits{MaxDepth: 100}, object.CALL_DEPTH_ERROR, "maximum call depth of 100 exceeded"},
+		{`for (;;) {}`, Limits{MaxSteps: 1000}, object.STEP_LIMIT_ERROR, "step limit of 1000 exceeded"},
+		{`let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(1000)`, Limits{MaxSteps: 500}, object.STEP_LIMIT_ERROR, "step limit of 500 exceeded"},
+		{`let f = fn() { try { f() } catch (e) { 1 } }; f()`, Limits{MaxDepth: 10}, object.CALL_DEPTH_ERROR, "maximum call depth of 10 exceeded"},
+		{`for (;;) { try { 1 } catch (e) { 2 } finally { 3 } }`, Limits{MaxSteps: 100}, object.STEP_LIMIT_ERROR, "step limit of 100 exceeded"},
+	}
+	for _, tt := range tests {
+		evaluated := testEvalContext(context.Background(), tt.input, tt.limits)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Kind != tt.kind || errObj.Message != tt.message {
+			t.Errorf("wrong error for %q. want=%s: %s, got=%s: %s", tt.input, tt.kind, tt.message, errObj.Kind, errObj.Message)
+		}
+	}
+}
+
+func TestEvalContextWithinLimits(t *testing.T) {
+	input := `let f = fn(n) { if (n == 0) { 0 } else { n + f(n - 1) } }; f(50)`
+	evaluated := testEvalContext(context.Background(), input, Limits{MaxSteps: 10000, MaxDepth: 51})
+	testIntegerObject(t, evaluated, 1275)
+}
+
+func TestEvalContextDefaultMaxDepth(t *testing.T) {
+	evaluated := testEvalContext(context.Background(), `let f = fn() { f() }; f()`, Limits{})
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Kind != object.CALL_DEPTH_ERROR || len(errObj.Stack) != DefaultMaxDepth {
+		t.Errorf("wrong error. got=%s: %s, stack depth %d", errObj.Kind, errObj.Message, len(errObj.Stack))
+	}
+	input := `let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(20000)`
+	testIntegerObject(t, testEvalContext(context.Background(), input, Limits{MaxDepth: -1}), 0)
+}
+
+func TestEvalContextCallDepthStackTrace(t *testing.T) {
+	evaluated := testEvalContext(context.Background(), `let f = fn(n) { f(n + 1) }; f(1)`, Limits{MaxDepth: 3})
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	expected := []string{"f(3)", "f(2)", "f(1)"}
+	if len(errObj.Stack) != len(expected) {
+		t.Fatalf("wrong stack depth. want=%d, got=%d", len(expected), len(errObj.Stack))
+	}
+	for i, frame := range expected {
+		if errObj.Stack[i].String() != frame {
+			t.Errorf("stack[%d] wrong. want=%q, got=%q", i, frame, errObj.Stack[i].String())
+		}
+	}
+}
+
+func TestEvalContextCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	evaluated := testEvalContext(ctx, `let n = 0; for (;;) { n = n + 1 }`, Limits{})
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Kind != object.CANCELLED_ERROR || errObj.Message != "evaluation cancelled: context deadline exceeded" {
+		t.Errorf("wrong error. got=%s: %s", errObj.Kind, errObj.Message)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	evaluated = testEvalContext(ctx, `1`, Limits{})
+	if errObj, ok := evaluated.(*object.Error); !ok || errObj.Kind != object.CANCELLED_ERROR {
+		t.Errorf("cancelled context should not evaluate. got=%T(%+v)", evaluated, evaluated)
+	}
+}
+
+func TestEvalContextRestoresHook(t *testing.T) {
+	env := object.NewEnvironment()
+	hook := &countingHook{}
+	env.SetHook(hook)
+	program := parser.New(lexer.New(`1 + 2`)).ParseProgram()
+	testIntegerObject(t, EvalContext(context.Background(), program, env, Limits{MaxSteps: 100}), 3)
+	if hook.count == 0 {
+		t.Errorf("existing hook was not called")
+	}
+	if env.Hook() != hook {
+		t.Errorf("hook not restored. got=%T", env.Hook())
+	}
+}
+
+type countingHook struct {
+	count int
+}
+
+func (h *countingHook) Before(node ast.Node, env *object.Environment) *object.Error {
+	h.count++
+	return nil
+}
+
+func testEvalContext(ctx context.Context, input string, limits Limits) object.Object {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	return EvalContext(ctx, program, object.NewEnvironment(), limits)
+}
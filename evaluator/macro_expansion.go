@@ -0,0 +1,121 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+// evaluator/macro_expansion.go
+//
+//宏展开在解析之后、求值或编译之前进行：
+//DefineMacros 收集顶层的 let name = macro(...) { ... } 定义，ExpandMacros 将宏调用替换为宏返回的语法树
+
+//maxExpansionDepth 宏展开结果中仍有宏调用时会继续展开，超过该深度视为无限展开
+const maxExpansionDepth = 100
+
+//DefineMacros 将程序顶层的宏定义求值后保存到env中，并从程序中移除这些定义
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	statements := []ast.Statement{}
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok {
+			statements = append(statements, stmt)
+			continue
+		}
+		macro, ok := let.Value.(*ast.MacroLiteral)
+		if !ok {
+			statements = append(statements, stmt)
+			continue
+		}
+		env.Set(let.Name.Value, &object.Macro{Parameters: macro.Parameters, Body: macro.Body, Env: env})
+	}
+	program.Statements = statements
+}
+
+//ExpandMacros 展开程序中的宏调用，宏的实参是未求值的语法树（Quote），宏必须返回Quote
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	return expandMacros(program, env, 0)
+}
+
+func expandMacros(node ast.Node, env *object.Environment, depth int) (ast.Node, *object.Error) {
+	var expandErr *object.Error
+	expanded := ast.Modify(node, func(n ast.Node) ast.Node {
+		if expandErr != nil {
+			return n
+		}
+		call, ok := n.(*ast.CallExpression)
+		if !ok {
+			return n
+		}
+		macro, ok := macroOf(call, env)
+		if !ok {
+			return n
+		}
+		if depth >= maxExpansionDepth {
+			expandErr = newError("macro expansion is too deep (more than %d levels)", maxExpansionDepth)
+			expandErr.Pos = call.Pos()
+			return n
+		}
+		result, err := applyMacro(macro, call, env)
+		if err != nil {
+			expandErr = err
+			return n
+		}
+		//宏返回的语法树中可能还有宏调用
+		result, expandErr = expandMacros(result, env, depth+1)
+		return result
+	})
+	return expanded, expandErr
+}
+
+//macroOf 调用的函数是否是已定义的宏
+func macroOf(call *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+	obj, ok := env.Get(ident.Value)
+	if !ok {
+		return nil, false
+	}
+	macro, ok := obj.(*object.Macro)
+	return macro, ok
+}
+
+func applyMacro(macro *object.Macro, call *ast.CallExpression, env *object.Environment) (ast.Node, *object.Error) {
+	args := []object.Object{}
+	for _, a := range call.Arguments {
+		args = append(args, &object.Quote{Node: a})
+	}
+	frame := &object.Frame{
+		Function: call.Function.String(),
+		Pos:      call.Pos(),
+		Args:     args,
+		Parent:   env.Frame(),
+		Depth:    callDepth(env.Frame()),
+	}
+	if len(args) != len(macro.Parameters) {
+		err := newArgumentError("wrong number of arguments to macro %s. got=%d, want=%d", frame.Function, len(args), len(macro.Parameters))
+		err.Pos = call.Pos()
+		return nil, err
+	}
+	extendedEnv := object.NewCallEnvironment(macro.Env, frame)
+	for i, param := range macro.Parameters {
+		extendedEnv.Set(param.Value, args[i])
+	}
+	evaluated := unwrapReturnValue(Eval(macro.Body, extendedEnv))
+	if err, ok := evaluated.(*object.Error); ok {
+		if err.Stack == nil {
+			err.Stack = frame.Stack()
+		}
+		return nil, err
+	}
+	quote, ok := evaluated.(*object.Quote)
+	if !ok {
+		err := newTypeError("macro %s must return a quoted AST, got %s", frame.Function, evaluated.Type())
+		err.Pos = call.Pos()
+		err.Stack = frame.Stack()
+		return nil, err
+	}
+	return quote.Node, nil
+}
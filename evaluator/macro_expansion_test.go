@@ -0,0 +1,148 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+)
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	let number = 1;
+	let function = fn(x, y) { x + y };
+	let mymacro = macro(x, y) { x + y; };
+	`
+	env := object.NewEnvironment()
+	program := testParseProgram(input)
+
+	DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("Wrong number of statements. got=%d", len(program.Statements))
+	}
+	if _, ok := env.Get("number"); ok {
+		t.Fatalf("number should not be defined")
+	}
+	if _, ok := env.Get("function"); ok {
+		t.Fatalf("function should not be defined")
+	}
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment.")
+	}
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. got=%T (%+v)", obj, obj)
+	}
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("Wrong number of macro parameters. got=%d", len(macro.Parameters))
+	}
+	if macro.Parameters[0].String() != "x" || macro.Parameters[1].String() != "y" {
+		t.Fatalf("wrong parameters. got=%s, %s", macro.Parameters[0], macro.Parameters[1])
+	}
+	if macro.Body.String() != "(x + y)" {
+		t.Fatalf("body is not %q. got=%q", "(x + y)", macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let infixExpression = macro() { quote(1 + 2); };
+			infixExpression();`,
+			`(1 + 2)`,
+		},
+		{
+			`let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); };
+			reverse(2 + 2, 10 - 5);`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`let unless = macro(condition, consequence, alternative) {
+				quote(if (!(unquote(condition))) {
+					unquote(consequence);
+				} else {
+					unquote(alternative);
+				});
+			};
+			unless(10 > 5, puts("not greater"), puts("greater"));`,
+			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`,
+		},
+		{
+			//宏展开的结果中的宏调用也会被展开
+			`let twice = macro(x) { quote(unquote(x) + unquote(x)); };
+			let quad = macro(x) { quote(twice(twice(unquote(x)))); };
+			quad(1);`,
+			`((1 + 1) + (1 + 1))`,
+		},
+	}
+	for _, tt := range tests {
+		expected := testParseProgram(tt.expected)
+		program := testParseProgram(tt.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		expanded, err := ExpandMacros(program, env)
+		if err != nil {
+			t.Fatalf("expansion error for %q: %s", tt.input, err.Message)
+		}
+		if expanded.String() != expected.String() {
+			t.Errorf("not equal. want=%q, got=%q", expected.String(), expanded.String())
+		}
+	}
+}
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let m = macro(x) { 1 }; m(2)`, "macro m must return a quoted AST, got INTEGER"},
+		{`let m = macro(x) { quote(x) }; m()`, "wrong number of arguments to macro m. got=0, want=1"},
+		{`let m = macro() { throw "boom"; }; m()`, "boom"},
+		{`let m = macro() { quote(m()) }; m()`, "macro expansion is too deep (more than 100 levels)"},
+	}
+	for _, tt := range tests {
+		program := testParseProgram(tt.input)
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		_, err := ExpandMacros(program, env)
+		if err == nil {
+			t.Errorf("no error returned for %q", tt.input)
+			continue
+		}
+		if err.Message != tt.expected {
+			t.Errorf("wrong error message for %q. want=%q, got=%q", tt.input, tt.expected, err.Message)
+		}
+	}
+}
+
+func TestMacrosAtRuntime(t *testing.T) {
+	input := `
+	let unless = macro(cond, cons, alt) { quote(if (!(unquote(cond))) { unquote(cons) } else { unquote(alt) }) };
+	let x = 3;
+	unless(x > 5, x * 2, x)`
+	program := testParseProgram(input)
+	macros := object.NewEnvironment()
+	DefineMacros(program, macros)
+	expanded, err := ExpandMacros(program, macros)
+	if err != nil {
+		t.Fatalf("expansion error: %s", err.Message)
+	}
+	testIntegerObject(t, Eval(expanded, object.NewEnvironment()), 6)
+
+	if _, ok := testEval("let m = fn() { macro() { 1 } }; m()").(*object.Error); !ok {
+		t.Errorf("macro literal outside a top-level let should be an error")
+	}
+}
+
+func testParseProgram(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
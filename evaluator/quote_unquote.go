@@ -0,0 +1,104 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+	"monkey/token"
+)
+
+// evaluator/quote_unquote.go
+//
+//quote(<表达式>) 不对表达式求值，而是得到包装了语法树的Quote对象
+//quote中的unquote(<表达式>) 会被求值，结果转换为语法树后替换到原位置
+
+//isCallTo 是否是对名为name的标识符的调用
+func isCallTo(node ast.Node, name string) bool {
+	call, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == name
+}
+
+func evalQuote(node *ast.CallExpression, env *object.Environment) object.Object {
+	if len(node.Arguments) != 1 {
+		return newArgumentError("wrong number of arguments to quote. got=%d, want=1", len(node.Arguments))
+	}
+	//同一个quote可能被多次求值，在副本上替换unquote，不修改原来的语法树
+	quoted, err := copyNode(node.Arguments[0])
+	if err != nil {
+		return err
+	}
+	var unquoteErr *object.Error
+	quoted = ast.Modify(quoted, func(n ast.Node) ast.Node {
+		if unquoteErr != nil || !isCallTo(n, "unquote") {
+			return n
+		}
+		call := n.(*ast.CallExpression)
+		if len(call.Arguments) != 1 {
+			unquoteErr = newArgumentError("wrong number of arguments to unquote. got=%d, want=1", len(call.Arguments))
+			unquoteErr.Pos = call.Pos()
+			return n
+		}
+		value := Eval(call.Arguments[0], env)
+		if err, ok := value.(*object.Error); ok {
+			unquoteErr = err
+			return n
+		}
+		converted, err := objectToNode(value, call.Token)
+		if err != nil {
+			unquoteErr = err
+			return n
+		}
+		return converted
+	})
+	if unquoteErr != nil {
+		return unquoteErr
+	}
+	return &object.Quote{Node: quoted}
+}
+
+//copyNode 通过JSON编码复制语法树
+func copyNode(node ast.Node) (ast.Node, *object.Error) {
+	data, err := ast.EncodeJSON(node)
+	if err != nil {
+		return nil, &object.Error{Kind: object.INTERNAL_ERROR, Message: "cannot copy ast: " + err.Error()}
+	}
+	copied, err := ast.DecodeJSON(data)
+	if err != nil {
+		return nil, &object.Error{Kind: object.INTERNAL_ERROR, Message: "cannot copy ast: " + err.Error()}
+	}
+	return copied, nil
+}
+
+//objectToNode 将unquote的结果转换为语法树，新节点使用unquote调用的位置
+func objectToNode(obj object.Object, at token.Token) (ast.Node, *object.Error) {
+	tok := token.Token{Literal: obj.Inspect(), Pos: at.Pos, End: at.End}
+	switch obj := obj.(type) {
+	case *object.Integer:
+		tok.Type = token.INT
+		return &ast.IntegerLiteral{Token: tok, Value: obj.Value}, nil
+	case *object.BigInt:
+		tok.Type = token.INT
+		return &ast.IntegerLiteral{Token: tok, Big: obj.Value}, nil
+	case *object.Float:
+		tok.Type = token.FLOAT
+		return &ast.FloatLiteral{Token: tok, Value: obj.Value}, nil
+	case *object.Boolean:
+		tok.Type = token.FALSE
+		if obj.Value {
+			tok.Type = token.TRUE
+		}
+		return &ast.Boolean{Token: tok, Value: obj.Value}, nil
+	case *object.String:
+		tok.Type = token.STRING
+		return &ast.StringLiteral{Token: tok, Value: obj.Value}, nil
+	case *object.Quote:
+		return obj.Node, nil
+	default:
+		err := newTypeError("cannot unquote %s", obj.Type())
+		err.Pos = at.Pos
+		return nil, err
+	}
+}
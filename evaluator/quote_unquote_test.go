@@ -0,0 +1,85 @@
+package evaluator
+
+import (
+	"monkey/object"
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(5)`, `5`},
+		{`quote(5 + 8)`, `(5 + 8)`},
+		{`quote(foobar)`, `foobar`},
+		{`quote(foobar + barfoo)`, `(foobar + barfoo)`},
+	}
+	for _, tt := range tests {
+		testQuoteObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(unquote(4))`, `4`},
+		{`quote(unquote(4 + 4))`, `8`},
+		{`quote(8 + unquote(4 + 4))`, `(8 + 8)`},
+		{`quote(unquote(4 + 4) + 8)`, `(8 + 8)`},
+		{`let foobar = 8; quote(foobar)`, `foobar`},
+		{`let foobar = 8; quote(unquote(foobar))`, `8`},
+		{`quote(unquote(true))`, `true`},
+		{`quote(unquote(true == false))`, `false`},
+		{`quote(unquote("str"))`, `str`},
+		{`quote(unquote(1.5))`, `1.5`},
+		{`quote(unquote(quote(4 + 4)))`, `(4 + 4)`},
+		{`let quotedInfixExpression = quote(4 + 4);
+		  quote(unquote(4 + 4) + unquote(quotedInfixExpression))`, `(8 + (4 + 4))`},
+		//每次求值quote都得到新的语法树，不受之前unquote结果的影响
+		{`let f = fn(x) { quote(1 + unquote(x)) }; f(1); f(2)`, `(1 + 2)`},
+	}
+	for _, tt := range tests {
+		testQuoteObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestQuoteErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(1, 2)`, "wrong number of arguments to quote. got=2, want=1"},
+		{`quote(unquote())`, "wrong number of arguments to unquote. got=0, want=1"},
+		{`quote(unquote([1]))`, "cannot unquote ARRAY"},
+		{`quote(unquote(nope))`, "identifier not found: nope"},
+	}
+	for _, tt := range tests {
+		err, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error returned for %q", tt.input)
+			continue
+		}
+		if err.Message != tt.expected {
+			t.Errorf("wrong error message for %q. want=%q, got=%q", tt.input, tt.expected, err.Message)
+		}
+	}
+}
+
+func testQuoteObject(t *testing.T, obj object.Object, expected string) {
+	t.Helper()
+	quote, ok := obj.(*object.Quote)
+	if !ok {
+		t.Errorf("expected *object.Quote. got=%T (%+v)", obj, obj)
+		return
+	}
+	if quote.Node == nil {
+		t.Errorf("quote.Node is nil")
+		return
+	}
+	if quote.Node.String() != expected {
+		t.Errorf("not equal. got=%q, want=%q", quote.Node.String(), expected)
+	}
+}
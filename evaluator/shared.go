@@ -0,0 +1,40 @@
+package evaluator
+
+import "monkey/object"
+
+//以下函数供虚拟机使用，保证两种执行方式的运算结果一致
+
+//InfixOperation 计算 left <operator> right
+func InfixOperation(operator string, left object.Object, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+//PrefixOperation 计算 <operator>right
+func PrefixOperation(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+//IndexOperation 计算 left[index]
+func IndexOperation(left object.Object, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+//IsTruthy 条件判断时是否为真
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+//NativeBool 返回对应的布尔对象
+func NativeBool(input bool) *object.Boolean {
+	return nativeBoolToBooleanObject(input)
+}
+
+//Iterate 取出for-in循环依次访问的元素
+func Iterate(iterable object.Object) ([]object.Object, *object.Error) {
+	return iterableItems(iterable)
+}
+
+//Throw 将throw语句抛出的值转换为错误
+func Throw(val object.Object) *object.Error {
+	return throwValue(val)
+}
@@ -0,0 +1,16 @@
+//for循环示例
+let map = fn(arr,f){
+  let result = [];
+  for (x in arr) {
+    result = push(result,f(x));
+  }
+  result;
+};
+
+let sum = 0;
+for (let i = 1; i < 101; i = i + 1) {
+  sum = sum + i;
+}
+
+puts(map([1,2,3,4],fn(x){x*2}));
+puts(sum);
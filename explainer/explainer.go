@@ -1,39 +1,111 @@
 package explainer
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"monkey/ast"
+	"monkey/bytecode"
+	"monkey/compiler"
 	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
 	"monkey/repl"
+	"monkey/vm"
 )
 
-func printParserErrors(out io.Writer, errors []string) {
-	io.WriteString(out, repl.MONKEY_FACE)
-	io.WriteString(out, "Woops! We ran into some monkey business here!\n")
-	io.WriteString(out, " parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+func Start(in io.Reader, out io.Writer) {
+	StartFile("", in, out)
+}
+
+//StartFile 解释执行源文件，filename用于错误定位
+func StartFile(filename string, in io.Reader, out io.Writer) {
+	StartFileWithEngine(repl.EngineEval, filename, in, out)
+}
+
+//StartFileWithEngine 使用指定的引擎执行源文件
+func StartFileWithEngine(engine string, filename string, in io.Reader, out io.Writer) {
+	program := ExpandMacros(ParseFile(filename, in, out), out)
+	if program == nil {
+		return
+	}
+	if engine != repl.EngineVM {
+		env := object.NewEnvironment()
+		repl.PrintResult(out, evaluator.EvalContext(context.Background(), program, env, evaluator.Limits{}))
+		return
+	}
+	if bytecode := compileProgram(program, out); bytecode != nil {
+		RunBytecode(bytecode, out)
 	}
 }
 
-func Start(in io.Reader, out io.Writer) {
+//CompileFile 编译源文件，出错时输出错误并返回nil
+func CompileFile(filename string, in io.Reader, out io.Writer) *compiler.Bytecode {
+	program := ExpandMacros(ParseFile(filename, in, out), out)
+	if program == nil {
+		return nil
+	}
+	return compileProgram(program, out)
+}
+
+//StartBytecode 加载编译后的文件并由虚拟机执行
+func StartBytecode(in io.Reader, out io.Writer) {
+	bc, err := bytecode.Decode(in)
+	if err != nil {
+		fmt.Fprintf(out, "Woops! Loading bytecode failed:\n %s\n", err)
+		return
+	}
+	RunBytecode(bc, out)
+}
+
+//RunBytecode 由虚拟机执行字节码并输出结果
+func RunBytecode(bc *compiler.Bytecode, out io.Writer) {
+	machine := vm.New(bc)
+	if err := machine.Run(); err != nil {
+		fmt.Fprintf(out, "Woops! Executing bytecode failed:\n %s\n", err)
+		return
+	}
+	repl.PrintResult(out, machine.Result())
+}
+
+//ParseFile 解析源文件，有语法错误时输出错误并返回nil
+func ParseFile(filename string, in io.Reader, out io.Writer) *ast.Program {
 	bytes, err := io.ReadAll(in)
 	if err != nil {
 		fmt.Println(err)
 	}
 	codes := string(bytes)
-	l := lexer.New(codes)
+	l := lexer.NewWithFilename(filename, codes)
 	p := parser.New(l)
 	program := p.ParseProgram()
-	evaluated := evaluator.Eval(program, object.NewEnvironment())
-	if len(p.Errors()) != 0 {
-		printParserErrors(out, p.Errors())
+	if len(p.Diagnostics()) != 0 {
+		repl.PrintParserErrors(out, codes, p.Diagnostics())
+		return nil
+	}
+	return program
+}
+
+//ExpandMacros 展开程序中的宏，出错时输出错误并返回nil
+func ExpandMacros(program *ast.Program, out io.Writer) *ast.Program {
+	if program == nil {
+		return nil
+	}
+	env := object.NewEnvironment()
+	evaluator.DefineMacros(program, env)
+	expanded, err := evaluator.ExpandMacrosContext(context.Background(), program, env, evaluator.Limits{})
+	if err != nil {
+		repl.PrintResult(out, err)
+		return nil
 	}
-	if evaluated != nil {
-		io.WriteString(out, evaluated.Inspect())
-		io.WriteString(out, "\n")
+	return expanded.(*ast.Program)
+}
+
+func compileProgram(program *ast.Program, out io.Writer) *compiler.Bytecode {
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		fmt.Fprintf(out, "Woops! Compilation failed:\n %s\n", err)
+		return nil
 	}
+	return comp.Bytecode()
 }
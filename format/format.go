@@ -0,0 +1,82 @@
+package format
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+)
+
+// format/format.go
+//
+//将语法树输出为统一风格的源码：两个空格缩进，语句块中每条语句占一行，
+//数组、哈希和调用的参数超出行宽或其中有注释时每个元素占一行，注释保留在语句或元素之间或行尾
+
+const (
+	indentString = "  " //每一级缩进
+	maxWidth     = 80   //行宽，超出时数组、哈希和参数列表换行
+)
+
+//Error 源码有语法错误，无法格式化
+type Error struct {
+	Diagnostics []*parser.Diagnostic
+}
+
+func (e *Error) Error() string {
+	messages := []string{}
+	for _, d := range e.Diagnostics {
+		messages = append(messages, d.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+//Source 格式化源码，filename用于错误定位，有语法错误时返回*Error
+func Source(filename string, src []byte) ([]byte, error) {
+	p := parser.New(lexer.NewWithComments(filename, string(src)))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		return nil, &Error{Diagnostics: p.Diagnostics()}
+	}
+	pr := &printer{src: string(src), comments: p.Comments(), brackets: matchBrackets(string(src))}
+	pr.statements(program.Statements, len(src)+1)
+	if pr.out.Len() > 0 {
+		pr.out.WriteString("\n")
+	}
+	return pr.out.Bytes(), nil
+}
+
+//Node 格式化语法树，没有源码可用，因此不包含注释
+func Node(node ast.Node) string {
+	p := &printer{}
+	switch node := node.(type) {
+	case *ast.Program:
+		p.statements(node.Statements, 0)
+	case *ast.BlockStatement:
+		p.block(node)
+	case ast.Statement:
+		p.statements([]ast.Statement{node}, 0)
+	case ast.Expression:
+		p.expr(node)
+	}
+	return p.out.String()
+}
+
+//matchBrackets 源码中每个左括号对应的右括号的偏移，用于确定语句块和列表的结尾，源码没有语法错误因此括号一定正确嵌套
+func matchBrackets(src string) map[int]int {
+	brackets := map[int]int{}
+	stack := []int{}
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LBRACE, token.LBRACKET, token.LPAREN:
+			stack = append(stack, tok.Pos.Offset)
+		case token.RBRACE, token.RBRACKET, token.RPAREN:
+			if len(stack) > 0 {
+				brackets[stack[len(stack)-1]] = tok.Pos.Offset
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	return brackets
+}
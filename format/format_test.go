@@ -0,0 +1,212 @@
+package format
+
+import (
+	"monkey/lexer"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// format/format_test.go
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=1+2*3", "let x = 1 + 2 * 3;\n"},
+		{"(1+2)*3; 1-(2-3); (1-2)-3; -(a+b); -(-1); !!x", "(1 + 2) * 3;\n1 - (2 - 3);\n1 - 2 - 3;\n-(a + b);\n-(-1);\n!!x;\n"},
+		{"f(1)(2)[0]; (-f)(x); x = y = 1", "f(1)(2)[0];\n(-f)(x);\nx = y = 1;\n"},
+		{"let f=fn(a,b=1,...c){a}", "let f = fn(a, b = 1, ...c) {\n  a;\n};\n"},
+		{"if(x){1}else{2}", "if (x) {\n  1;\n} else {\n  2;\n}\n"},
+		//下一条语句以 ( [ - 开始时不能省略分号，否则会被解析为同一个表达式
+		{"if(x){1}; -1; if(x){1}; [1]; if(x){1}; y", "if (x) {\n  1;\n};\n-1;\nif (x) {\n  1;\n};\n[1];\nif (x) {\n  1;\n}\ny;\n"},
+		{"for(let i=0;i<3;i=i+1){} for(;;){break} outer:for(x in xs){continue outer}",
+			"for (let i = 0; i < 3; i = i + 1) {}\nfor (;;) {\n  break;\n}\nouter: for (x in xs) {\n  continue outer;\n}\n"},
+		{`try{throw "x"}catch(e){e}finally{}; try{1}catch{2}`,
+			"try {\n  throw \"x\";\n} catch (e) {\n  e;\n} finally {}\ntry {\n  1;\n} catch {\n  2;\n}\n"},
+		{`{"b":1,"a":[1,2]}; {}; []`, "{\"b\": 1, \"a\": [1, 2]};\n{};\n[];\n"},
+		{"let m=macro(a){quote(unquote(a))}", "let m = macro(a) {\n  quote(unquote(a));\n};\n"},
+	}
+	for _, tt := range tests {
+		out, err := Source("", []byte(tt.input))
+		if err != nil {
+			t.Fatalf("format error for %q: %s", tt.input, err)
+		}
+		if string(out) != tt.expected {
+			t.Errorf("wrong output for %q.\nwant=%q\ngot=%q", tt.input, tt.expected, out)
+		}
+	}
+}
+
+func TestSourceComments(t *testing.T) {
+	input := `// 文件头
+
+let a = 1; // 行尾
+// 函数前
+let f = fn() {
+  // 函数体开头
+  a;
+
+
+  a // 最后一条语句
+  // 函数体结尾
+};
+// 文件末尾`
+	expected := `// 文件头
+
+let a = 1; // 行尾
+// 函数前
+let f = fn() {
+  // 函数体开头
+  a;
+
+  a; // 最后一条语句
+  // 函数体结尾
+};
+// 文件末尾
+`
+	out, err := Source("", []byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != expected {
+		t.Errorf("wrong output.\nwant=%s\ngot=%s", expected, out)
+	}
+}
+
+func TestSourceLiteralComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		//哈希中的注释留在所属的键值对旁边，有注释时不合并为一行
+		{`let h = {"a": 1, // trailing a
+// before b
+"b": 2};`, `let h = {
+  "a": 1, // trailing a
+  // before b
+  "b": 2
+};
+`},
+		{`let xs = [
+  // first
+  1,
+  [2, 3] // nested
+  // last
+];`, `let xs = [
+  // first
+  1,
+  [2, 3] // nested
+  // last
+];
+`},
+		{"let e = {\n// nothing\n};", "let e = {\n  // nothing\n};\n"},
+		{"f(1, // one\n2)", "f(\n  1, // one\n  2\n);\n"},
+		//参数列表中的注释跟随参数，不会移到函数体中
+		{"let f = fn(a, // c\n b) { a + b };", "let f = fn(\n  a, // c\n  b\n) {\n  a + b;\n};\n"},
+		{"let f = fn(\n// first\na, b = 1, ...rest // rest\n) { a };", "let f = fn(\n  // first\n  a,\n  b = 1,\n  ...rest // rest\n) {\n  a;\n};\n"},
+		{"let m = macro(a, // c\n b) { a };", "let m = macro(\n  a, // c\n  b\n) {\n  a;\n};\n"},
+		//元素内部语句块中的注释不影响列表的布局
+		{"map(xs, fn(x) {\n// body\nx})", "map(xs, fn(x) {\n  // body\n  x;\n});\n"},
+		//语句块之后的注释不会移到else、catch的语句块中
+		{"if (x) { 1 } // after if\nelse { 2 }", "if (x) {\n  1;\n} // after if\nelse {\n  2;\n}\n"},
+		{"try { 1 }\n// before catch\ncatch (e) { 2 } finally { 3 }",
+			"try {\n  1;\n}\n// before catch\ncatch (e) {\n  2;\n} finally {\n  3;\n}\n"},
+	}
+	for _, tt := range tests {
+		out, err := Source("", []byte(tt.input))
+		if err != nil {
+			t.Fatalf("format error for %q: %s", tt.input, err)
+		}
+		if string(out) != tt.expected {
+			t.Errorf("wrong output for %q.\nwant=%s\ngot=%s", tt.input, tt.expected, out)
+		}
+		again, err := Source("", out)
+		if err != nil || string(again) != string(out) {
+			t.Errorf("formatting %q is not idempotent. got=%s", tt.input, again)
+		}
+	}
+}
+
+func TestSourceWrapping(t *testing.T) {
+	input := `let person = {"name": "Alice", "age": 24, "tags": ["admin", "staff"], "city": "Wonderland"};
+let xs = [1, fn(x) { x }];
+map(xs, fn(x) { x * 2 });`
+	expected := `let person = {
+  "name": "Alice",
+  "age": 24,
+  "tags": ["admin", "staff"],
+  "city": "Wonderland"
+};
+let xs = [
+  1,
+  fn(x) {
+    x;
+  }
+];
+map(xs, fn(x) {
+  x * 2;
+});
+`
+	out, err := Source("", []byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != expected {
+		t.Errorf("wrong output.\nwant=%s\ngot=%s", expected, out)
+	}
+}
+
+func TestSourceIdempotent(t *testing.T) {
+	files, err := filepath.Glob("../examples/*.mk")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no examples found: %v", err)
+	}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := Source(file, src)
+		if err != nil {
+			t.Fatalf("format error for %s: %s", file, err)
+		}
+		again, err := Source(file, out)
+		if err != nil {
+			t.Fatalf("formatted %s does not parse: %s", file, err)
+		}
+		if string(again) != string(out) {
+			t.Errorf("formatting %s is not idempotent.\nfirst=%s\nsecond=%s", file, out, again)
+		}
+		//格式化不改变程序的含义
+		before := parser.New(lexer.New(string(src))).ParseProgram()
+		after := parser.New(lexer.New(string(out))).ParseProgram()
+		if before.String() != after.String() {
+			t.Errorf("formatting %s changed the program.\nbefore=%s\nafter=%s", file, before, after)
+		}
+	}
+}
+
+func TestSourceSyntaxError(t *testing.T) {
+	_, err := Source("bad.mk", []byte("let = 1;"))
+	ferr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expected *Error. got=%T (%v)", err, err)
+	}
+	if len(ferr.Diagnostics) != 1 || ferr.Error() != "bad.mk:1:5: expected next token to be IDENT, got = instead" {
+		t.Errorf("wrong error. got=%q", ferr.Error())
+	}
+}
+
+func TestNode(t *testing.T) {
+	program := parser.New(lexer.New("let x = fn(a) { a + 1 }; x(2)")).ParseProgram()
+	expected := "let x = fn(a) {\n  a + 1;\n};\nx(2);"
+	if got := Node(program); got != expected {
+		t.Errorf("wrong output.\nwant=%q\ngot=%q", expected, got)
+	}
+	if got := Node(program.Statements[1]); got != "x(2);" {
+		t.Errorf("wrong statement output. got=%q", got)
+	}
+}
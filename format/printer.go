@@ -0,0 +1,548 @@
+package format
+
+import (
+	"bytes"
+	"monkey/ast"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+)
+
+//printer 按顺序输出格式化后的源码
+type printer struct {
+	out    bytes.Buffer
+	indent int  //当前缩进级数
+	fresh  bool //刚换行，写入内容前需要先缩进
+
+	src      string        //原始源码，用于判断空行和行尾注释，可为空
+	comments []token.Token //尚未输出的注释，按位置排列
+	brackets map[int]int   //左括号到对应右括号的偏移
+}
+
+func (p *printer) write(s string) {
+	if p.fresh {
+		p.out.WriteString(strings.Repeat(indentString, p.indent))
+		p.fresh = false
+	}
+	p.out.WriteString(s)
+}
+
+func (p *printer) newline() {
+	p.out.WriteString("\n")
+	p.fresh = true
+}
+
+//column 当前行已经输出的宽度
+func (p *printer) column() int {
+	b := p.out.Bytes()
+	width := len(b) - bytes.LastIndexByte(b, '\n') - 1
+	if p.fresh {
+		width += len(indentString) * p.indent
+	}
+	return width
+}
+
+//startLine 为源码中位于offset的语句或注释另起一行，原来前面有空行时保留一个空行
+func (p *printer) startLine(offset int, first bool) {
+	if p.out.Len() == 0 {
+		return
+	}
+	if !first && p.blankBefore(offset) {
+		p.newline()
+	}
+	p.newline()
+}
+
+//blankBefore 源码中offset之前是否是空行
+func (p *printer) blankBefore(offset int) bool {
+	newlines := 0
+	for i := offset - 1; i >= 0 && i < len(p.src); i-- {
+		switch p.src[i] {
+		case '\n':
+			newlines++
+		case ' ', '\t', '\r':
+		default:
+			return newlines > 1
+		}
+	}
+	return false
+}
+
+//trailing 注释前面同一行中是否有代码，即是否是行尾注释
+func (p *printer) trailing(comment token.Token) bool {
+	for i := comment.Pos.Offset - 1; i >= 0 && i < len(p.src); i-- {
+		switch p.src[i] {
+		case '\n':
+			return false
+		case ' ', '\t', '\r':
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+//commentBefore 是否还有位于offset之前的注释
+func (p *printer) commentBefore(offset int) bool {
+	return len(p.comments) > 0 && p.comments[0].Pos.Offset < offset
+}
+
+func (p *printer) popComment() string {
+	comment := p.comments[0]
+	p.comments = p.comments[1:]
+	return strings.TrimRight(comment.Literal, " \t")
+}
+
+//statements 输出语句列表，end为列表在源码中的结尾，之前的注释都属于这个列表
+func (p *printer) statements(list []ast.Statement, end int) {
+	first := true
+	for i, stmt := range list {
+		offset := stmt.Pos().Offset
+		for p.commentBefore(offset) {
+			p.startLine(p.comments[0].Pos.Offset, first)
+			p.write(p.popComment())
+			first = false
+		}
+		p.startLine(offset, first)
+		first = false
+		p.statement(stmt)
+		var next ast.Statement
+		boundary := end
+		if i+1 < len(list) {
+			next = list[i+1]
+			boundary = next.Pos().Offset
+		}
+		if needsSemicolon(stmt, next) {
+			p.write(";")
+		}
+		//语句之后同一行中的注释留在行尾，语句内部的其他注释依次放在语句之后
+		trailing := true
+		for p.commentBefore(boundary) && p.trailing(p.comments[0]) {
+			if trailing {
+				p.write(" ")
+			} else {
+				p.newline()
+			}
+			p.write(p.popComment())
+			trailing = false
+		}
+	}
+	for p.commentBefore(end) {
+		p.startLine(p.comments[0].Pos.Offset, first)
+		p.write(p.popComment())
+		first = false
+	}
+}
+
+//needsSemicolon 以语句块结尾的表达式语句省略分号，除非下一条语句会被解析为它的延续
+func needsSemicolon(stmt ast.Statement, next ast.Statement) bool {
+	switch stmt := stmt.(type) {
+	case *ast.ForStatement, *ast.ForInStatement:
+		return false
+	case *ast.ExpressionStatement:
+		switch stmt.Expression.(type) {
+		case *ast.IfExpression, *ast.TryExpression, *ast.FunctionLiteral, *ast.MacroLiteral:
+			nextStmt, ok := next.(*ast.ExpressionStatement)
+			if !ok || nextStmt.Expression == nil {
+				return false
+			}
+			switch startChar(nextStmt.Expression) {
+			case '(', '[', '-':
+				return true
+			}
+			return false
+		}
+	}
+	return true
+}
+
+//startChar 表达式输出后的第一个字符
+func startChar(e ast.Expression) byte {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		if precedence(e.Left) < precedence(e) {
+			return '('
+		}
+		return startChar(e.Left)
+	case *ast.CallExpression:
+		if precedence(e.Function) < parser.CALL {
+			return '('
+		}
+		return startChar(e.Function)
+	case *ast.IndexExpression:
+		if precedence(e.Left) < parser.INDEX {
+			return '('
+		}
+		return startChar(e.Left)
+	case *ast.PrefixExpression:
+		return e.Operator[0]
+	case *ast.ArrayLiteral:
+		return '['
+	case *ast.HashLiteral:
+		return '{'
+	}
+	return 0
+}
+
+//precedence 表达式作为操作数时的优先级，低于所在运算符时需要加括号
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(token.TokenType(e.Operator))
+	case *ast.AssignStatement:
+		return parser.ASSIGN
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	}
+	return parser.INDEX + 1
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.write("let " + stmt.Name.Value + " = ")
+		p.expr(stmt.Value)
+	case *ast.ReturnStatement:
+		p.write("return")
+		if stmt.ReturnValue != nil {
+			p.write(" ")
+			p.expr(stmt.ReturnValue)
+		}
+	case *ast.ThrowStatement:
+		p.write("throw ")
+		p.expr(stmt.Value)
+	case *ast.ExpressionStatement:
+		p.expr(stmt.Expression)
+	case *ast.BreakStatement:
+		p.write("break")
+		p.label(stmt.Label)
+	case *ast.ContinueStatement:
+		p.write("continue")
+		p.label(stmt.Label)
+	case *ast.ForStatement:
+		if stmt.Label != nil {
+			p.write(stmt.Label.Value + ": ")
+		}
+		p.write("for (")
+		if stmt.Init != nil {
+			p.statement(stmt.Init)
+		}
+		p.write(";")
+		if stmt.Condition != nil {
+			p.write(" ")
+			p.expr(stmt.Condition)
+		}
+		p.write(";")
+		if stmt.Post != nil {
+			p.write(" ")
+			p.expr(stmt.Post)
+		}
+		p.write(") ")
+		p.block(stmt.Body)
+	case *ast.ForInStatement:
+		if stmt.Label != nil {
+			p.write(stmt.Label.Value + ": ")
+		}
+		p.write("for (" + stmt.Variable.Value + " in ")
+		p.expr(stmt.Iterable)
+		p.write(") ")
+		p.block(stmt.Body)
+	}
+}
+
+func (p *printer) label(label *ast.Identifier) {
+	if label != nil {
+		p.write(" " + label.Value)
+	}
+}
+
+//closing 源码中位于offset的左括号对应的右括号的偏移，没有源码时为-1
+func (p *printer) closing(offset int) int {
+	end, ok := p.brackets[offset]
+	if !ok {
+		return -1
+	}
+	return end
+}
+
+//between 输出位于offset之前的注释，用于语句块之间的注释，如if语句块与else之间
+//输出了注释时另起一行并返回true
+func (p *printer) between(offset int) bool {
+	if !p.commentBefore(offset) {
+		return false
+	}
+	for p.commentBefore(offset) {
+		if p.trailing(p.comments[0]) {
+			p.write(" ")
+		} else {
+			p.newline()
+		}
+		p.write(p.popComment())
+	}
+	p.newline()
+	return true
+}
+
+//keyword 在语句块之后输出else、catch等关键字，关键字之前的注释保留在语句块之后
+func (p *printer) keyword(word string, offset int) {
+	if p.between(offset) {
+		p.write(word + " ")
+		return
+	}
+	p.write(" " + word + " ")
+}
+
+//block 输出语句块，空的语句块输出为{}
+func (p *printer) block(b *ast.BlockStatement) {
+	end := p.closing(b.Token.Pos.Offset)
+	if len(b.Statements) == 0 && !p.commentBefore(end) {
+		p.write("{}")
+		return
+	}
+	p.write("{")
+	p.indent++
+	p.statements(b.Statements, end)
+	p.indent--
+	p.newline()
+	p.write("}")
+}
+
+//operand 输出操作数，优先级低于min时加括号
+func (p *printer) operand(e ast.Expression, min int) {
+	if precedence(e) < min {
+		p.write("(")
+		p.expr(e)
+		p.write(")")
+		return
+	}
+	p.expr(e)
+}
+
+func (p *printer) expr(e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		p.write(e.Value)
+	case *ast.IntegerLiteral, *ast.FloatLiteral:
+		p.write(e.String())
+	case *ast.StringLiteral:
+		p.write(`"` + e.Value + `"`)
+	case *ast.Boolean:
+		if e.Value {
+			p.write("true")
+		} else {
+			p.write("false")
+		}
+	case *ast.PrefixExpression:
+		p.write(e.Operator)
+		//避免连续的负号 --x
+		if right, ok := e.Right.(*ast.PrefixExpression); ok && e.Operator == "-" && right.Operator == "-" {
+			p.write("(")
+			p.expr(right)
+			p.write(")")
+			return
+		}
+		p.operand(e.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		//运算符左结合，右侧同优先级的操作数也需要括号
+		prec := precedence(e)
+		p.operand(e.Left, prec)
+		p.write(" " + e.Operator + " ")
+		p.operand(e.Right, prec+1)
+	case *ast.AssignStatement:
+		p.write(e.Name.Value + " = ")
+		p.expr(e.Value)
+	case *ast.CallExpression:
+		p.operand(e.Function, parser.CALL)
+		p.list("(", ")", e.Token.Pos.Offset, exprOffsets(e.Arguments), false, func(i int) { p.expr(e.Arguments[i]) })
+	case *ast.IndexExpression:
+		p.operand(e.Left, parser.INDEX)
+		p.write("[")
+		p.expr(e.Index)
+		p.write("]")
+	case *ast.ArrayLiteral:
+		p.list("[", "]", e.Token.Pos.Offset, exprOffsets(e.Elements), true, func(i int) { p.expr(e.Elements[i]) })
+	case *ast.HashLiteral:
+		keys := ast.SortedKeys(e.Pairs)
+		p.list("{", "}", e.Token.Pos.Offset, exprOffsets(keys), true, func(i int) {
+			p.expr(keys[i])
+			p.write(": ")
+			p.expr(e.Pairs[keys[i]])
+		})
+	case *ast.FunctionLiteral:
+		p.write("fn")
+		p.parameters(p.paren(e.Token.Pos.Offset), e.Parameters, e.Defaults, e.Rest)
+		p.write(" ")
+		p.block(e.Body)
+	case *ast.MacroLiteral:
+		p.write("macro")
+		p.parameters(p.paren(e.Token.Pos.Offset), e.Parameters, nil, nil)
+		p.write(" ")
+		p.block(e.Body)
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expr(e.Condition)
+		p.write(") ")
+		p.block(e.Consequence)
+		if e.Alternative != nil {
+			p.keyword("else", e.Alternative.Token.Pos.Offset)
+			p.block(e.Alternative)
+		}
+	case *ast.TryExpression:
+		p.write("try ")
+		p.block(e.Block)
+		if e.Catch != nil {
+			p.keyword("catch", e.Catch.Token.Pos.Offset)
+			if e.CatchParam != nil {
+				p.write("(" + e.CatchParam.Value + ") ")
+			}
+			p.block(e.Catch)
+		}
+		if e.Finally != nil {
+			p.keyword("finally", e.Finally.Token.Pos.Offset)
+			p.block(e.Finally)
+		}
+	}
+}
+
+//paren 源码中offset之后第一个左括号的偏移，用于找到参数列表的开头，没有源码时为-1
+func (p *printer) paren(offset int) int {
+	for i := offset; i >= 0 && i < len(p.src); i++ {
+		if _, ok := p.brackets[i]; ok && p.src[i] == '(' {
+			return i
+		}
+	}
+	return -1
+}
+
+//parameters 输出参数列表，start为左括号在源码中的偏移，参数之间的注释跟随参数
+func (p *printer) parameters(start int, params []*ast.Identifier, defaults map[string]ast.Expression, rest *ast.Identifier) {
+	offsets := []int{}
+	for _, param := range params {
+		offsets = append(offsets, param.Pos().Offset)
+	}
+	if rest != nil {
+		offsets = append(offsets, rest.Pos().Offset)
+	}
+	p.list("(", ")", start, offsets, false, func(i int) {
+		if i == len(params) {
+			p.write("..." + rest.Value)
+			return
+		}
+		p.write(params[i].Value)
+		if def, ok := defaults[params[i].Value]; ok {
+			p.write(" = ")
+			p.expr(def)
+		}
+	})
+}
+
+//exprOffsets 表达式在源码中的偏移
+func exprOffsets(list []ast.Expression) []int {
+	offsets := make([]int, len(list))
+	for i, e := range list {
+		offsets[i] = e.Pos().Offset
+	}
+	return offsets
+}
+
+//list 输出以逗号分隔的列表，放在一行超出行宽时每个元素占一行
+//wrapMultiline为true时，多个元素中有跨行的元素也会每个元素占一行
+//start为左括号在源码中的偏移，未知时为-1，offsets为各元素的偏移
+//元素之间有注释时每个元素占一行，注释放在下一个元素之前或所在行的行尾
+func (p *printer) list(open, close string, start int, offsets []int, wrapMultiline bool, item func(i int)) {
+	n := len(offsets)
+	end := p.closing(start)
+	commented := p.commentedList(start, end)
+	if n == 0 && !commented {
+		p.write(open + close)
+		return
+	}
+	if !commented && p.fits(open, close, n, wrapMultiline, item) {
+		p.write(open)
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				p.write(", ")
+			}
+			item(i)
+		}
+		p.write(close)
+		return
+	}
+	p.write(open)
+	p.indent++
+	for i := 0; i < n; i++ {
+		for p.commentBefore(offsets[i]) {
+			p.newline()
+			p.write(p.popComment())
+		}
+		p.newline()
+		item(i)
+		if i < n-1 {
+			p.write(",")
+		}
+		//元素之后同一行中的注释留在行尾
+		boundary := end
+		if i+1 < n {
+			boundary = offsets[i+1]
+		}
+		for p.commentBefore(boundary) && p.trailing(p.comments[0]) {
+			p.write(" " + p.popComment())
+		}
+	}
+	for p.commentBefore(end) {
+		p.newline()
+		p.write(p.popComment())
+	}
+	p.indent--
+	p.newline()
+	p.write(close)
+}
+
+//commentedList 源码中start和end之间的列表是否直接包含注释，元素内部括号中的注释不算
+func (p *printer) commentedList(start, end int) bool {
+	for _, comment := range p.comments {
+		offset := comment.Pos.Offset
+		if offset >= end {
+			return false
+		}
+		if offset <= start {
+			continue
+		}
+		nested := false
+		for open, close := range p.brackets {
+			if open > start && open < offset && offset < close && close < end {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			return true
+		}
+	}
+	return false
+}
+
+//fits 在不带注释的printer中试排，判断列表能否放在当前行中
+func (p *printer) fits(open, close string, n int, wrapMultiline bool, item func(i int)) bool {
+	save := *p
+	*p = printer{}
+	p.write(open)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			p.write(", ")
+		}
+		item(i)
+	}
+	p.write(close)
+	inline := p.out.String()
+	*p = save
+
+	firstLine := inline
+	if i := strings.IndexByte(inline, '\n'); i >= 0 {
+		firstLine = inline[:i]
+		if wrapMultiline && n > 1 {
+			return false
+		}
+	}
+	return p.column()+len(firstLine) <= maxWidth
+}
@@ -0,0 +1,49 @@
+package frame
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// frame/frame.go
+//
+//语言服务器和调试适配器共用的消息分帧：每条消息前有 Content-Length 头，头与内容之间以空行分隔
+
+//MaxContentLength 一条消息内容的最大字节数，超过时不分配内存，直接返回错误
+const MaxContentLength = 64 << 20
+
+//Read 读取一条消息的内容，输入结束时返回io.EOF
+func Read(r *bufio.Reader) ([]byte, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF || (err == io.ErrUnexpectedEOF && len(header) == 0) {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length: %q", header.Get("Content-Length"))
+	}
+	if length > MaxContentLength {
+		return nil, fmt.Errorf("Content-Length %d exceeds limit %d", length, MaxContentLength)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+//Write 写出一条消息的头和内容
+func Write(w io.Writer, body []byte) error {
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
@@ -0,0 +1,52 @@
+package frame
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadWrite(t *testing.T) {
+	var buf bytes.Buffer
+	for _, body := range []string{`{"a":1}`, ``, `[]`} {
+		if err := Write(&buf, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := bufio.NewReader(&buf)
+	for _, want := range []string{`{"a":1}`, ``, `[]`} {
+		body, err := Read(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != want {
+			t.Errorf("wrong body. want=%q, got=%q", want, body)
+		}
+	}
+	if _, err := Read(r); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Content-Length: x\r\n\r\n", `invalid Content-Length: "x"`},
+		{"Content-Length: -1\r\n\r\n", `invalid Content-Length: "-1"`},
+		{"Content-Type: text\r\n\r\n", `invalid Content-Length: ""`},
+		{fmt.Sprintf("Content-Length: %d\r\n\r\n", MaxContentLength+1), "exceeds limit"},
+		{"Content-Length: 9223372036854775807\r\n\r\n", "exceeds limit"},
+		{"Content-Length: 10\r\n\r\n{}", "unexpected EOF"},
+	}
+	for _, tt := range tests {
+		_, err := Read(bufio.NewReader(strings.NewReader(tt.input)))
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("wrong error for %q. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package interpreter
+
+import (
+	"fmt"
+	"monkey/evaluator"
+	"monkey/marshal"
+	"monkey/object"
+	"reflect"
+	"sort"
+)
+
+// interpreter/builtin.go
+//
+//将Go函数注册为Monkey中的函数。普通的Go函数通过反射包装：
+//实参按参数类型从Monkey对象转换，返回值转换为Monkey对象，最后一个返回值为error且不为nil时转换为错误。
+//值的转换规则见marshal包。
+//注册的函数是该解释器的内置函数：与标准的内置函数一样可以被let绑定遮盖，不是全局变量（Get取不到），
+//同名时优先于标准的内置函数。虚拟机按下标引用内置函数，因此注册的函数不能在vm引擎中使用。
+//lint不认识注册的函数，检查时通过lint.Options.Builtins传入Builtins的结果
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+//Register 将fn注册为内置函数name，只对该解释器生效，宏中也可以使用，fn可以是object.BuiltinFunction、*object.Builtin或普通的Go函数
+func (i *Interpreter) Register(name string, fn interface{}) error {
+	builtin, err := NewBuiltin(name, fn)
+	if err != nil {
+		return err
+	}
+	i.env.SetBuiltin(name, builtin)
+	i.macroEnv.SetBuiltin(name, builtin)
+	i.builtins[name] = true
+	return nil
+}
+
+//Builtins 按名称排序的注册的内置函数名
+func (i *Interpreter) Builtins() []string {
+	names := make([]string, 0, len(i.builtins))
+	for name := range i.builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//NewBuiltin 将fn包装为内置函数，name用于错误信息
+func NewBuiltin(name string, fn interface{}) (*object.Builtin, error) {
+	switch fn := fn.(type) {
+	case *object.Builtin:
+		return fn, nil
+	case object.BuiltinFunction:
+		return &object.Builtin{Fn: fn}, nil
+	case func(args ...object.Object) object.Object:
+		return &object.Builtin{Fn: fn}, nil
+	}
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("%s: cannot register %T as a function", name, fn)
+	}
+	t := v.Type()
+	results := t.NumOut()
+	returnsError := results > 0 && t.Out(results-1) == errorType
+	if returnsError {
+		results--
+	}
+	if results > 1 {
+		return nil, fmt.Errorf("%s: function must return at most one value and an error, got %s", name, t)
+	}
+	return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		in, err := convertArgs(name, t, args)
+		if err != nil {
+			return err
+		}
+		out := v.Call(in)
+		if returnsError {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return &object.Error{Kind: object.GENERIC_ERROR, Message: err.Error()}
+			}
+			out = out[:len(out)-1]
+		}
+		if len(out) == 0 {
+			return evaluator.NULL
+		}
+		result, convErr := marshal.Marshal(out[0].Interface())
+		if convErr != nil {
+			return &object.Error{Kind: object.TYPE_ERROR, Message: fmt.Sprintf("result of %s: %s", name, convErr)}
+		}
+		return result
+	}}, nil
+}
+
+//convertArgs 检查实参个数并转换为Go函数的参数
+func convertArgs(name string, t reflect.Type, args []object.Object) ([]reflect.Value, *object.Error) {
+	required := t.NumIn()
+	if t.IsVariadic() {
+		required--
+	}
+	if len(args) < required || (!t.IsVariadic() && len(args) > required) {
+		want := fmt.Sprintf("%d", required)
+		if t.IsVariadic() {
+			want = fmt.Sprintf("at least %d", required)
+		}
+		return nil, &object.Error{Kind: object.ARGUMENT_ERROR,
+			Message: fmt.Sprintf("wrong number of arguments to %s. got=%d, want=%s", name, len(args), want)}
+	}
+	in := make([]reflect.Value, len(args))
+	for idx, arg := range args {
+		var paramType reflect.Type
+		if t.IsVariadic() && idx >= required {
+			paramType = t.In(required).Elem()
+		} else {
+			paramType = t.In(idx)
+		}
+		value, err := fromObject(arg, paramType)
+		if err != nil {
+			return nil, &object.Error{Kind: object.TYPE_ERROR,
+				Message: fmt.Sprintf("argument %d to %s: %s", idx+1, name, err)}
+		}
+		in[idx] = value
+	}
+	return in, nil
+}
+
+//fromObject 将Monkey对象转换为类型为t的Go值
+func fromObject(obj object.Object, t reflect.Type) (reflect.Value, error) {
+	ptr := reflect.New(t)
+	if err := marshal.Unmarshal(obj, ptr.Interface()); err != nil {
+		return reflect.Value{}, err
+	}
+	return ptr.Elem(), nil
+}
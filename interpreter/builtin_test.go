@@ -0,0 +1,217 @@
+package interpreter
+
+import (
+	"errors"
+	"monkey/lint"
+	"monkey/object"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	i, _, _ := newTestInterpreter()
+	register := func(name string, fn interface{}) {
+		t.Helper()
+		if err := i.Register(name, fn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	register("hasPrefix", func(s string, n int64) (bool, error) {
+		if n < 0 {
+			return false, errors.New("negative length")
+		}
+		return strings.HasPrefix(s, strings.Repeat("a", int(n))), nil
+	})
+	register("raw", object.BuiltinFunction(func(args ...object.Object) object.Object {
+		return &object.Integer{Value: int64(len(args))}
+	}))
+	register("rawFunc", func(args ...object.Object) object.Object {
+		return &object.String{Value: "raw"}
+	})
+	register("builtin", &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		return &object.Integer{Value: 7}
+	}})
+	register("sum", func(base float64, xs ...int) float64 {
+		for _, x := range xs {
+			base += float64(x)
+		}
+		return base
+	})
+	register("words", func(s string) []string { return strings.Fields(s) })
+	register("counts", func(words []string) map[string]int {
+		counts := map[string]int{}
+		for _, w := range words {
+			counts[w]++
+		}
+		return counts
+	})
+	register("total", func(m map[string]int) int {
+		total := 0
+		for _, n := range m {
+			total += n
+		}
+		return total
+	})
+	register("describe", func(v interface{}) string {
+		switch v := v.(type) {
+		case nil:
+			return "nil"
+		case []interface{}:
+			return "slice"
+		case map[string]interface{}:
+			return "map"
+		default:
+			return typeName(v)
+		}
+	})
+	register("identity", func(obj object.Object) object.Object { return obj })
+	register("nothing", func() {})
+	register("fail", func() error { return errors.New("boom") })
+	register("maybe", func(ok bool) interface{} {
+		if ok {
+			return uint8(1)
+		}
+		return nil
+	})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`hasPrefix("aab", 2)`, "true"},
+		{`hasPrefix("abb", 2)`, "false"},
+		{`if (hasPrefix("a", 1)) { "yes" } else { "no" }`, "yes"},
+		{`hasPrefix("a", 1) == true`, "true"},
+		{`raw(1, 2, 3)`, "3"},
+		{`rawFunc()`, "raw"},
+		{`builtin()`, "7"},
+		{`sum(0.5)`, "0.5"},
+		{`sum(0.5, 1, 2)`, "3.5"},
+		{`sum(1, 2)`, "3.0"},
+		{`words(" a b  c ")`, `[a, b, c]`},
+		{`counts(words("a b a"))["a"]`, "2"},
+		{`total({"x": 1, "y": 2})`, "3"},
+		{`describe(1)`, "int64"},
+		{`describe("s")`, "string"},
+		{`describe([1, "a"])`, "slice"},
+		{`describe({"k": 1})`, "map"},
+		{`describe(if (false) { 1 })`, "nil"},
+		{`identity(fn(x) { x })(5)`, "5"},
+		{`nothing()`, "null"},
+		{`maybe(true)`, "1"},
+		{`maybe(false)`, "null"},
+		{`try { fail() } catch (e) { e["message"] }`, "boom"},
+	}
+	for _, tt := range tests {
+		result, err := i.Run(tt.input)
+		if err != nil {
+			t.Errorf("%s: %v", tt.input, err)
+			continue
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. want=%s, got=%s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case int64:
+		return "int64"
+	case string:
+		return "string"
+	}
+	return "other"
+}
+
+func TestRegisteredBuiltins(t *testing.T) {
+	i, _, _ := newTestInterpreter()
+	i.Register("double", func(n int) int { return n * 2 })
+	i.Register("len", func(s string) string { return "host " + s })
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`double(2)`, "4"},
+		{`let f = fn(x) { double(x) + 1 }; f(3)`, "7"},
+		{`let g = fn(double) { double }; g(5)`, "5"},
+		{`len("x")`, "host x"},
+		{`let twice = macro(x) { quote(unquote(double(2)) + unquote(x)) }; twice(1)`, "5"},
+		{`let double = fn(n) { n * 3 }; double(2)`, "6"},
+	}
+	for _, tt := range tests {
+		result, err := i.Run(tt.input)
+		if err != nil {
+			t.Errorf("%s: %v", tt.input, err)
+			continue
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. want=%s, got=%s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	if names := i.Builtins(); !reflect.DeepEqual(names, []string{"double", "len"}) {
+		t.Errorf("wrong builtins. got=%v", names)
+	}
+	other, _, _ := newTestInterpreter()
+	if _, err := other.Run(`double(1)`); err == nil || !strings.Contains(err.Error(), "identifier not found: double") {
+		t.Errorf("builtins leaked between interpreters. got=%v", err)
+	}
+	if findings := lint.SourceOptions("", []byte(`double(1)`), lint.Options{Builtins: i.Builtins()}); len(findings) != 0 {
+		t.Errorf("unexpected lint findings: %v", findings)
+	}
+}
+
+func TestRegisterErrors(t *testing.T) {
+	i, _, _ := newTestInterpreter()
+	i.Register("hasPrefix", func(s string, n int64) (bool, error) {
+		if n < 0 {
+			return false, errors.New("negative length")
+		}
+		return true, nil
+	})
+	i.Register("small", func(n int8) int8 { return n })
+	i.Register("count", func(n uint) uint { return n })
+	i.Register("keys", func(m map[string]int) int { return len(m) })
+	i.Register("chan", func() chan int { return make(chan int) })
+
+	tests := []struct {
+		input    string
+		kind     string
+		expected string
+	}{
+		{`hasPrefix("a")`, object.ARGUMENT_ERROR, "wrong number of arguments to hasPrefix. got=1, want=2"},
+		{`hasPrefix(1, 2)`, object.TYPE_ERROR, "argument 1 to hasPrefix: cannot convert INTEGER to string"},
+		{`hasPrefix("a", -1)`, object.GENERIC_ERROR, "negative length"},
+		{`small(1000)`, object.TYPE_ERROR, "argument 1 to small: 1000 overflows int8"},
+		{`count(-1)`, object.TYPE_ERROR, "argument 1 to count: -1 overflows uint"},
+		{`keys({"a": "b"})`, object.TYPE_ERROR, "argument 1 to keys: value of a: cannot convert STRING to int"},
+		{`keys({1: 1})`, object.TYPE_ERROR, "argument 1 to keys: key 1: cannot convert INTEGER to string"},
+		{`chan()`, object.TYPE_ERROR, "result of chan: cannot convert chan int to a Monkey value"},
+	}
+	for _, tt := range tests {
+		_, err := i.Run(tt.input)
+		runtimeErr, ok := err.(*RuntimeError)
+		if !ok {
+			t.Errorf("%s: expected *RuntimeError. got=%T(%v)", tt.input, err, err)
+			continue
+		}
+		if runtimeErr.Err.Kind != tt.kind || runtimeErr.Err.Message != tt.expected {
+			t.Errorf("wrong error for %s. want=%s: %s, got=%s: %s", tt.input, tt.kind, tt.expected, runtimeErr.Err.Kind, runtimeErr.Err.Message)
+		}
+	}
+
+	invalid := []interface{}{
+		42,
+		(func())(nil),
+		func() (int, int) { return 0, 0 },
+		func() (int, string, error) { return 0, "", nil },
+	}
+	for _, fn := range invalid {
+		if err := i.Register("bad", fn); err == nil {
+			t.Errorf("expected error for %T", fn)
+		}
+	}
+}
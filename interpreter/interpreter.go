@@ -0,0 +1,179 @@
+package interpreter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+	"strings"
+)
+
+// interpreter/interpreter.go
+//
+//供Go程序嵌入Monkey使用：解析、展开宏并求值源码，全局变量和宏在多次Run之间保留。
+//语法错误返回*ParseError，运行时错误返回*RuntimeError
+
+//ParseError 源码有语法错误，程序没有执行
+type ParseError struct {
+	Diagnostics []*parser.Diagnostic
+}
+
+func (e *ParseError) Error() string {
+	messages := []string{}
+	for _, d := range e.Diagnostics {
+		messages = append(messages, d.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+//RuntimeError 程序执行时出错，包括宏展开时的错误
+type RuntimeError struct {
+	Err *object.Error
+}
+
+//Error 格式为 <类型>: <信息>，有位置时附带 at <位置>
+func (e *RuntimeError) Error() string {
+	message := e.Err.Kind + ": " + e.Err.Message
+	if e.Err.Pos.IsValid() {
+		message += " at " + e.Err.Pos.String()
+	}
+	return message
+}
+
+//StackTrace 错误的回溯信息
+func (e *RuntimeError) StackTrace() string {
+	return e.Err.StackTrace()
+}
+
+//Interpreter Monkey解释器，不能同时在多个goroutine中使用
+type Interpreter struct {
+	env      *object.Environment
+	macroEnv *object.Environment
+	limits   evaluator.Limits
+	traces   io.Writer       //未捕获的运行时错误的回溯信息的输出，默认不输出
+	builtins map[string]bool //Register注册的内置函数名
+}
+
+//New 创建解释器，程序的输出默认为os.Stdout和os.Stderr，函数调用最多嵌套evaluator.DefaultMaxDepth层
+func New() *Interpreter {
+	env := object.NewEnvironment()
+	env.SetOutput(os.Stdout, os.Stderr)
+	return &Interpreter{
+		env:      env,
+		macroEnv: object.NewEnvironment(),
+		limits:   evaluator.Limits{MaxDepth: evaluator.DefaultMaxDepth},
+		builtins: map[string]bool{},
+	}
+}
+
+//SetStdout 设置puts等内置函数的输出
+func (i *Interpreter) SetStdout(w io.Writer) {
+	i.env.SetOutput(w, i.env.Output().Stderr)
+}
+
+//SetStderr 设置程序的错误输出，设置之后未捕获的运行时错误的回溯信息也输出到这里
+//没有设置时不输出回溯信息，通过RuntimeError.StackTrace取得
+func (i *Interpreter) SetStderr(w io.Writer) {
+	i.env.SetOutput(i.env.Output().Stdout, w)
+	i.traces = w
+}
+
+//SetLimits 设置之后每次执行和调用的步数与调用层数限制，超出时返回的错误类型为StepLimitError或CallDepthError
+func (i *Interpreter) SetLimits(limits evaluator.Limits) {
+	i.limits = limits
+}
+
+//Run 执行源码，返回最后一个表达式的值
+func (i *Interpreter) Run(src string) (object.Object, error) {
+	return i.RunContext(context.Background(), src)
+}
+
+//RunContext 执行源码，ctx被取消或超时时停止执行并返回类型为CancelledError的错误
+func (i *Interpreter) RunContext(ctx context.Context, src string) (object.Object, error) {
+	return i.run(ctx, "", src)
+}
+
+//RunFile 执行源文件，错误信息中的位置包含文件名
+func (i *Interpreter) RunFile(path string) (object.Object, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return i.run(context.Background(), path, string(src))
+}
+
+func (i *Interpreter) run(ctx context.Context, filename, src string) (object.Object, error) {
+	p := parser.New(lexer.NewWithFilename(filename, src))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		return nil, &ParseError{Diagnostics: p.Diagnostics()}
+	}
+	evaluator.DefineMacros(program, i.macroEnv)
+	expanded, errObj := evaluator.ExpandMacrosContext(ctx, program, i.macroEnv, i.limits)
+	if errObj != nil {
+		return nil, i.runtimeError(errObj)
+	}
+	return i.result(evaluator.EvalContext(ctx, expanded.(*ast.Program), i.env, i.limits))
+}
+
+//Set 设置全局变量
+func (i *Interpreter) Set(name string, value object.Object) {
+	i.env.Set(name, value)
+}
+
+//Get 取得全局变量
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	return i.env.Get(name)
+}
+
+//Call 调用全局变量name绑定的函数
+func (i *Interpreter) Call(name string, args ...object.Object) (object.Object, error) {
+	return i.CallContext(context.Background(), name, args...)
+}
+
+//CallContext 调用全局变量name绑定的函数，ctx被取消或超时时停止执行
+func (i *Interpreter) CallContext(ctx context.Context, name string, args ...object.Object) (object.Object, error) {
+	fn, ok := i.env.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("function not found: %s", name)
+	}
+	return i.callFunction(ctx, name, fn, args)
+}
+
+//CallFunction 调用函数或内置函数，如Run返回的函数或通过Get取得的函数，name用于调用栈展示
+func (i *Interpreter) CallFunction(name string, fn object.Object, args ...object.Object) (object.Object, error) {
+	return i.callFunction(context.Background(), name, fn, args)
+}
+
+func (i *Interpreter) callFunction(ctx context.Context, name string, fn object.Object, args []object.Object) (object.Object, error) {
+	switch fn.(type) {
+	case *object.Function, *object.Builtin:
+	default:
+		return nil, fmt.Errorf("%s is not a function: %s", name, fn.Type())
+	}
+	return i.result(evaluator.ApplyFunctionContext(ctx, name, fn, args, i.env, i.limits))
+}
+
+//result 运行时错误转换为*RuntimeError
+func (i *Interpreter) result(obj object.Object) (object.Object, error) {
+	if err, ok := obj.(*object.Error); ok {
+		return nil, i.runtimeError(err)
+	}
+	if obj == nil {
+		return evaluator.NULL, nil
+	}
+	return obj, nil
+}
+
+//runtimeError 设置了错误输出时将回溯信息输出到错误输出
+func (i *Interpreter) runtimeError(err *object.Error) *RuntimeError {
+	if i.traces != nil {
+		io.WriteString(i.traces, err.StackTrace())
+	}
+	return &RuntimeError{Err: err}
+}
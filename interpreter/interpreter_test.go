@@ -0,0 +1,269 @@
+package interpreter
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"monkey/evaluator"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestInterpreter() (*Interpreter, *bytes.Buffer, *bytes.Buffer) {
+	var stdout, stderr bytes.Buffer
+	i := New()
+	i.SetStdout(&stdout)
+	i.SetStderr(&stderr)
+	return i, &stdout, &stderr
+}
+
+func TestRun(t *testing.T) {
+	i, stdout, stderr := newTestInterpreter()
+	result, err := i.Run(`let double = fn(x) { x * 2 }; puts("hi", 1); double(21)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Inspect() != "42" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+	if stdout.String() != "hi\n1\n" {
+		t.Errorf("wrong stdout. got=%q", stdout.String())
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("unexpected stderr. got=%q", stderr.String())
+	}
+
+	//全局变量和宏在多次Run之间保留
+	if _, err := i.Run(`let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) };`); err != nil {
+		t.Fatal(err)
+	}
+	result, err = i.Run(`unless(false, double(1), 0)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Inspect() != "2" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+
+	result, err = i.Run(`let x = 1;`)
+	if err != nil || result != evaluator.NULL {
+		t.Errorf("expected null for let. got=%v, %v", result, err)
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rule.mk")
+	os.WriteFile(path, []byte("let limit = 10;\nlimit + 1;\n"), 0644)
+	i, _, _ := newTestInterpreter()
+	result, err := i.RunFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Inspect() != "11" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+	if _, err := i.RunFile(filepath.Join(t.TempDir(), "missing.mk")); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error. got=%v", err)
+	}
+
+	os.WriteFile(path, []byte("let x = 1;\nlet = 2;\n"), 0644)
+	_, err = i.RunFile(path)
+	parseErr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected *ParseError. got=%T(%v)", err, err)
+	}
+	if len(parseErr.Diagnostics) != 1 || parseErr.Error() != path+":2:5: expected next token to be IDENT, got = instead" {
+		t.Errorf("wrong parse error. got=%q", parseErr.Error())
+	}
+	if _, ok := i.Get("x"); ok {
+		t.Errorf("program with parse errors should not run")
+	}
+}
+
+func TestRuntimeError(t *testing.T) {
+	i, _, stderr := newTestInterpreter()
+	_, err := i.Run("let f = fn(x) { x + true };\nf(1);")
+	runtimeErr, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("expected *RuntimeError. got=%T(%v)", err, err)
+	}
+	if runtimeErr.Error() != "TypeError: type mismatch: INTEGER + BOOLEAN at 1:17" {
+		t.Errorf("wrong error. got=%q", runtimeErr.Error())
+	}
+	if runtimeErr.Err.Kind != object.TYPE_ERROR {
+		t.Errorf("wrong kind. got=%s", runtimeErr.Err.Kind)
+	}
+	if stderr.String() != runtimeErr.StackTrace() || !strings.Contains(stderr.String(), "f(1)") {
+		t.Errorf("wrong stderr. got=%q", stderr.String())
+	}
+
+	_, err = i.Run("let m = macro(x) { 1 }; m(2)")
+	if _, ok := err.(*RuntimeError); !ok {
+		t.Errorf("expected macro expansion error to be *RuntimeError. got=%T(%v)", err, err)
+	}
+}
+
+func TestRuntimeErrorNotPrintedByDefault(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	i := New()
+	os.Stderr = stderr
+	if _, err := i.Run("1 + true"); err == nil {
+		t.Fatalf("expected a runtime error")
+	}
+	w.Close()
+	out, _ := io.ReadAll(r)
+	if len(out) != 0 {
+		t.Errorf("stack trace written to stderr: %q", out)
+	}
+}
+
+func TestGlobalsAndCall(t *testing.T) {
+	i, stdout, _ := newTestInterpreter()
+	i.Set("threshold", &object.Integer{Value: 5})
+	if _, err := i.Run(`let check = fn(n, label = "n") { puts(label); n > threshold };`); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := i.Call("check", &object.Integer{Value: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != evaluator.TRUE {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+	if stdout.String() != "n\n" {
+		t.Errorf("puts in a called function should use the interpreter stdout. got=%q", stdout.String())
+	}
+
+	i.Set("threshold", &object.Integer{Value: 10})
+	result, _ = i.Call("check", &object.Integer{Value: 7})
+	if result.Inspect() != "false" {
+		t.Errorf("wrong result after Set. got=%s", result.Inspect())
+	}
+
+	if v, ok := i.Get("threshold"); !ok || v.Inspect() != "10" {
+		t.Errorf("wrong global. got=%v", v)
+	}
+
+	if _, err := i.Call("missing"); err == nil || err.Error() != "function not found: missing" {
+		t.Errorf("wrong error for missing function. got=%v", err)
+	}
+	if _, err := i.Call("threshold"); err == nil || err.Error() != "threshold is not a function: INTEGER" {
+		t.Errorf("wrong error for non-function. got=%v", err)
+	}
+
+	_, err = i.Call("check")
+	runtimeErr, ok := err.(*RuntimeError)
+	if !ok || runtimeErr.Err.Kind != object.ARGUMENT_ERROR {
+		t.Fatalf("expected ArgumentError. got=%v", err)
+	}
+	if len(runtimeErr.Err.Stack) != 1 || runtimeErr.Err.Stack[0].Function != "check" {
+		t.Errorf("wrong stack. got=%v", runtimeErr.Err.Stack)
+	}
+
+	fn, err := i.Run("fn(a, b) { a + b }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = i.CallFunction("add", fn, &object.Integer{Value: 1}, &object.Integer{Value: 2})
+	if err != nil || result.Inspect() != "3" {
+		t.Errorf("wrong result. got=%v, %v", result, err)
+	}
+
+	puts, _ := i.Get("puts")
+	if puts != nil {
+		t.Errorf("builtins are not globals. got=%v", puts)
+	}
+}
+
+func TestSeparateInterpreters(t *testing.T) {
+	a, aOut, _ := newTestInterpreter()
+	b, bOut, _ := newTestInterpreter()
+	a.Run(`let x = "a"; puts(x)`)
+	b.Run(`puts("b")`)
+	if _, ok := b.Get("x"); ok {
+		t.Errorf("globals leaked between interpreters")
+	}
+	if aOut.String() != "a\n" || bOut.String() != "b\n" {
+		t.Errorf("wrong output. a=%q, b=%q", aOut.String(), bOut.String())
+	}
+}
+
+func TestLimits(t *testing.T) {
+	i, _, _ := newTestInterpreter()
+	i.SetLimits(evaluator.Limits{MaxSteps: 10000, MaxDepth: 50})
+	tests := []struct {
+		run  func() error
+		kind string
+	}{
+		{func() error { _, err := i.Run(`let f = fn() { f() }; f()`); return err }, object.CALL_DEPTH_ERROR},
+		{func() error { _, err := i.Run(`for (;;) {}`); return err }, object.STEP_LIMIT_ERROR},
+		{func() error { _, err := i.Call("f"); return err }, object.CALL_DEPTH_ERROR},
+		{func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			i.SetLimits(evaluator.Limits{})
+			_, err := i.RunContext(ctx, `for (;;) {}`)
+			return err
+		}, object.CANCELLED_ERROR},
+		{func() error {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := i.CallContext(ctx, "f")
+			return err
+		}, object.CANCELLED_ERROR},
+	}
+	for n, tt := range tests {
+		runtimeErr, ok := tt.run().(*RuntimeError)
+		if !ok {
+			t.Errorf("tests[%d]: expected *RuntimeError", n)
+			continue
+		}
+		if runtimeErr.Err.Kind != tt.kind {
+			t.Errorf("tests[%d]: wrong kind. want=%s, got=%s", n, tt.kind, runtimeErr.Err.Kind)
+		}
+	}
+	result, err := i.Run(`let g = fn(n) { if (n == 0) { 0 } else { g(n - 1) } }; g(100)`)
+	if err != nil || result.Inspect() != "0" {
+		t.Errorf("limits removed, expected 0. got=%v, %v", result, err)
+	}
+}
+
+func TestDefaultCallDepth(t *testing.T) {
+	i, _, _ := newTestInterpreter()
+	_, err := i.Run(`let f = fn() { f() }; f()`)
+	runtimeErr, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("expected *RuntimeError. got=%T(%v)", err, err)
+	}
+	if runtimeErr.Err.Kind != object.CALL_DEPTH_ERROR {
+		t.Errorf("wrong kind. want=%s, got=%s", object.CALL_DEPTH_ERROR, runtimeErr.Err.Kind)
+	}
+}
+
+func TestMacroExpansionLimits(t *testing.T) {
+	src := `let m = macro(x) { let n = 0; for (;;) { n = n + 1 } }; m(1)`
+	i, _, _ := newTestInterpreter()
+	i.SetLimits(evaluator.Limits{MaxSteps: 1000})
+	_, err := i.Run(src)
+	if runtimeErr, ok := err.(*RuntimeError); !ok || runtimeErr.Err.Kind != object.STEP_LIMIT_ERROR {
+		t.Errorf("expected StepLimitError. got=%T(%v)", err, err)
+	}
+
+	i, _, _ = newTestInterpreter()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = i.RunContext(ctx, src)
+	if runtimeErr, ok := err.(*RuntimeError); !ok || runtimeErr.Err.Kind != object.CANCELLED_ERROR {
+		t.Errorf("expected CancelledError. got=%T(%v)", err, err)
+	}
+}
@@ -10,20 +10,49 @@ type Lexer struct {
 	position     int    //当前位置(当前字符的位置）
 	readPosition int    //当前的读取位置(词法解析需要预读）词法分析器除了查看当前字符，还需要进一步“查看”字符串，即查看字符串中的下一个字符
 	ch           byte   //正在查看的字符
+
+	filename string //源文件名，用于定位
+	line     int    //当前字符所在行，从1开始
+	column   int    //当前字符所在列，从1开始
+
+	keepComments bool //是否输出注释token
 }
 
 //New 创建词法解析器
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewWithFilename("", input)
+}
+
+//NewWithFilename 创建词法解析器，产生的token位置中带有文件名
+func NewWithFilename(filename string, input string) *Lexer {
+	l := &Lexer{input: input, filename: filename, line: 1}
 	l.readChar()
 	return l
 }
 
+//NewWithComments 创建保留注释的词法解析器，注释以COMMENT token输出，用于格式化等需要注释的场景
+func NewWithComments(filename string, input string) *Lexer {
+	l := NewWithFilename(filename, input)
+	l.keepComments = true
+	return l
+}
+
+//pos 当前字符的位置
+func (l *Lexer) pos() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
+}
+
 //NextToken 解析代码，输出解析出的token，包括类型和字面量
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 	//跳过空白符
 	l.skipWhitespace()
+	start := l.pos()
 	switch l.ch {
 	case '=':
 		if '=' == l.peekChar() {
@@ -48,10 +77,15 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.MINUS, l.ch)
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch)
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
 	case '/':
 		if '/' == l.peekChar() {
-			l.skipToNexLine()
-			return l.NextToken()
+			comment := l.readComment()
+			if !l.keepComments {
+				return l.NextToken()
+			}
+			return token.Token{Type: token.COMMENT, Literal: comment, Pos: start, End: l.pos()}
 		} else {
 			tok = newToken(token.SLASH, l.ch)
 		}
@@ -75,6 +109,9 @@ func (l *Lexer) NextToken() token.Token {
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
+		tok.Pos = start
+		tok.End = start
+		return tok
 	case '"':
 		tok.Type = token.STRING
 		tok.Literal = l.readString()
@@ -84,20 +121,33 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.RBRACKET, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '.':
+		if l.peekChar() == '.' && l.peekCharAt(2) == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	default:
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos = start
+			tok.End = l.pos()
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Literal, tok.Type = l.readNumber()
+			tok.Pos = start
+			tok.End = l.pos()
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
 	}
 	l.readChar()
+	tok.Pos = start
+	tok.End = l.pos()
 	return tok
 }
 
@@ -123,6 +173,12 @@ func newToken(tokenType token.TokenType, ch byte) token.Token {
 //这么做也是为了让事情保持简单，让我们能够专注于解释器的基础部分。
 //如果要完全支持Unicode和UTF-8，就要将l.ch的类型从byte改为rune，同时还要修改读取下一个字符的方式
 func (l *Lexer) readChar() {
+	//0 更新行列号
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
 	//1 是否读取结束
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
@@ -158,19 +214,55 @@ func (l *Lexer) skipWhitespace() {
 		l.readChar()
 	}
 }
-func (l *Lexer) skipToNexLine() {
-	for !isLineSep(l.ch) {
+//readComment 读取到行尾（或文件末尾）为止的注释，不包括换行符
+func (l *Lexer) readComment() string {
+	position := l.position
+	for !isLineSep(l.ch) && l.ch != 0 {
 		l.readChar()
 	}
+	return l.input[position:l.position]
 }
 
-//readNumber 读取数字，仅支持整数
-func (l *Lexer) readNumber() string {
+//readNumber 读取数字，支持整数和浮点数
+//浮点数包含小数部分或指数部分，如 1.5、2e10、3.0e-2
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	startPosition := l.position
+	var tokenType token.TokenType = token.INT
+	l.readDigits()
+	//小数点后必须紧跟数字
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+	//指数部分 e[+-]数字
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar()
+		if isDigit(next) || ((next == '+' || next == '-') && isDigit(l.peekCharAt(2))) {
+			tokenType = token.FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+	return l.input[startPosition:l.position], tokenType
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[startPosition:l.position]
+}
+
+//peekCharAt 瞅一眼当前字符之后第n个字符
+func (l *Lexer) peekCharAt(n int) byte {
+	position := l.position + n
+	if position >= len(l.input) {
+		return 0
+	}
+	return l.input[position]
 }
 
 func (l *Lexer) readString() string {
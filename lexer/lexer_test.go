@@ -24,11 +24,88 @@ a;//注释2
 	}
 	testLexer(t, input, tests)
 }
+
+func TestKeepComments(t *testing.T) {
+	input := "//注释1\na; // 注释2\n// 文件末尾的注释"
+	tests := []tokenResult{
+		{token.COMMENT, "//注释1"},
+		{token.IDENT, "a"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "// 注释2"},
+		{token.COMMENT, "// 文件末尾的注释"},
+		{token.EOF, ""},
+	}
+	l := NewWithComments("", input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.exceptedType || tok.Literal != tt.exceptedLiteral {
+			t.Fatalf("tests[%d] - wrong token. excepted=%q %q, got=%q %q", i, tt.exceptedType, tt.exceptedLiteral, tok.Type, tok.Literal)
+		}
+	}
+	//不保留注释时，文件末尾的注释直接得到EOF
+	testLexer(t, "a // 注释", []tokenResult{{token.IDENT, "a"}, {token.EOF, ""}})
+}
+
+func TestForIn(t *testing.T) {
+	input := `for (x in arr) {}`
+	tests := []tokenResult{
+		{token.FOR, "for"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.IN, "in"},
+		{token.IDENT, "arr"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.EOF, ""},
+	}
+	testLexer(t, input, tests)
+}
+
+func TestNumbers(t *testing.T) {
+	input := `5 1.5 0.25 2e10 3.0e-2 4E+3 7. 1e x1`
+	tests := []tokenResult{
+		{token.INT, "5"},
+		{token.FLOAT, "1.5"},
+		{token.FLOAT, "0.25"},
+		{token.FLOAT, "2e10"},
+		{token.FLOAT, "3.0e-2"},
+		{token.FLOAT, "4E+3"},
+		{token.INT, "7"},
+		{token.ILLEGAL, "."},
+		{token.INT, "1"},
+		{token.IDENT, "e"},
+		{token.IDENT, "x"},
+		{token.INT, "1"},
+		{token.EOF, ""},
+	}
+	testLexer(t, input, tests)
+}
+
+func TestEllipsis(t *testing.T) {
+	input := `fn(a, ...rest) .. .`
+	tests := []tokenResult{
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.IDENT, "a"},
+		{token.COMMA, ","},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.RPAREN, ")"},
+		{token.ILLEGAL, "."},
+		{token.ILLEGAL, "."},
+		{token.ILLEGAL, "."},
+		{token.EOF, ""},
+	}
+	testLexer(t, input, tests)
+}
+
 func TestNextToken(t *testing.T) {
-	input := `=+(){}`
+	input := `=+%(){}`
 	tests := []tokenResult{
 		{token.ASSIGN, "="},
 		{token.PLUS, "+"},
+		{token.PERCENT, "%"},
 		{token.LPAREN, "("},
 		{token.RPAREN, ")"},
 		{token.LBRACE, "{"},
@@ -211,6 +288,43 @@ for
 	testLexer(t, input, tests)
 }
 
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 10;\n  \"ab\" // c\n\tfoo==1"
+	tests := []struct {
+		exceptedType  token.TokenType
+		exceptedPos   string
+		exceptedEnd   string
+		exceptedStart int
+	}{
+		{token.LET, "t.mk:1:1", "t.mk:1:4", 0},
+		{token.IDENT, "t.mk:1:5", "t.mk:1:6", 4},
+		{token.ASSIGN, "t.mk:1:7", "t.mk:1:8", 6},
+		{token.INT, "t.mk:1:9", "t.mk:1:11", 8},
+		{token.SEMICOLON, "t.mk:1:11", "t.mk:1:12", 10},
+		{token.STRING, "t.mk:2:3", "t.mk:2:7", 14},
+		{token.IDENT, "t.mk:3:2", "t.mk:3:5", 25},
+		{token.EQ, "t.mk:3:5", "t.mk:3:7", 28},
+		{token.INT, "t.mk:3:7", "t.mk:3:8", 30},
+		{token.EOF, "t.mk:3:8", "t.mk:3:8", 31},
+	}
+	l := NewWithFilename("t.mk", input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.exceptedType {
+			t.Fatalf("test[%d] - token type wrong, excepted=%q, get %q", i, tt.exceptedType, tok.Type)
+		}
+		if tok.Pos.String() != tt.exceptedPos {
+			t.Errorf("test[%d] - token pos wrong, excepted=%q, get %q", i, tt.exceptedPos, tok.Pos.String())
+		}
+		if tok.End.String() != tt.exceptedEnd {
+			t.Errorf("test[%d] - token end wrong, excepted=%q, get %q", i, tt.exceptedEnd, tok.End.String())
+		}
+		if tok.Pos.Offset != tt.exceptedStart {
+			t.Errorf("test[%d] - token offset wrong, excepted=%d, get %d", i, tt.exceptedStart, tok.Pos.Offset)
+		}
+	}
+}
+
 func testLexer(t *testing.T, input string, tests []tokenResult) {
 	l := New(input)
 	for i, tt := range tests {
@@ -0,0 +1,383 @@
+package lint
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+)
+
+//作用域与求值器一致：只有函数调用会创建新的环境，
+//let、赋值和for-in的循环变量绑定在所在函数的环境中，catch的参数只在catch语句块中可见
+
+//builtinArity 参数个数固定的内置函数
+var builtinArity = map[string]*arity{
+	"len":   {min: 1, max: 1},
+	"first": {min: 1, max: 1},
+	"last":  {min: 1, max: 1},
+	"rest":  {min: 1, max: 1},
+	"push":  {min: 2, max: 2},
+}
+
+//arity 函数可以接受的参数个数，max为-1时不限
+type arity struct {
+	min, max int
+}
+
+func (a *arity) accepts(n int) bool {
+	return n >= a.min && (a.max < 0 || n <= a.max)
+}
+
+func (a *arity) String() string {
+	switch {
+	case a.max < 0:
+		return fmt.Sprintf("at least %d", a.min)
+	case a.min == a.max:
+		return fmt.Sprintf("%d", a.min)
+	default:
+		return fmt.Sprintf("%d to %d", a.min, a.max)
+	}
+}
+
+type bindingKind int
+
+const (
+	kindLet bindingKind = iota
+	kindParam
+	kindAssign
+	kindLoop
+	kindCatch
+)
+
+//binding 作用域中的一个名字
+type binding struct {
+	ident    *ast.Identifier //第一次声明（没有声明时为第一次赋值）的位置
+	kind     bindingKind
+	declared bool   //是否通过let、参数、for-in或catch声明过
+	used     bool   //是否被读取过
+	defs     int    //被绑定的次数
+	arity    *arity //只被绑定过一次且值为函数或宏时，可以检查调用的参数个数
+}
+
+type scope struct {
+	parent *scope
+	names  map[string]*binding
+	order  []*binding
+}
+
+func (s *scope) bind(ident *ast.Identifier, kind bindingKind) *binding {
+	b, ok := s.names[ident.Value]
+	if !ok {
+		b = &binding{ident: ident, kind: kind}
+		s.names[ident.Value] = b
+		s.order = append(s.order, b)
+	}
+	//之前只有赋值时，以第一次声明为准
+	if kind != kindAssign && !b.declared {
+		b.ident = ident
+		b.kind = kind
+		b.declared = true
+	}
+	b.defs++
+	b.arity = nil
+	return b
+}
+
+func (s *scope) lookup(name string) *binding {
+	for scope := s; scope != nil; scope = scope.parent {
+		if b, ok := scope.names[name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+//declared 名字是否在当前或外层作用域中声明过
+func (s *scope) declared(name string) bool {
+	for scope := s; scope != nil; scope = scope.parent {
+		if b, ok := scope.names[name]; ok && b.declared {
+			return true
+		}
+	}
+	return false
+}
+
+type checker struct {
+	scope    *scope
+	findings []*parser.Diagnostic
+	builtins map[string]bool //Options.Builtins
+}
+
+//function 检查一个函数（或整个程序），先收集函数中绑定的名字，再检查函数体
+func (c *checker) function(params []*ast.Identifier, defaults map[string]ast.Expression, rest *ast.Identifier, body ast.Node) {
+	s := &scope{parent: c.scope, names: map[string]*binding{}}
+	c.scope = s
+	for _, p := range params {
+		c.declare(p, kindParam)
+	}
+	if rest != nil {
+		c.declare(rest, kindParam)
+	}
+	c.collect(body, nil)
+	for _, p := range params {
+		if def, ok := defaults[p.Value]; ok {
+			c.visit(def)
+		}
+	}
+	c.visit(body)
+	c.scope = s.parent
+
+	for _, b := range s.order {
+		if b.used || strings.HasPrefix(b.ident.Value, "_") {
+			continue
+		}
+		switch b.kind {
+		case kindLet:
+			c.report(RuleUnusedVariable, b.ident.Token, "%s is declared but never used", b.ident.Value)
+		case kindParam:
+			c.report(RuleUnusedParameter, b.ident.Token, "parameter %s is never used", b.ident.Value)
+		}
+	}
+}
+
+//declare 在当前作用域中绑定名字，检查是否与内置函数同名
+func (c *checker) declare(ident *ast.Identifier, kind bindingKind) *binding {
+	if kind != kindAssign && c.isBuiltin(ident.Value) {
+		c.report(RuleShadowedBuiltin, ident.Token, "%s shadows the builtin function %s", ident.Value, ident.Value)
+	}
+	return c.scope.bind(ident, kind)
+}
+
+//collect 收集函数体中绑定的名字，不进入内层函数
+//catch语句块中对与参数同名的名字的绑定重新绑定的是参数，不属于函数的作用域
+func (c *checker) collect(body ast.Node, hidden map[string]bool) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			return false
+		case *ast.LetStatement:
+			if hidden[n.Name.Value] {
+				break
+			}
+			b := c.declare(n.Name, kindLet)
+			if b.defs == 1 {
+				b.arity = arityOf(n.Value)
+			}
+		case *ast.AssignStatement:
+			if n.Name != nil && !hidden[n.Name.Value] {
+				c.declare(n.Name, kindAssign)
+			}
+		case *ast.ForInStatement:
+			if !hidden[n.Variable.Value] {
+				c.declare(n.Variable, kindLoop)
+			}
+		case *ast.TryExpression:
+			if n.CatchParam == nil {
+				return true
+			}
+			c.collect(n.Block, hidden)
+			inner := map[string]bool{n.CatchParam.Value: true}
+			for name := range hidden {
+				inner[name] = true
+			}
+			c.collect(n.Catch, inner)
+			if n.Finally != nil {
+				c.collect(n.Finally, hidden)
+			}
+			return false
+		case *ast.CallExpression:
+			return !isCallTo(n, "quote")
+		}
+		return true
+	})
+}
+
+func arityOf(value ast.Expression) *arity {
+	switch fn := value.(type) {
+	case *ast.FunctionLiteral:
+		a := &arity{max: len(fn.Parameters)}
+		for _, p := range fn.Parameters {
+			if _, ok := fn.Defaults[p.Value]; !ok {
+				a.min++
+			}
+		}
+		if fn.Rest != nil {
+			a.max = -1
+		}
+		return a
+	case *ast.MacroLiteral:
+		return &arity{min: len(fn.Parameters), max: len(fn.Parameters)}
+	}
+	return nil
+}
+
+func (c *checker) visit(node ast.Node) {
+	if node == nil {
+		return
+	}
+	ast.Inspect(node, c.inspect)
+}
+
+func (c *checker) inspect(node ast.Node) bool {
+	switch n := node.(type) {
+	case *ast.Program:
+		c.unreachable(n.Statements)
+	case *ast.BlockStatement:
+		c.unreachable(n.Statements)
+	case *ast.FunctionLiteral:
+		c.function(n.Parameters, n.Defaults, n.Rest, n.Body)
+		return false
+	case *ast.MacroLiteral:
+		c.function(n.Parameters, nil, nil, n.Body)
+		return false
+	case *ast.LetStatement:
+		c.visit(n.Value)
+		return false
+	case *ast.AssignStatement:
+		if n.Name != nil && !c.scope.declared(n.Name.Value) {
+			c.report(RuleUndeclaredAssign, n.Name.Token, "assignment to %s, which is not declared with let", n.Name.Value)
+		}
+		c.visit(n.Value)
+		return false
+	case *ast.ForStatement:
+		if n.Init != nil {
+			c.visit(n.Init)
+		}
+		c.visit(n.Condition)
+		c.visit(n.Post)
+		c.visit(n.Body)
+		return false
+	case *ast.ForInStatement:
+		c.visit(n.Iterable)
+		c.visit(n.Body)
+		return false
+	case *ast.BreakStatement, *ast.ContinueStatement:
+		return false
+	case *ast.TryExpression:
+		c.visit(n.Block)
+		if n.CatchParam != nil {
+			s := &scope{parent: c.scope, names: map[string]*binding{}}
+			c.scope = s
+			c.declare(n.CatchParam, kindCatch)
+			c.visit(n.Catch)
+			c.scope = s.parent
+		} else if n.Catch != nil {
+			c.visit(n.Catch)
+		}
+		if n.Finally != nil {
+			c.visit(n.Finally)
+		}
+		return false
+	case *ast.IfExpression:
+		if isConstant(n.Condition) {
+			c.report(RuleConstantCondition, n.Token, "if condition %s is constant", n.Condition)
+		}
+	case *ast.CallExpression:
+		if isCallTo(n, "quote") {
+			c.quote(n)
+			return false
+		}
+		c.call(n)
+	case *ast.Identifier:
+		c.use(n)
+	}
+	return true
+}
+
+//quote quote中的语法树不会被求值，只检查unquote中的表达式
+func (c *checker) quote(call *ast.CallExpression) {
+	for _, arg := range call.Arguments {
+		ast.Inspect(arg, func(n ast.Node) bool {
+			if isCallTo(n, "unquote") {
+				for _, a := range n.(*ast.CallExpression).Arguments {
+					c.visit(a)
+				}
+				return false
+			}
+			return true
+		})
+	}
+}
+
+func (c *checker) use(ident *ast.Identifier) {
+	//只被赋值过的名字在第一次赋值前读取的是外层的同名变量
+	found := false
+	for scope := c.scope; scope != nil; scope = scope.parent {
+		if b, ok := scope.names[ident.Value]; ok {
+			b.used = true
+			found = true
+			if b.declared {
+				break
+			}
+		}
+	}
+	if found {
+		return
+	}
+	if c.isBuiltin(ident.Value) {
+		return
+	}
+	c.report(RuleUndefined, ident.Token, "identifier not found: %s", ident.Value)
+}
+
+//call 检查调用已知函数时的参数个数
+func (c *checker) call(call *ast.CallExpression) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return
+	}
+	var a *arity
+	if b := c.scope.lookup(ident.Value); b != nil {
+		a = b.arity
+	} else {
+		a = builtinArity[ident.Value]
+	}
+	if a != nil && !a.accepts(len(call.Arguments)) {
+		c.report(RuleWrongArgumentCount, ident.Token, "wrong number of arguments to %s. got=%d, want=%s", ident.Value, len(call.Arguments), a)
+	}
+}
+
+//unreachable 检查跳转语句之后的语句，每个语句块只报告一次
+func (c *checker) unreachable(stmts []ast.Statement) {
+	for i := 0; i+1 < len(stmts); i++ {
+		switch stmts[i].(type) {
+		case *ast.ReturnStatement, *ast.ThrowStatement, *ast.BreakStatement, *ast.ContinueStatement:
+			pos := stmts[i+1].Pos()
+			c.report(RuleUnreachable, token.Token{Pos: pos, End: pos}, "unreachable code")
+			return
+		}
+	}
+}
+
+//isConstant 表达式是否只由字面量组成
+func isConstant(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.Boolean, *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return isConstant(e.Right)
+	case *ast.InfixExpression:
+		return isConstant(e.Left) && isConstant(e.Right)
+	}
+	return false
+}
+
+func (c *checker) isBuiltin(name string) bool {
+	if name == "quote" || name == "unquote" || c.builtins[name] {
+		return true
+	}
+	_, ok := evaluator.GetBuiltin(name)
+	return ok
+}
+
+//isCallTo 是否是对名为name的标识符的调用
+func isCallTo(node ast.Node, name string) bool {
+	call, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == name
+}
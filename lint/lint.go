@@ -0,0 +1,100 @@
+package lint
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"sort"
+)
+
+// lint/lint.go
+//
+//静态检查语法树中常见的错误，每条结果是一个警告级别的parser.Diagnostic，Code为规则编号。
+//在出问题的行或者上一行写 // lint:ignore L002 可以忽略该行的指定规则（不写编号时忽略所有规则），
+//写 // lint:file-ignore L002 则在整个文件中忽略
+
+//规则编号
+const (
+	RuleUndefined          = "L001" //使用了未定义的标识符
+	RuleUnusedVariable     = "L002" //let绑定的变量没有被使用
+	RuleUnusedParameter    = "L003" //函数参数没有被使用
+	RuleShadowedBuiltin    = "L004" //变量与内置函数同名
+	RuleUnreachable        = "L005" //return、throw、break、continue之后的代码不会执行
+	RuleConstantCondition  = "L006" //if的条件是常量
+	RuleWrongArgumentCount = "L007" //调用已知函数时参数个数不正确
+	RuleUndeclaredAssign   = "L008" //对没有用let声明过的变量赋值
+)
+
+//Rule 规则的说明
+type Rule struct {
+	ID          string
+	Name        string
+	Description string
+}
+
+//Rules 所有规则，按编号排列
+var Rules = []Rule{
+	{RuleUndefined, "undefined-identifier", "use of an identifier that is never defined"},
+	{RuleUnusedVariable, "unused-variable", "let binding that is never used"},
+	{RuleUnusedParameter, "unused-parameter", "function parameter that is never used"},
+	{RuleShadowedBuiltin, "shadowed-builtin", "binding that hides a builtin function such as len or puts"},
+	{RuleUnreachable, "unreachable-code", "code after return, throw, break or continue"},
+	{RuleConstantCondition, "constant-condition", "if condition that is a constant expression"},
+	{RuleWrongArgumentCount, "wrong-argument-count", "call of a known function with the wrong number of arguments"},
+	{RuleUndeclaredAssign, "undeclared-assignment", "assignment to a name that was never declared with let"},
+}
+
+//Options 检查的选项
+type Options struct {
+	//Builtins 宿主程序注册的内置函数名，如interpreter.Interpreter.Builtins的结果，
+	//与标准的内置函数一样不报告为未定义（L001），被遮盖时报告L004
+	Builtins []string
+}
+
+//Source 解析并检查源码，有语法错误时只返回语法错误
+func Source(filename string, src []byte) []*parser.Diagnostic {
+	return SourceOptions(filename, src, Options{})
+}
+
+//SourceOptions 与Source相同，按opts检查
+func SourceOptions(filename string, src []byte, opts Options) []*parser.Diagnostic {
+	p := parser.New(lexer.NewWithComments(filename, string(src)))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		return p.Diagnostics()
+	}
+	return CheckOptions(program, p.Comments(), opts)
+}
+
+//Check 检查程序，comments为源码中的注释，用于忽略指定的规则，可为空
+func Check(program *ast.Program, comments []token.Token) []*parser.Diagnostic {
+	return CheckOptions(program, comments, Options{})
+}
+
+//CheckOptions 与Check相同，按opts检查
+func CheckOptions(program *ast.Program, comments []token.Token, opts Options) []*parser.Diagnostic {
+	c := &checker{builtins: map[string]bool{}}
+	for _, name := range opts.Builtins {
+		c.builtins[name] = true
+	}
+	c.function(nil, nil, nil, program)
+	findings := newSuppressions(comments).filter(c.findings)
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Pos.Offset < findings[j].Pos.Offset
+	})
+	return findings
+}
+
+//report 记录一条结果，附带忽略该结果的方法
+func (c *checker) report(rule string, tok token.Token, format string, a ...interface{}) {
+	c.findings = append(c.findings, &parser.Diagnostic{
+		Severity: parser.SeverityWarning,
+		Pos:      tok.Pos,
+		End:      tok.End,
+		Code:     rule,
+		Message:  fmt.Sprintf(format, a...),
+		Hints:    []string{fmt.Sprintf("add `// lint:ignore %s` to suppress", rule)},
+	})
+}
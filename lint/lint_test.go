@@ -0,0 +1,124 @@
+package lint
+
+import (
+	"monkey/parser"
+	"reflect"
+	"testing"
+)
+
+// lint/lint_test.go
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string //<位置> <规则>
+	}{
+		{"puts(x);", []string{"1:6 L001"}},
+		{"let f = fn() { g() }; let g = fn() { 1 }; f();", []string{}},
+		{"let x = 1;", []string{"1:5 L002"}},
+		{"let x = 1; x = 2;", []string{"1:5 L002"}},
+		{"let _x = 1; let f = fn(_a) { 1 }; f(1);", []string{}},
+		{"let f = fn(a, b) { a }; f(1, 2);", []string{"1:15 L003"}},
+		{"let f = fn(a, ...xs) { a }; f(1);", []string{"1:18 L003"}},
+		{"let len = 1; len;", []string{"1:5 L004"}},
+		{"let f = fn(puts) { puts }; f(1);", []string{"1:12 L004"}},
+		{"let f = fn() { return 1; 2; 3 }; f();", []string{"1:26 L005"}},
+		{"for (;;) { break; puts(1); }", []string{"1:19 L005"}},
+		{"if (true) { 1 }; if (1 + 2 > 3) { 1 }; let x = 1; if (x) { 2 }", []string{"1:1 L006", "1:18 L006"}},
+		{"let f = fn(a, b = 1) { a + b }; f(); f(1); f(1, 2, 3);", []string{"1:33 L007", "1:44 L007"}},
+		{"len(); puts(); push([], 1, 2);", []string{"1:1 L007", "1:16 L007"}},
+		{"let f = fn(a) { a }; f = fn() { 1 }; f(1, 2);", []string{}},
+		{"count = 1; count;", []string{"1:1 L008"}},
+		{"let count = 0; let inc = fn() { count = count + 1 }; inc();", []string{}},
+		{"for (x in [1]) { x = x + 1 }; try { 1 } catch (e) { e = 2 }", []string{}},
+		{"try { throw 1 } catch (e) {}; puts(e)", []string{"1:36 L001"}},
+		{"let e = 1; try { 1 } catch (e) { let e = 2; e }; e", []string{}},
+		{"try { 1 } catch (e) { e = 2; try { 1 } catch (e) { e } }", []string{}},
+		{"let m = macro(a, b) { quote(unquote(a) + c) }; m(1, 2);", []string{"1:18 L003"}},
+	}
+	for _, tt := range tests {
+		findings := Source("", []byte(tt.input))
+		got := []string{}
+		for _, d := range findings {
+			if d.Severity != parser.SeverityWarning {
+				t.Fatalf("unexpected error for %q: %s", tt.input, d.Error())
+			}
+			got = append(got, d.Pos.String()+" "+d.Code)
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("wrong findings for %q.\nwant=%v\ngot=%v", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestBuiltinsOption(t *testing.T) {
+	opts := Options{Builtins: []string{"hasPrefix"}}
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`hasPrefix("ab", "a"); hasPrefix(1, 2, 3)`, []string{}},
+		{"let hasPrefix = 1; hasPrefix", []string{"1:5 L004"}},
+		{"hasSuffix(1)", []string{"1:1 L001"}},
+	}
+	for _, tt := range tests {
+		got := []string{}
+		for _, d := range SourceOptions("", []byte(tt.input), opts) {
+			got = append(got, d.Pos.String()+" "+d.Code)
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("wrong findings for %q.\nwant=%v\ngot=%v", tt.input, tt.expected, got)
+		}
+	}
+	if findings := Source("", []byte(`hasPrefix("ab", "a")`)); len(findings) != 1 || findings[0].Code != RuleUndefined {
+		t.Errorf("expected L001 without the option. got=%v", findings)
+	}
+}
+
+func TestSuppressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; // lint:ignore L002", []string{}},
+		{"// lint:ignore L002 kept for debugging\nlet x = 1;", []string{}},
+		{"// lint:ignore L001\nlet x = 1;", []string{"2:5 L002"}},
+		{"// lint:ignore L001,L002\nlet x = y;", []string{}},
+		{"let x = y; // lint:ignore", []string{}},
+		{"// lint:ignore L002\n\nlet x = 1;", []string{"3:5 L002"}},
+		{"// lint:file-ignore L002\nlet x = 1;\nlet y = z;", []string{"3:9 L001"}},
+	}
+	for _, tt := range tests {
+		got := []string{}
+		for _, d := range Source("", []byte(tt.input)) {
+			got = append(got, d.Pos.String()+" "+d.Code)
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("wrong findings for %q.\nwant=%v\ngot=%v", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	findings := Source("bad.mk", []byte("let = 1;"))
+	if len(findings) != 1 || findings[0].Severity != parser.SeverityError {
+		t.Fatalf("expected one syntax error. got=%v", findings)
+	}
+}
+
+func TestFindingFields(t *testing.T) {
+	findings := Source("a.mk", []byte("let f = fn() { 1 };\nf(2);"))
+	if len(findings) != 1 {
+		t.Fatalf("wrong number of findings. got=%d", len(findings))
+	}
+	d := findings[0]
+	if d.Code != RuleWrongArgumentCount || d.Pos.String() != "a.mk:2:1" || d.End.Column != 2 {
+		t.Errorf("wrong finding. got=%+v", d)
+	}
+	if d.Message != "wrong number of arguments to f. got=1, want=0" {
+		t.Errorf("wrong message. got=%q", d.Message)
+	}
+	if len(d.Hints) != 1 || d.Hints[0] != "add `// lint:ignore L007` to suppress" {
+		t.Errorf("wrong hints. got=%q", d.Hints)
+	}
+}
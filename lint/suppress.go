@@ -0,0 +1,99 @@
+package lint
+
+import (
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+)
+
+//ruleSet 被忽略的规则，all为true时忽略所有规则
+type ruleSet struct {
+	all   bool
+	rules map[string]bool
+}
+
+func (r *ruleSet) add(ids []string) {
+	if len(ids) == 0 {
+		r.all = true
+		return
+	}
+	if r.rules == nil {
+		r.rules = map[string]bool{}
+	}
+	for _, id := range ids {
+		r.rules[id] = true
+	}
+}
+
+func (r *ruleSet) contains(rule string) bool {
+	return r != nil && (r.all || r.rules[rule])
+}
+
+//suppressions 由注释指定的忽略规则
+type suppressions struct {
+	file  ruleSet
+	lines map[int]*ruleSet
+}
+
+//newSuppressions 解析 // lint:ignore [规则...] 与 // lint:file-ignore [规则...] 注释
+//lint:ignore 作用于注释所在的行和下一行
+func newSuppressions(comments []token.Token) *suppressions {
+	s := &suppressions{lines: map[int]*ruleSet{}}
+	for _, comment := range comments {
+		fields := strings.Fields(strings.TrimPrefix(comment.Literal, "//"))
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "lint:ignore":
+			ids := ruleIDs(fields[1:])
+			for _, line := range []int{comment.Pos.Line, comment.Pos.Line + 1} {
+				if s.lines[line] == nil {
+					s.lines[line] = &ruleSet{}
+				}
+				s.lines[line].add(ids)
+			}
+		case "lint:file-ignore":
+			s.file.add(ruleIDs(fields[1:]))
+		}
+	}
+	return s
+}
+
+//ruleIDs 取出注释中的规则编号，编号之后可以写说明，如 lint:ignore L002,L003 调试时使用
+func ruleIDs(fields []string) []string {
+	ids := []string{}
+	for _, field := range fields {
+		found := false
+		for _, id := range strings.Split(field, ",") {
+			if isRule(id) {
+				ids = append(ids, id)
+				found = true
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	return ids
+}
+
+func isRule(id string) bool {
+	for _, rule := range Rules {
+		if rule.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *suppressions) filter(findings []*parser.Diagnostic) []*parser.Diagnostic {
+	kept := []*parser.Diagnostic{}
+	for _, d := range findings {
+		if s.file.contains(d.Code) || s.lines[d.Pos.Line].contains(d.Code) {
+			continue
+		}
+		kept = append(kept, d)
+	}
+	return kept
+}
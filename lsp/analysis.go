@@ -0,0 +1,310 @@
+package lsp
+
+import (
+	"monkey/ast"
+	"monkey/format"
+	"monkey/lexer"
+	"monkey/token"
+	"sort"
+	"strings"
+)
+
+//作用域与求值器一致：只有函数调用会创建新的环境，
+//let和for-in的循环变量绑定在所在函数的环境中，catch的参数只在catch语句块中可见
+
+type bindingKind int
+
+const (
+	kindLet bindingKind = iota
+	kindParam
+	kindLoop
+	kindCatch
+)
+
+//binding 一次名字的绑定
+type binding struct {
+	ident *ast.Identifier
+	kind  bindingKind
+	value ast.Expression //let绑定的值
+	owner ast.Node       //参数所属的函数或宏
+}
+
+//scope 程序、一个函数体或catch语句块，start和end为花括号的偏移
+type scope struct {
+	parent     *scope
+	start, end int
+	names      map[string][]*binding
+	order      []*binding
+}
+
+func (s *scope) bind(b *binding) {
+	if _, ok := s.names[b.ident.Value]; !ok {
+		s.order = append(s.order, b)
+	}
+	s.names[b.ident.Value] = append(s.names[b.ident.Value], b)
+}
+
+//analysis 文档中名字的绑定与引用
+type analysis struct {
+	tokens []token.Token
+	braces map[int]int //左花括号对应的右花括号的偏移
+	scopes []*scope
+	scope  *scope
+	idents []*ast.Identifier            //所有标识符，按位置排列
+	refs   map[*ast.Identifier]*binding //标识符引用的绑定，内置函数和未定义的名字不在其中
+}
+
+func analyze(program *ast.Program, text string) *analysis {
+	a := &analysis{braces: map[int]int{}, refs: map[*ast.Identifier]*binding{}}
+	stack := []int{}
+	l := lexer.New(text)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		a.tokens = append(a.tokens, tok)
+		switch tok.Type {
+		case token.LBRACE:
+			stack = append(stack, tok.Pos.Offset)
+		case token.RBRACE:
+			if len(stack) > 0 {
+				a.braces[stack[len(stack)-1]] = tok.Pos.Offset
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	a.function(&scope{start: -1, end: len(text) + 1}, nil, nil, nil, program)
+	sort.Slice(a.idents, func(i, j int) bool {
+		return a.idents[i].Token.Pos.Offset < a.idents[j].Token.Pos.Offset
+	})
+	return a
+}
+
+//function 先收集函数体中绑定的名字，再解析函数体中的引用
+func (a *analysis) function(s *scope, owner ast.Node, params []*ast.Identifier, defaults map[string]ast.Expression, body ast.Node) {
+	a.enter(s)
+	for _, p := range params {
+		a.define(&binding{ident: p, kind: kindParam, owner: owner})
+	}
+	a.collect(body, nil)
+	for _, p := range params {
+		if def, ok := defaults[p.Value]; ok {
+			ast.Inspect(def, a.inspect)
+		}
+	}
+	ast.Inspect(body, a.inspect)
+	a.scope = s.parent
+}
+
+func (a *analysis) enter(s *scope) {
+	s.parent = a.scope
+	s.names = map[string][]*binding{}
+	a.scopes = append(a.scopes, s)
+	a.scope = s
+}
+
+//collect 收集函数体中绑定的名字，不进入内层函数
+//catch语句块中与参数同名的let和for-in重新绑定的是参数，不属于函数的作用域
+func (a *analysis) collect(body ast.Node, hidden map[string]bool) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			return false
+		case *ast.LetStatement:
+			if !hidden[n.Name.Value] {
+				a.define(&binding{ident: n.Name, kind: kindLet, value: n.Value})
+			}
+		case *ast.ForInStatement:
+			if !hidden[n.Variable.Value] {
+				a.define(&binding{ident: n.Variable, kind: kindLoop})
+			}
+		case *ast.TryExpression:
+			if n.CatchParam == nil {
+				return true
+			}
+			a.collect(n.Block, hidden)
+			inner := map[string]bool{n.CatchParam.Value: true}
+			for name := range hidden {
+				inner[name] = true
+			}
+			a.collect(n.Catch, inner)
+			if n.Finally != nil {
+				a.collect(n.Finally, hidden)
+			}
+			return false
+		}
+		return true
+	})
+}
+
+func (a *analysis) define(b *binding) {
+	a.scope.bind(b)
+	a.refs[b.ident] = b
+	a.idents = append(a.idents, b.ident)
+}
+
+func (a *analysis) inspect(node ast.Node) bool {
+	switch n := node.(type) {
+	case *ast.FunctionLiteral:
+		params := n.Parameters
+		if n.Rest != nil {
+			params = append(params[:len(params):len(params)], n.Rest)
+		}
+		a.function(a.bodyScope(n.Body), n, params, n.Defaults, n.Body)
+		return false
+	case *ast.MacroLiteral:
+		a.function(a.bodyScope(n.Body), n, n.Parameters, nil, n.Body)
+		return false
+	case *ast.TryExpression:
+		if n.CatchParam == nil {
+			return true
+		}
+		ast.Inspect(n.Block, a.inspect)
+		s := a.bodyScope(n.Catch)
+		a.enter(s)
+		a.define(&binding{ident: n.CatchParam, kind: kindCatch})
+		ast.Inspect(n.Catch, a.inspect)
+		a.scope = s.parent
+		if n.Finally != nil {
+			ast.Inspect(n.Finally, a.inspect)
+		}
+		return false
+	case *ast.Identifier:
+		if _, ok := a.refs[n]; ok {
+			return true
+		}
+		if b := a.resolve(a.scope, n.Value, n.Token.Pos.Offset); b != nil {
+			a.refs[n] = b
+		}
+		a.idents = append(a.idents, n)
+	}
+	return true
+}
+
+func (a *analysis) bodyScope(body *ast.BlockStatement) *scope {
+	start := body.Token.Pos.Offset
+	end, ok := a.braces[start]
+	if !ok {
+		end = start
+	}
+	return &scope{start: start, end: end}
+}
+
+//resolve 从内向外查找名字，同一作用域中有多次绑定时取offset之前的最后一次
+func (a *analysis) resolve(s *scope, name string, offset int) *binding {
+	for ; s != nil; s = s.parent {
+		bindings := s.names[name]
+		if len(bindings) == 0 {
+			continue
+		}
+		found := bindings[0]
+		for _, b := range bindings {
+			if b.ident.Token.Pos.Offset <= offset {
+				found = b
+			}
+		}
+		return found
+	}
+	return nil
+}
+
+//identAt 光标所在的标识符，光标在标识符末尾时也算在内
+func (a *analysis) identAt(offset int) *ast.Identifier {
+	for _, ident := range a.idents {
+		start := ident.Token.Pos.Offset
+		if start <= offset && offset <= start+len(ident.Value) {
+			return ident
+		}
+	}
+	return nil
+}
+
+//scopeAt 包含offset的最内层作用域
+func (a *analysis) scopeAt(offset int) *scope {
+	var found *scope
+	for _, s := range a.scopes {
+		if s.start < offset && offset <= s.end && (found == nil || s.start > found.start) {
+			found = s
+		}
+	}
+	return found
+}
+
+//visible offset处可见的绑定，内层的名字遮蔽外层的同名绑定
+func (a *analysis) visible(offset int) []*binding {
+	seen := map[string]bool{}
+	result := []*binding{}
+	for s := a.scopeAt(offset); s != nil; s = s.parent {
+		for _, b := range s.order {
+			if !seen[b.ident.Value] {
+				seen[b.ident.Value] = true
+				result = append(result, a.resolve(s, b.ident.Value, offset))
+			}
+		}
+	}
+	return result
+}
+
+//statementEnd 语句块中第i条语句的结束偏移，limit为语句块的结尾
+func (a *analysis) statementEnd(stmts []ast.Statement, i int, limit int) int {
+	next := limit
+	if i+1 < len(stmts) {
+		next = stmts[i+1].Pos().Offset
+	}
+	j := sort.Search(len(a.tokens), func(j int) bool { return a.tokens[j].Pos.Offset >= next })
+	if j == 0 {
+		return stmts[i].Pos().Offset
+	}
+	return tokenEnd(a.tokens[j-1])
+}
+
+//signature 函数或宏的参数列表，如 fn(a, b = 1, ...rest)
+func signature(node ast.Node) string {
+	var params []string
+	var out strings.Builder
+	switch fn := node.(type) {
+	case *ast.FunctionLiteral:
+		out.WriteString("fn(")
+		for _, p := range fn.Parameters {
+			if def, ok := fn.Defaults[p.Value]; ok {
+				params = append(params, p.Value+" = "+format.Node(def))
+			} else {
+				params = append(params, p.Value)
+			}
+		}
+		if fn.Rest != nil {
+			params = append(params, "..."+fn.Rest.Value)
+		}
+	case *ast.MacroLiteral:
+		out.WriteString("macro(")
+		for _, p := range fn.Parameters {
+			params = append(params, p.Value)
+		}
+	}
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(")")
+	return out.String()
+}
+
+//describe 悬停时展示的绑定定义
+func describe(b *binding) string {
+	switch b.kind {
+	case kindLet:
+		switch b.value.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			return "let " + b.ident.Value + " = " + signature(b.value)
+		}
+		value := format.Node(b.value)
+		if i := strings.IndexByte(value, '\n'); i >= 0 {
+			value = value[:i] + " ..."
+		}
+		return "let " + b.ident.Value + " = " + value
+	case kindParam:
+		owner := signature(b.owner)
+		if fn, ok := b.owner.(*ast.FunctionLiteral); ok && fn.Name != "" {
+			owner = fn.Name + " = " + owner
+		}
+		return "(parameter) " + b.ident.Value + " of " + owner
+	case kindLoop:
+		return "(loop variable) " + b.ident.Value
+	default:
+		return "(catch parameter) " + b.ident.Value
+	}
+}
@@ -0,0 +1,116 @@
+package lsp
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"sort"
+	"unicode/utf8"
+)
+
+//document 打开的文档，每次修改后重新解析
+type document struct {
+	uri         string
+	text        string
+	lineStarts  []int //每一行起始的字节偏移
+	program     *ast.Program
+	diagnostics []*parser.Diagnostic
+	analysis    *analysis //有语法错误时为空
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{uri: uri, text: text, lineStarts: []int{0}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lineStarts = append(d.lineStarts, i+1)
+		}
+	}
+	p := parser.New(lexer.NewWithFilename(uri, text))
+	d.program = p.ParseProgram()
+	d.diagnostics = p.Diagnostics()
+	if len(d.diagnostics) == 0 {
+		d.analysis = analyze(d.program, text)
+	}
+	return d
+}
+
+//position 字节偏移转换为LSP位置
+func (d *document) position(offset int) Position {
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+	line := sort.Search(len(d.lineStarts), func(i int) bool { return d.lineStarts[i] > offset }) - 1
+	character := 0
+	for _, r := range d.text[d.lineStarts[line]:offset] {
+		character += utf16Len(r)
+	}
+	return Position{Line: line, Character: character}
+}
+
+//offset LSP位置转换为字节偏移，超出行尾时取行尾
+func (d *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lineStarts) {
+		return len(d.text)
+	}
+	offset := d.lineStarts[pos.Line]
+	for character := 0; character < pos.Character && offset < len(d.text); {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		if r == '\n' {
+			break
+		}
+		character += utf16Len(r)
+		offset += size
+	}
+	return offset
+}
+
+func (d *document) rangeOf(start, end int) Range {
+	return Range{Start: d.position(start), End: d.position(end)}
+}
+
+//identRange 标识符在文档中的范围
+func (d *document) identRange(ident *ast.Identifier) Range {
+	return d.rangeOf(ident.Token.Pos.Offset, ident.Token.Pos.Offset+len(ident.Value))
+}
+
+//lspDiagnostics 语法错误转换为LSP诊断信息，修复建议附在信息之后
+func (d *document) lspDiagnostics() []Diagnostic {
+	result := []Diagnostic{}
+	for _, diag := range d.diagnostics {
+		end := diag.End
+		if !end.IsValid() || end.Offset < diag.Pos.Offset {
+			end = diag.Pos
+		}
+		message := diag.Message
+		for _, hint := range diag.Hints {
+			message += "\nhint: " + hint
+		}
+		result = append(result, Diagnostic{
+			Range:    d.rangeOf(diag.Pos.Offset, end.Offset),
+			Severity: int(diag.Severity),
+			Code:     diag.Code,
+			Source:   "monkey",
+			Message:  message,
+		})
+	}
+	return result
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+//tokenEnd 取token的结束偏移，没有结束位置时按字面量长度计算
+func tokenEnd(tok token.Token) int {
+	if tok.End.IsValid() {
+		return tok.End.Offset
+	}
+	return tok.Pos.Offset + len(tok.Literal)
+}
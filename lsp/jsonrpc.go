@@ -0,0 +1,66 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"monkey/frame"
+)
+
+// lsp/jsonrpc.go
+//
+//JSON-RPC 2.0 消息，分帧见frame包
+
+//JSON-RPC错误码
+const (
+	CodeParseError           = -32700
+	CodeInvalidRequest       = -32600
+	CodeMethodNotFound       = -32601
+	CodeInvalidParams        = -32602
+	CodeInternalError        = -32603
+	CodeServerNotInitialized = -32002
+)
+
+//Message 请求、通知或响应，请求与响应有ID，通知没有
+type Message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *ResponseError   `json:"error,omitempty"`
+}
+
+//ResponseError 响应中的错误
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+//ReadMessage 读取一条消息，输入结束时返回io.EOF
+func ReadMessage(r *bufio.Reader) (*Message, error) {
+	body, err := frame.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	msg := &Message{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, &ResponseError{Code: CodeParseError, Message: err.Error()}
+	}
+	return msg, nil
+}
+
+//WriteMessage 写出一条消息
+func WriteMessage(w io.Writer, msg *Message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return frame.Write(w, body)
+}
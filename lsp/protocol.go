@@ -0,0 +1,156 @@
+package lsp
+
+// lsp/protocol.go
+//
+//服务器用到的LSP协议类型，字段名与协议一致
+
+//Position 从0开始的行号与列号，列号按UTF-16编码单元计
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+//Range 起止位置，不包含结束位置
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+//Location 文档中的一个范围
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+type TextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type TextDocumentItem struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Version    int    `json:"version"`
+	Text       string `json:"text"`
+}
+
+type VersionedTextDocumentIdentifier struct {
+	URI     string `json:"uri"`
+	Version int    `json:"version"`
+}
+
+//TextDocumentContentChangeEvent 只支持全量同步，Text为修改后的全文
+type TextDocumentContentChangeEvent struct {
+	Text string `json:"text"`
+}
+
+type DidOpenTextDocumentParams struct {
+	TextDocument TextDocumentItem `json:"textDocument"`
+}
+
+type DidChangeTextDocumentParams struct {
+	TextDocument   VersionedTextDocumentIdentifier  `json:"textDocument"`
+	ContentChanges []TextDocumentContentChangeEvent `json:"contentChanges"`
+}
+
+type DidCloseTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+//TextDocumentPositionParams hover、definition与completion的参数
+type TextDocumentPositionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+type DocumentSymbolParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type DocumentFormattingParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type InitializeResult struct {
+	Capabilities ServerCapabilities `json:"capabilities"`
+	ServerInfo   *ServerInfo        `json:"serverInfo,omitempty"`
+}
+
+type ServerInfo struct {
+	Name string `json:"name"`
+}
+
+type ServerCapabilities struct {
+	TextDocumentSync           int                `json:"textDocumentSync"`
+	HoverProvider              bool               `json:"hoverProvider"`
+	DefinitionProvider         bool               `json:"definitionProvider"`
+	DocumentSymbolProvider     bool               `json:"documentSymbolProvider"`
+	CompletionProvider         *CompletionOptions `json:"completionProvider,omitempty"`
+	DocumentFormattingProvider bool               `json:"documentFormattingProvider"`
+}
+
+type CompletionOptions struct {
+	TriggerCharacters []string `json:"triggerCharacters,omitempty"`
+}
+
+//TextDocumentSyncFull 每次修改都发送全文
+const TextDocumentSyncFull = 1
+
+type PublishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+//Diagnostic Severity取值与parser.Severity相同：1错误 2警告 3信息 4提示
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity"`
+	Code     string `json:"code,omitempty"`
+	Source   string `json:"source"`
+	Message  string `json:"message"`
+}
+
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    *Range        `json:"range,omitempty"`
+}
+
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+//SymbolKind 只用到其中两种
+type SymbolKind int
+
+const (
+	SymbolKindFunction SymbolKind = 12
+	SymbolKindVariable SymbolKind = 13
+)
+
+type DocumentSymbol struct {
+	Name           string           `json:"name"`
+	Detail         string           `json:"detail,omitempty"`
+	Kind           SymbolKind       `json:"kind"`
+	Range          Range            `json:"range"`
+	SelectionRange Range            `json:"selectionRange"`
+	Children       []DocumentSymbol `json:"children,omitempty"`
+}
+
+//CompletionItemKind 只用到其中两种
+type CompletionItemKind int
+
+const (
+	CompletionItemKindFunction CompletionItemKind = 3
+	CompletionItemKindVariable CompletionItemKind = 6
+)
+
+type CompletionItem struct {
+	Label  string             `json:"label"`
+	Kind   CompletionItemKind `json:"kind"`
+	Detail string             `json:"detail,omitempty"`
+}
+
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
@@ -0,0 +1,295 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/format"
+)
+
+// lsp/server.go
+//
+//语言服务器，通过标准输入输出与编辑器交换JSON-RPC消息，依次处理每条消息。
+//文档只支持全量同步，修改后重新解析并发布语法错误
+
+//Server 语言服务器
+type Server struct {
+	in          *bufio.Reader
+	out         io.Writer
+	docs        map[string]*document
+	initialized bool
+	shutdown    bool
+}
+
+//NewServer 从in读取消息，响应和通知写入out
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{in: bufio.NewReader(in), out: out, docs: map[string]*document{}}
+}
+
+//Run 处理消息直到收到exit通知或输入结束，exit之前没有收到shutdown请求时返回错误
+func (s *Server) Run() error {
+	for {
+		msg, err := ReadMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if rerr, ok := err.(*ResponseError); ok {
+			if err := s.reply(nil, nil, rerr); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("exit without shutdown")
+			}
+			return nil
+		}
+		if msg.ID == nil {
+			s.notification(msg)
+			continue
+		}
+		result, rerr := s.request(msg)
+		if err := s.reply(msg.ID, result, rerr); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, rerr *ResponseError) error {
+	msg := &Message{ID: id, Error: rerr}
+	if id == nil {
+		null := json.RawMessage("null")
+		msg.ID = &null
+	}
+	if rerr == nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		msg.Result = data
+	}
+	return WriteMessage(s.out, msg)
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return WriteMessage(s.out, &Message{Method: method, Params: data})
+}
+
+//request 处理请求，没有结果时返回nil，序列化为null
+func (s *Server) request(msg *Message) (interface{}, *ResponseError) {
+	if msg.Method == "initialize" {
+		s.initialized = true
+		return &InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:           TextDocumentSyncFull,
+				HoverProvider:              true,
+				DefinitionProvider:         true,
+				DocumentSymbolProvider:     true,
+				CompletionProvider:         &CompletionOptions{},
+				DocumentFormattingProvider: true,
+			},
+			ServerInfo: &ServerInfo{Name: "monkey"},
+		}, nil
+	}
+	if !s.initialized {
+		return nil, &ResponseError{Code: CodeServerNotInitialized, Message: "server not initialized"}
+	}
+	switch msg.Method {
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/hover":
+		return s.withPosition(msg, s.hover)
+	case "textDocument/definition":
+		return s.withPosition(msg, s.definition)
+	case "textDocument/completion":
+		return s.withPosition(msg, s.completion)
+	case "textDocument/documentSymbol":
+		params := &DocumentSymbolParams{}
+		if err := json.Unmarshal(msg.Params, params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.documentSymbols(params.TextDocument.URI)
+	case "textDocument/formatting":
+		params := &DocumentFormattingParams{}
+		if err := json.Unmarshal(msg.Params, params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.formatting(params.TextDocument.URI)
+	}
+	return nil, &ResponseError{Code: CodeMethodNotFound, Message: "method not found: " + msg.Method}
+}
+
+//notification 处理通知，通知没有响应，无法处理的通知直接忽略
+func (s *Server) notification(msg *Message) {
+	switch msg.Method {
+	case "textDocument/didOpen":
+		params := &DidOpenTextDocumentParams{}
+		if json.Unmarshal(msg.Params, params) == nil {
+			s.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		params := &DidChangeTextDocumentParams{}
+		if json.Unmarshal(msg.Params, params) == nil && len(params.ContentChanges) > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+		}
+	case "textDocument/didClose":
+		params := &DidCloseTextDocumentParams{}
+		if json.Unmarshal(msg.Params, params) == nil {
+			delete(s.docs, params.TextDocument.URI)
+			s.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+		}
+	}
+}
+
+//update 重新解析文档并发布诊断信息
+func (s *Server) update(uri, text string) {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+	s.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{URI: uri, Diagnostics: doc.lspDiagnostics()})
+}
+
+func invalidParams(err error) *ResponseError {
+	return &ResponseError{Code: CodeInvalidParams, Message: err.Error()}
+}
+
+//withPosition 解析位置参数，找到对应的文档后交给handler，文档没有打开时结果为null
+func (s *Server) withPosition(msg *Message, handler func(doc *document, offset int) interface{}) (interface{}, *ResponseError) {
+	params := &TextDocumentPositionParams{}
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		return nil, invalidParams(err)
+	}
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return nil, nil
+	}
+	return handler(doc, doc.offset(params.Position)), nil
+}
+
+//hover 展示标识符绑定的定义
+func (s *Server) hover(doc *document, offset int) interface{} {
+	if doc.analysis == nil {
+		return nil
+	}
+	ident := doc.analysis.identAt(offset)
+	if ident == nil {
+		return nil
+	}
+	var text string
+	if b, ok := doc.analysis.refs[ident]; ok {
+		text = describe(b)
+	} else if _, ok := evaluator.GetBuiltin(ident.Value); ok {
+		text = "(builtin) " + ident.Value
+	} else {
+		return nil
+	}
+	r := doc.identRange(ident)
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```monkey\n" + text + "\n```"},
+		Range:    &r,
+	}
+}
+
+//definition 跳转到标识符绑定的位置
+func (s *Server) definition(doc *document, offset int) interface{} {
+	if doc.analysis == nil {
+		return nil
+	}
+	ident := doc.analysis.identAt(offset)
+	if ident == nil {
+		return nil
+	}
+	b, ok := doc.analysis.refs[ident]
+	if !ok {
+		return nil
+	}
+	return &Location{URI: doc.uri, Range: doc.identRange(b.ident)}
+}
+
+//completion 光标处可见的名字与内置函数
+func (s *Server) completion(doc *document, offset int) interface{} {
+	items := []CompletionItem{}
+	seen := map[string]bool{}
+	if doc.analysis != nil {
+		for _, b := range doc.analysis.visible(offset) {
+			kind := CompletionItemKindVariable
+			switch b.value.(type) {
+			case *ast.FunctionLiteral, *ast.MacroLiteral:
+				kind = CompletionItemKindFunction
+			}
+			seen[b.ident.Value] = true
+			items = append(items, CompletionItem{Label: b.ident.Value, Kind: kind, Detail: describe(b)})
+		}
+	}
+	for _, name := range evaluator.BuiltinNames {
+		if !seen[name] {
+			items = append(items, CompletionItem{Label: name, Kind: CompletionItemKindFunction, Detail: "(builtin) " + name})
+		}
+	}
+	return items
+}
+
+//documentSymbols let绑定的名字，函数中的let绑定作为子节点
+func (s *Server) documentSymbols(uri string) (interface{}, *ResponseError) {
+	doc, ok := s.docs[uri]
+	if !ok || doc.analysis == nil {
+		return []DocumentSymbol{}, nil
+	}
+	return doc.symbols(doc.program.Statements, len(doc.text)), nil
+}
+
+func (d *document) symbols(stmts []ast.Statement, limit int) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for i, stmt := range stmts {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok {
+			continue
+		}
+		symbol := DocumentSymbol{
+			Name:           let.Name.Value,
+			Kind:           SymbolKindVariable,
+			Range:          d.rangeOf(let.Pos().Offset, d.analysis.statementEnd(stmts, i, limit)),
+			SelectionRange: d.identRange(let.Name),
+		}
+		var body *ast.BlockStatement
+		switch fn := let.Value.(type) {
+		case *ast.FunctionLiteral:
+			body = fn.Body
+		case *ast.MacroLiteral:
+			body = fn.Body
+		}
+		if body != nil {
+			symbol.Kind = SymbolKindFunction
+			symbol.Detail = signature(let.Value)
+			if end, ok := d.analysis.braces[body.Token.Pos.Offset]; ok {
+				symbol.Children = d.symbols(body.Statements, end)
+			}
+		}
+		symbols = append(symbols, symbol)
+	}
+	return symbols
+}
+
+//formatting 格式化整个文档，有语法错误时不做修改
+func (s *Server) formatting(uri string) (interface{}, *ResponseError) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		return []TextEdit{}, nil
+	}
+	formatted, err := format.Source(uri, []byte(doc.text))
+	if err != nil || string(formatted) == doc.text {
+		return []TextEdit{}, nil
+	}
+	return []TextEdit{{Range: doc.rangeOf(0, len(doc.text)), NewText: string(formatted)}}, nil
+}
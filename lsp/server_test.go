@@ -0,0 +1,405 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+//client 脚本化的客户端，通过管道与服务器通信
+type client struct {
+	t             *testing.T
+	w             io.WriteCloser
+	r             *bufio.Reader
+	nextID        int
+	notifications []*Message
+	done          chan error
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &client{t: t, w: clientOut, r: bufio.NewReader(clientIn), done: make(chan error, 1)}
+	go func() {
+		err := NewServer(serverIn, serverOut).Run()
+		serverOut.Close()
+		c.done <- err
+	}()
+	return c
+}
+
+func (c *client) send(msg *Message, params interface{}) {
+	c.t.Helper()
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		msg.Params = data
+	}
+	if err := WriteMessage(c.w, msg); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+//call 发送请求并等待响应，期间收到的通知保存在notifications中
+func (c *client) call(method string, params interface{}, result interface{}) *ResponseError {
+	c.t.Helper()
+	c.nextID++
+	id := json.RawMessage(strconv.Itoa(c.nextID))
+	c.send(&Message{ID: &id, Method: method}, params)
+	for {
+		msg, err := ReadMessage(c.r)
+		if err != nil {
+			c.t.Fatalf("%s: %v", method, err)
+		}
+		if msg.ID == nil {
+			c.notifications = append(c.notifications, msg)
+			continue
+		}
+		if string(*msg.ID) != string(id) {
+			c.t.Fatalf("%s: wrong response id. got=%s, want=%s", method, *msg.ID, id)
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("%s: %v", method, err)
+			}
+		}
+		return nil
+	}
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+	c.send(&Message{Method: method}, params)
+}
+
+//diagnostics 读取下一条publishDiagnostics通知
+func (c *client) diagnostics() *PublishDiagnosticsParams {
+	c.t.Helper()
+	msg, err := ReadMessage(c.r)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if msg.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("expected publishDiagnostics. got=%q", msg.Method)
+	}
+	params := &PublishDiagnosticsParams{}
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		c.t.Fatal(err)
+	}
+	return params
+}
+
+func (c *client) open(uri, text string) *PublishDiagnosticsParams {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", &DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "monkey", Version: 1, Text: text},
+	})
+	return c.diagnostics()
+}
+
+func (c *client) exit() error {
+	c.t.Helper()
+	if err := c.call("shutdown", nil, nil); err != nil {
+		c.t.Fatal(err)
+	}
+	c.notify("exit", nil)
+	return <-c.done
+}
+
+func startClient(t *testing.T) *client {
+	c := newClient(t)
+	result := &InitializeResult{}
+	if err := c.call("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, result); err != nil {
+		t.Fatal(err)
+	}
+	if !result.Capabilities.HoverProvider || result.Capabilities.TextDocumentSync != TextDocumentSyncFull {
+		t.Fatalf("wrong capabilities. got=%+v", result.Capabilities)
+	}
+	c.notify("initialized", map[string]interface{}{})
+	return c
+}
+
+func at(uri string, line, character int) *TextDocumentPositionParams {
+	return &TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: line, Character: character},
+	}
+}
+
+const uri = "file:///main.mk"
+
+const source = `let add = fn(a, b = 1) {
+  let sum = a + b;
+  sum
+};
+let x = add(1, 2);
+for (item in [x]) { puts(item) }
+`
+
+func TestDiagnostics(t *testing.T) {
+	c := startClient(t)
+	params := c.open(uri, "let x = 1;\nlet = 2;")
+	if params.URI != uri || len(params.Diagnostics) != 1 {
+		t.Fatalf("wrong diagnostics. got=%+v", params)
+	}
+	d := params.Diagnostics[0]
+	if d.Severity != 1 || d.Code != "E001" || d.Range.Start != (Position{Line: 1, Character: 4}) {
+		t.Errorf("wrong diagnostic. got=%+v", d)
+	}
+
+	c.notify("textDocument/didChange", &DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let x = 1;\nlet y = 2;"}},
+	})
+	if params := c.diagnostics(); len(params.Diagnostics) != 0 {
+		t.Errorf("expected no diagnostics after change. got=%+v", params.Diagnostics)
+	}
+
+	c.notify("textDocument/didClose", &DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}})
+	if params := c.diagnostics(); len(params.Diagnostics) != 0 {
+		t.Errorf("expected diagnostics to be cleared. got=%+v", params.Diagnostics)
+	}
+	if err := c.exit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHover(t *testing.T) {
+	c := startClient(t)
+	c.open(uri, source)
+	tests := []struct {
+		line, character int
+		expected        string
+	}{
+		{4, 9, "let add = fn(a, b = 1)"},
+		{1, 12, "(parameter) a of add = fn(a, b = 1)"},
+		{2, 3, "let sum = a + b"},
+		{5, 26, "(loop variable) item"},
+		{5, 21, "(builtin) puts"},
+		{5, 7, "(loop variable) item"},
+	}
+	for _, tt := range tests {
+		hover := &Hover{}
+		if err := c.call("textDocument/hover", at(uri, tt.line, tt.character), hover); err != nil {
+			t.Fatal(err)
+		}
+		expected := "```monkey\n" + tt.expected + "\n```"
+		if hover.Contents.Value != expected {
+			t.Errorf("wrong hover at %d:%d. want=%q, got=%q", tt.line, tt.character, expected, hover.Contents.Value)
+		}
+	}
+
+	var hover *Hover
+	if err := c.call("textDocument/hover", at(uri, 0, 22), &hover); err != nil {
+		t.Fatal(err)
+	}
+	if hover != nil {
+		t.Errorf("expected no hover outside identifiers. got=%+v", hover)
+	}
+	c.exit()
+}
+
+func TestDefinition(t *testing.T) {
+	c := startClient(t)
+	c.open(uri, source+"let f = fn(x) { x };\n")
+	tests := []struct {
+		line, character int
+		expected        *Range
+	}{
+		{4, 9, &Range{Position{0, 4}, Position{0, 7}}},
+		{1, 12, &Range{Position{0, 13}, Position{0, 14}}},
+		{1, 16, &Range{Position{0, 16}, Position{0, 17}}},
+		{2, 2, &Range{Position{1, 6}, Position{1, 9}}},
+		{5, 15, &Range{Position{4, 4}, Position{4, 5}}},
+		{6, 16, &Range{Position{6, 11}, Position{6, 12}}},
+		{5, 21, nil},
+	}
+	for _, tt := range tests {
+		var location *Location
+		if err := c.call("textDocument/definition", at(uri, tt.line, tt.character), &location); err != nil {
+			t.Fatal(err)
+		}
+		if tt.expected == nil {
+			if location != nil {
+				t.Errorf("expected no definition at %d:%d. got=%+v", tt.line, tt.character, location)
+			}
+			continue
+		}
+		if location == nil || location.URI != uri || location.Range != *tt.expected {
+			t.Errorf("wrong definition at %d:%d. want=%+v, got=%+v", tt.line, tt.character, tt.expected, location)
+		}
+	}
+	c.exit()
+}
+
+func TestCatchScope(t *testing.T) {
+	c := startClient(t)
+	c.open(uri, "let e = 1;\ntry { throw 2 } catch (e) { e };\ne;\ntry { 1 } catch (err) { err };\nerr\n")
+	tests := []struct {
+		line, character int
+		expected        *Range
+	}{
+		{1, 28, &Range{Position{1, 23}, Position{1, 24}}},
+		{2, 0, &Range{Position{0, 4}, Position{0, 5}}},
+		{3, 25, &Range{Position{3, 17}, Position{3, 20}}},
+		{4, 0, nil},
+	}
+	for _, tt := range tests {
+		var location *Location
+		if err := c.call("textDocument/definition", at(uri, tt.line, tt.character), &location); err != nil {
+			t.Fatal(err)
+		}
+		if tt.expected == nil {
+			if location != nil {
+				t.Errorf("expected no definition at %d:%d. got=%+v", tt.line, tt.character, location)
+			}
+			continue
+		}
+		if location == nil || location.Range != *tt.expected {
+			t.Errorf("wrong definition at %d:%d. want=%+v, got=%+v", tt.line, tt.character, tt.expected, location)
+		}
+	}
+
+	hover := &Hover{}
+	if err := c.call("textDocument/hover", at(uri, 2, 0), hover); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "```monkey\nlet e = 1\n```"; hover.Contents.Value != expected {
+		t.Errorf("wrong hover. want=%q, got=%q", expected, hover.Contents.Value)
+	}
+	c.exit()
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	c := startClient(t)
+	c.open(uri, source)
+	symbols := []DocumentSymbol{}
+	params := &DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}}
+	if err := c.call("textDocument/documentSymbol", params, &symbols); err != nil {
+		t.Fatal(err)
+	}
+	expected := []DocumentSymbol{
+		{
+			Name:           "add",
+			Detail:         "fn(a, b = 1)",
+			Kind:           SymbolKindFunction,
+			Range:          Range{Position{0, 0}, Position{3, 2}},
+			SelectionRange: Range{Position{0, 4}, Position{0, 7}},
+			Children: []DocumentSymbol{{
+				Name:           "sum",
+				Kind:           SymbolKindVariable,
+				Range:          Range{Position{1, 2}, Position{1, 18}},
+				SelectionRange: Range{Position{1, 6}, Position{1, 9}},
+			}},
+		},
+		{
+			Name:           "x",
+			Kind:           SymbolKindVariable,
+			Range:          Range{Position{4, 0}, Position{4, 18}},
+			SelectionRange: Range{Position{4, 4}, Position{4, 5}},
+		},
+	}
+	if !reflect.DeepEqual(symbols, expected) {
+		t.Errorf("wrong symbols.\nwant=%+v\ngot=%+v", expected, symbols)
+	}
+	c.exit()
+}
+
+func TestCompletion(t *testing.T) {
+	c := startClient(t)
+	c.open(uri, source)
+	tests := []struct {
+		line, character int
+		expected        []string
+	}{
+		{2, 2, []string{"a", "b", "sum", "add", "x", "item"}},
+		{5, 0, []string{"add", "x", "item"}},
+	}
+	for _, tt := range tests {
+		items := []CompletionItem{}
+		if err := c.call("textDocument/completion", at(uri, tt.line, tt.character), &items); err != nil {
+			t.Fatal(err)
+		}
+		labels := []string{}
+		builtins := 0
+		for _, item := range items {
+			if item.Detail == "(builtin) "+item.Label {
+				builtins++
+				continue
+			}
+			labels = append(labels, item.Label)
+		}
+		if !reflect.DeepEqual(labels, tt.expected) {
+			t.Errorf("wrong completion at %d:%d. want=%v, got=%v", tt.line, tt.character, tt.expected, labels)
+		}
+		if builtins == 0 {
+			t.Errorf("expected builtins in completion at %d:%d", tt.line, tt.character)
+		}
+	}
+	c.exit()
+}
+
+func TestFormatting(t *testing.T) {
+	c := startClient(t)
+	c.open(uri, "let x=1;\nputs( x )")
+	edits := []TextEdit{}
+	params := &DocumentFormattingParams{TextDocument: TextDocumentIdentifier{URI: uri}}
+	if err := c.call("textDocument/formatting", params, &edits); err != nil {
+		t.Fatal(err)
+	}
+	expected := []TextEdit{{Range: Range{Position{0, 0}, Position{1, 9}}, NewText: "let x = 1;\nputs(x);\n"}}
+	if !reflect.DeepEqual(edits, expected) {
+		t.Errorf("wrong edits. want=%+v, got=%+v", expected, edits)
+	}
+	c.exit()
+}
+
+func TestProtocolErrors(t *testing.T) {
+	c := newClient(t)
+	if err := c.call("textDocument/hover", at(uri, 0, 0), nil); err == nil || err.Code != CodeServerNotInitialized {
+		t.Errorf("expected ServerNotInitialized. got=%v", err)
+	}
+	c.call("initialize", map[string]interface{}{}, nil)
+	if err := c.call("workspace/unknown", nil, nil); err == nil || err.Code != CodeMethodNotFound {
+		t.Errorf("expected MethodNotFound. got=%v", err)
+	}
+	if err := c.call("textDocument/hover", []int{1}, nil); err == nil || err.Code != CodeInvalidParams {
+		t.Errorf("expected InvalidParams. got=%v", err)
+	}
+	c.notify("exit", nil)
+	if err := <-c.done; err == nil {
+		t.Errorf("expected error for exit without shutdown")
+	}
+}
+
+func TestPositions(t *testing.T) {
+	doc := newDocument(uri, "let s = \"日😀\";\nlet t = s;")
+	tests := []struct {
+		offset   int
+		expected Position
+	}{
+		{0, Position{0, 0}},
+		{9, Position{0, 9}},
+		{12, Position{0, 10}},
+		{16, Position{0, 12}},
+		{19, Position{1, 0}},
+		{29, Position{1, 10}},
+	}
+	for _, tt := range tests {
+		pos := doc.position(tt.offset)
+		if pos != tt.expected {
+			t.Errorf("wrong position for offset %d. want=%+v, got=%+v", tt.offset, tt.expected, pos)
+		}
+		if offset := doc.offset(pos); offset != tt.offset {
+			t.Errorf("wrong offset for %+v. want=%d, got=%d", pos, tt.offset, offset)
+		}
+	}
+}
@@ -1,28 +1,58 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"monkey/bytecode"
 	"monkey/explainer"
 	"monkey/repl"
 	"os"
 	"os/user"
+	"path/filepath"
 )
 
+var engine = flag.String("engine", repl.EngineEval, "执行引擎: eval 或 vm")
+
 func main() {
-	if len(os.Args) == 1 {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: monkey [-engine=eval|vm] [file.mk|file.mkc]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       monkey build [-o file.mkc] file.mk\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       monkey fmt [-w] [-l] file.mk...\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       monkey lint [-disable=L002,...] [-rules] file.mk...\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       monkey lsp\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       monkey dap\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       monkey tokens|ast [-json] file.mk\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       monkey disasm [-json] file.mk|file.mkc\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *engine != repl.EngineEval && *engine != repl.EngineVM {
+		fmt.Printf("unknown engine: %s\n", *engine)
+		os.Exit(2)
+	}
+	args := flag.Args()
+	if len(args) == 0 {
 		startWithRepl()
+		return
+	}
+	if command, ok := commands[args[0]]; ok {
+		os.Exit(command(args[1:]))
 	}
-	args := os.Args
 	starWithFile(args)
 }
 
 func starWithFile(args []string) {
-	file, err := os.Open(args[1])
+	file, err := os.Open(args[0])
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
-	explainer.Start(file, os.Stdout)
+	defer file.Close()
+	if filepath.Ext(args[0]) == bytecode.Extension {
+		explainer.StartBytecode(file, os.Stdout)
+		return
+	}
+	explainer.StartFileWithEngine(*engine, args[0], file, os.Stdout)
 }
 
 func startWithRepl() {
@@ -32,5 +62,5 @@ func startWithRepl() {
 	}
 	fmt.Printf("Hello %s! This is the Monkey programming language!\n", user.Username)
 	fmt.Printf("Feel free to types in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	repl.StartWithEngine(*engine, os.Stdin, os.Stdout)
 }
@@ -0,0 +1,224 @@
+package marshal
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"monkey/evaluator"
+	"monkey/object"
+	"reflect"
+	"strings"
+)
+
+// marshal/marshal.go
+//
+//Go值与Monkey对象之间的转换：
+//整数、浮点数、字符串、布尔值对应Integer、Float、String、Boolean，*big.Int对应BigInt，
+//切片和数组对应Array，map和结构体对应Hash，nil指针、nil切片、nil map和nil接口对应null。
+//结构体的导出字段以字段名为键，可以用标签 `monkey:"name,omitempty"` 修改键名，`monkey:"-"` 忽略该字段，
+//匿名的结构体字段没有标签时展开到外层。已经是object.Object的值原样保留
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	bigIntType = reflect.TypeOf(big.Int{})
+)
+
+//UnsupportedTypeError Go值的类型无法转换为Monkey对象，如函数和通道
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return fmt.Sprintf("cannot convert %s to a Monkey value", e.Type)
+}
+
+//Marshal 将Go值转换为Monkey对象
+func Marshal(v interface{}) (object.Object, error) {
+	return marshal(reflect.ValueOf(v), map[visit]bool{})
+}
+
+//visit 正在转换的指针、map或切片
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+//marshal visiting为正在转换的引用，用于发现循环引用
+func marshal(v reflect.Value, visiting map[visit]bool) (object.Object, error) {
+	if !v.IsValid() {
+		return evaluator.NULL, nil
+	}
+	if v.Type().Implements(objectType) && !(v.Kind() == reflect.Ptr && v.IsNil()) {
+		if v.Kind() == reflect.Interface && v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return v.Interface().(object.Object), nil
+	}
+	if v.Type() == bigIntType {
+		value := v.Interface().(big.Int)
+		return &object.BigInt{Value: new(big.Int).Set(&value)}, nil
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return &object.BigInt{Value: new(big.Int).SetUint64(v.Uint())}, nil
+		}
+		return &object.Integer{Value: int64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Bool:
+		return evaluator.NativeBool(v.Bool()), nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return marshal(v.Elem(), visiting)
+	case reflect.Ptr:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		if err := enter(v, visiting); err != nil {
+			return nil, err
+		}
+		defer delete(visiting, visit{v.Pointer(), v.Type()})
+		return marshal(v.Elem(), visiting)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() {
+				return evaluator.NULL, nil
+			}
+			if err := enter(v, visiting); err != nil {
+				return nil, err
+			}
+			defer delete(visiting, visit{v.Pointer(), v.Type()})
+		}
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			element, err := marshal(v.Index(i), visiting)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+			elements[i] = element
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		if err := enter(v, visiting); err != nil {
+			return nil, err
+		}
+		defer delete(visiting, visit{v.Pointer(), v.Type()})
+		hash := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := marshal(iter.Key(), visiting)
+			if err != nil {
+				return nil, err
+			}
+			value, err := marshal(iter.Value(), visiting)
+			if err != nil {
+				return nil, fmt.Errorf("value of %s: %w", key.Inspect(), err)
+			}
+			if err := set(hash, key, value); err != nil {
+				return nil, err
+			}
+		}
+		return hash, nil
+	case reflect.Struct:
+		hash := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+		for _, f := range structFields(v.Type()) {
+			fv := v.FieldByIndex(f.index)
+			if f.omitEmpty && fv.IsZero() {
+				continue
+			}
+			value, err := marshal(fv, visiting)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.name, err)
+			}
+			key := &object.String{Value: f.name}
+			hash.Pairs[key.HashKey()] = object.HashPair{Key: key, Value: value}
+		}
+		return hash, nil
+	}
+	return nil, &UnsupportedTypeError{Type: v.Type()}
+}
+
+//enter 记录正在转换的引用，已经在转换中时为循环引用
+func enter(v reflect.Value, visiting map[visit]bool) error {
+	key := visit{v.Pointer(), v.Type()}
+	if visiting[key] {
+		return fmt.Errorf("cannot convert cyclic value of type %s", v.Type())
+	}
+	visiting[key] = true
+	return nil
+}
+
+func set(hash *object.Hash, key, value object.Object) error {
+	hashable, ok := key.(object.Hashable)
+	if !ok {
+		return fmt.Errorf("unusable as hash key: %s", key.Type())
+	}
+	hash.Pairs[hashable.HashKey()] = object.HashPair{Key: key, Value: value}
+	return nil
+}
+
+//field 结构体中参与转换的字段
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+//structFields 结构体的导出字段，外层的同名字段优先
+func structFields(t reflect.Type) []field {
+	fields := []field{}
+	seen := map[string]bool{}
+	var collect func(t reflect.Type, index []int)
+	embedded := [][]int{}
+	collect = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("monkey")
+			if tag == "-" {
+				continue
+			}
+			name, options := tag, ""
+			if comma := strings.IndexByte(tag, ','); comma >= 0 {
+				name, options = tag[:comma], tag[comma+1:]
+			}
+			fieldIndex := append(append([]int{}, index...), i)
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct && sf.Type.Kind() != reflect.Ptr {
+				embedded = append(embedded, fieldIndex)
+				continue
+			}
+			if sf.PkgPath != "" {
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			fields = append(fields, field{name: name, index: fieldIndex, omitEmpty: options == "omitempty"})
+		}
+	}
+	collect(t, nil)
+	//匿名字段中的字段在外层字段之后处理，同名时外层字段优先
+	for len(embedded) > 0 {
+		index := embedded[0]
+		embedded = embedded[1:]
+		collect(t.FieldByIndex(index).Type, index)
+	}
+	return fields
+}
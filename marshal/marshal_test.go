@@ -0,0 +1,294 @@
+package marshal
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type Base struct {
+	ID   int
+	Name string `monkey:"name"`
+}
+
+type user struct {
+	Base
+	Name    string            `monkey:"name"`
+	Email   string            `monkey:"email,omitempty"`
+	Tags    []string          `monkey:"tags"`
+	Manager *user             `monkey:"manager"`
+	Extra   interface{}       `monkey:"extra"`
+	Meta    map[string]string `monkey:"meta,omitempty"`
+	Secret  string            `monkey:"-"`
+	hidden  int
+}
+
+type node struct {
+	Next *node
+}
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{nil, "null"},
+		{5, "5"},
+		{int8(-3), "-3"},
+		{uint64(math.MaxUint64), "18446744073709551615"},
+		{2.5, "2.5"},
+		{"hi", "hi"},
+		{true, "true"},
+		{big.NewInt(7), "7"},
+		{[]int{1, 2}, "[1, 2]"},
+		{[2]bool{true, false}, "[true, false]"},
+		{[]int(nil), "null"},
+		{map[string]int{"b": 2, "a": 1}, "{a: 1, b: 2}"},
+		{map[int][]string{1: {"x"}}, "{1: [x]}"},
+		{[]interface{}{1, "a", nil, []interface{}{2.5}}, "[1, a, null, [2.5]]"},
+		{&object.Integer{Value: 9}, "9"},
+		{[]object.Object{&object.String{Value: "s"}}, "[s]"},
+		{Base{ID: 1, Name: "b"}, "{ID: 1, name: b}"},
+		{user{Base: Base{ID: 1, Name: "inner"}, Name: "ann", Tags: []string{"x"}, Secret: "s", hidden: 1},
+			"{ID: 1, extra: null, manager: null, name: ann, tags: [x]}"},
+		{&user{Name: "bob", Email: "b@x", Manager: &user{Name: "ann"}, Extra: map[string]int{"n": 1}, Meta: map[string]string{"k": "v"}},
+			"{ID: 0, email: b@x, extra: {n: 1}, manager: {ID: 0, extra: null, manager: null, name: ann, tags: null}, meta: {k: v}, name: bob, tags: null}"},
+	}
+	for _, tt := range tests {
+		obj, err := Marshal(tt.input)
+		if err != nil {
+			t.Errorf("Marshal(%#v) failed: %s", tt.input, err)
+			continue
+		}
+		if got := inspect(obj); got != tt.expected {
+			t.Errorf("Marshal(%#v) wrong. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestMarshalSingletons(t *testing.T) {
+	if obj, _ := Marshal(true); obj != evaluator.TRUE {
+		t.Errorf("true is not TRUE. got=%#v", obj)
+	}
+	if obj, _ := Marshal((*user)(nil)); obj != evaluator.NULL {
+		t.Errorf("nil pointer is not NULL. got=%#v", obj)
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	cyclic := &node{}
+	cyclic.Next = cyclic
+	loop := []interface{}{nil}
+	loop[0] = loop
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{func() {}, "cannot convert func() to a Monkey value"},
+		{make(chan int), "cannot convert chan int to a Monkey value"},
+		{[]interface{}{1, func() {}}, "element 1: cannot convert func() to a Monkey value"},
+		{map[string]interface{}{"f": make(chan int)}, "value of f: cannot convert chan int to a Monkey value"},
+		{struct{ F func() }{}, "field F: cannot convert func() to a Monkey value"},
+		{map[[1]int]int{{1}: 1}, "unusable as hash key: ARRAY"},
+		{cyclic, "field Next: cannot convert cyclic value of type *marshal.node"},
+		{loop, "element 0: cannot convert cyclic value of type []interface {}"},
+	}
+	for _, tt := range tests {
+		_, err := Marshal(tt.input)
+		if err == nil {
+			t.Errorf("Marshal(%T) should fail", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, err)
+		}
+	}
+	_, err := Marshal([]func(){nil})
+	var unsupported *UnsupportedTypeError
+	if !errors.As(err, &unsupported) || unsupported.Type != reflect.TypeOf(func() {}) {
+		t.Errorf("expected wrapped UnsupportedTypeError. got=%#v", err)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	var (
+		i     int
+		u8    uint8
+		f     float64
+		s     string
+		b     bool
+		bi    big.Int
+		ints  []int
+		arr   [2]string
+		m     map[string][]int
+		keyed map[int]bool
+		any   interface{}
+		obj   object.Object
+		hash  *object.Hash
+		p     *int
+		usr   user
+	)
+	tests := []struct {
+		input    string
+		target   interface{}
+		expected interface{}
+	}{
+		{`42`, &i, 42},
+		{`255`, &u8, uint8(255)},
+		{`1.5`, &f, 1.5},
+		{`3`, &f, 3.0},
+		{`"str"`, &s, "str"},
+		{`1 < 2`, &b, true},
+		{`9223372036854775807 + 1`, &bi, *new(big.Int).Lsh(big.NewInt(1), 63)},
+		{`[1, 2, 3]`, &ints, []int{1, 2, 3}},
+		{`if (false) { 1 }`, &ints, []int(nil)},
+		{`["a", "b"]`, &arr, [2]string{"a", "b"}},
+		{`{"a": [1], "b": []}`, &m, map[string][]int{"a": {1}, "b": {}}},
+		{`{1: true, 2: false}`, &keyed, map[int]bool{1: true, 2: false}},
+		{`[1, "a", 2.5, if (false) { 1 }, {"k": [true]}]`, &any,
+			[]interface{}{int64(1), "a", 2.5, nil, map[string]interface{}{"k": []interface{}{true}}}},
+		{`{1: 2}`, &obj, nil},
+		{`{1: 2}`, &hash, nil},
+		{`5`, &p, intPtr(5)},
+		{`if (false) { 1 }`, &p, (*int)(nil)},
+		{`{"ID": 3, "name": "ann", "EMAIL": "a@x", "tags": ["x"], "manager": {"name": "bob"}, "extra": 1, "Secret": "s", "hidden": 1, "unknown": 0}`,
+			&usr, user{Base: Base{ID: 3}, Name: "ann", Email: "a@x", Tags: []string{"x"}, Manager: &user{Name: "bob"}, Extra: int64(1)}},
+	}
+	for _, tt := range tests {
+		input := eval(t, tt.input)
+		if err := Unmarshal(input, tt.target); err != nil {
+			t.Errorf("Unmarshal(%s) failed: %s", tt.input, err)
+			continue
+		}
+		got := reflect.ValueOf(tt.target).Elem().Interface()
+		if tt.expected == nil {
+			if got != input {
+				t.Errorf("Unmarshal(%s) should keep the object. got=%#v", tt.input, got)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("Unmarshal(%s) wrong. expected=%#v, got=%#v", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestUnmarshalInterfaceKeepsFunctions(t *testing.T) {
+	var any interface{}
+	if err := Unmarshal(eval(t, `fn(x) { x }`), &any); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := any.(*object.Function); !ok {
+		t.Errorf("expected *object.Function. got=%T", any)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var (
+		i    int
+		i8   int8
+		u    uint
+		s    string
+		arr  [1]int
+		m    map[string]int
+		any  interface{}
+		fn   func()
+		ch   chan int
+		usr  user
+		strs []string
+	)
+	tests := []struct {
+		input    string
+		target   interface{}
+		expected string
+	}{
+		{`"a"`, &i, "cannot convert STRING to int"},
+		{`1000`, &i8, "1000 overflows int8"},
+		{`-1`, &u, "-1 overflows uint"},
+		{`9223372036854775807 + 1`, &i, "9223372036854775808 overflows int"},
+		{`if (false) { 1 }`, &s, "cannot convert NULL to string"},
+		{`[1, 2]`, &arr, "cannot convert ARRAY of length 2 to [1]int"},
+		{`{"a": "b"}`, &m, "value of a: cannot convert STRING to int"},
+		{`{1: 1}`, &m, "key 1: cannot convert INTEGER to string"},
+		{`{1: 1}`, &any, "cannot convert hash with INTEGER keys to map[string]interface {}"},
+		{`fn() {}`, &fn, "cannot convert FUNCTION to func()"},
+		{`1`, &ch, "cannot convert INTEGER to chan int"},
+		{`{"manager": {"tags": [1]}}`, &usr, "field manager: field tags: element 0: cannot convert INTEGER to string"},
+		{`[1]`, &strs, "element 0: cannot convert INTEGER to string"},
+	}
+	for _, tt := range tests {
+		err := Unmarshal(eval(t, tt.input), tt.target)
+		if err == nil {
+			t.Errorf("Unmarshal(%s) should fail", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error for %s. expected=%q, got=%q", tt.input, tt.expected, err)
+		}
+	}
+
+	err := Unmarshal(eval(t, `[1]`), &strs)
+	var typeErr *UnmarshalTypeError
+	if !errors.As(err, &typeErr) || typeErr.Value != object.INTEGER_OBJ || typeErr.Type != reflect.TypeOf("") {
+		t.Errorf("expected wrapped UnmarshalTypeError. got=%#v", err)
+	}
+	for _, target := range []interface{}{nil, i, (*int)(nil)} {
+		var invalid *InvalidUnmarshalError
+		if err := Unmarshal(evaluator.NULL, target); !errors.As(err, &invalid) {
+			t.Errorf("Unmarshal(%T) should fail with InvalidUnmarshalError. got=%v", target, err)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	in := user{Base: Base{ID: 7}, Name: "ann", Tags: []string{"a", "b"}, Manager: &user{Name: "bob", Meta: map[string]string{"k": "v"}}}
+	obj, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out user
+	if err := Unmarshal(obj, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("round trip changed value. expected=%#v, got=%#v", in, out)
+	}
+}
+
+func eval(t *testing.T, input string) object.Object {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parse %q failed: %v", input, p.Errors())
+	}
+	return evaluator.Eval(program, object.NewEnvironment())
+}
+
+//inspect 与Inspect相同，但哈希按键排序
+func inspect(obj object.Object) string {
+	switch obj := obj.(type) {
+	case *object.Array:
+		elements := []string{}
+		for _, e := range obj.Elements {
+			elements = append(elements, inspect(e))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *object.Hash:
+		pairs := []string{}
+		for _, pair := range obj.SortedPairs() {
+			pairs = append(pairs, inspect(pair.Key)+": "+inspect(pair.Value))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	}
+	return obj.Inspect()
+}
+
+func intPtr(n int) *int { return &n }
@@ -0,0 +1,293 @@
+package marshal
+
+import (
+	"fmt"
+	"math/big"
+	"monkey/object"
+	"reflect"
+	"strings"
+)
+
+//UnmarshalTypeError Monkey对象无法转换为目标类型
+type UnmarshalTypeError struct {
+	Value object.ObjectType
+	Type  reflect.Type
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	return fmt.Sprintf("cannot convert %s to %s", e.Value, e.Type)
+}
+
+//InvalidUnmarshalError Unmarshal的目标不是非nil指针
+type InvalidUnmarshalError struct {
+	Type reflect.Type
+}
+
+func (e *InvalidUnmarshalError) Error() string {
+	if e.Type == nil {
+		return "Unmarshal(nil)"
+	}
+	if e.Type.Kind() != reflect.Ptr {
+		return "Unmarshal(non-pointer " + e.Type.String() + ")"
+	}
+	return "Unmarshal(nil " + e.Type.String() + ")"
+}
+
+//Unmarshal 将Monkey对象转换后保存到target指向的值中，target必须是非nil指针
+//null转换为nil指针、nil切片、nil map和nil接口；转换为interface{}时，
+//数组为[]interface{}，键都是字符串的哈希为map[string]interface{}，函数等其他对象原样保留
+func Unmarshal(obj object.Object, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return &InvalidUnmarshalError{Type: reflect.TypeOf(target)}
+	}
+	return unmarshal(obj, v.Elem())
+}
+
+func unmarshal(obj object.Object, v reflect.Value) error {
+	t := v.Type()
+	_, isNull := obj.(*object.Null)
+	if t == objectType {
+		v.Set(reflect.ValueOf(&obj).Elem())
+		return nil
+	}
+	//*object.Hash等对象类型和对象实现的接口直接赋值，interface{}则转换为普通Go值
+	if reflect.TypeOf(obj).AssignableTo(t) && !(t.Kind() == reflect.Interface && t.NumMethod() == 0) {
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	}
+	if t == bigIntType {
+		return unmarshalBigInt(obj, v)
+	}
+	mismatch := &UnmarshalTypeError{Value: obj.Type(), Type: t}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := integer(obj, mismatch)
+		if err != nil {
+			return err
+		}
+		if !n.IsInt64() || v.OverflowInt(n.Int64()) {
+			return fmt.Errorf("%s overflows %s", n, t)
+		}
+		v.SetInt(n.Int64())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := integer(obj, mismatch)
+		if err != nil {
+			return err
+		}
+		if !n.IsUint64() || v.OverflowUint(n.Uint64()) {
+			return fmt.Errorf("%s overflows %s", n, t)
+		}
+		v.SetUint(n.Uint64())
+	case reflect.Float32, reflect.Float64:
+		switch n := obj.(type) {
+		case *object.Float:
+			v.SetFloat(n.Value)
+		case *object.Integer:
+			v.SetFloat(float64(n.Value))
+		case *object.BigInt:
+			f, _ := new(big.Float).SetInt(n.Value).Float64()
+			v.SetFloat(f)
+		default:
+			return mismatch
+		}
+	case reflect.String:
+		s, ok := obj.(*object.String)
+		if !ok {
+			return mismatch
+		}
+		v.SetString(s.Value)
+	case reflect.Bool:
+		b, ok := obj.(*object.Boolean)
+		if !ok {
+			return mismatch
+		}
+		v.SetBool(b.Value)
+	case reflect.Ptr:
+		if isNull {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		return unmarshal(obj, v.Elem())
+	case reflect.Interface:
+		if isNull {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		//非空接口中已有指针时转换到该指针指向的值
+		if t.NumMethod() > 0 {
+			if !v.IsNil() && v.Elem().Kind() == reflect.Ptr && !v.Elem().IsNil() {
+				return unmarshal(obj, v.Elem().Elem())
+			}
+			return mismatch
+		}
+		value, err := toInterface(obj)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			v.Set(reflect.Zero(t))
+		} else {
+			v.Set(reflect.ValueOf(value))
+		}
+	case reflect.Slice:
+		if isNull {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		array, ok := obj.(*object.Array)
+		if !ok {
+			return mismatch
+		}
+		slice := reflect.MakeSlice(t, len(array.Elements), len(array.Elements))
+		for i, element := range array.Elements {
+			if err := unmarshal(element, slice.Index(i)); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+		v.Set(slice)
+	case reflect.Array:
+		array, ok := obj.(*object.Array)
+		if !ok {
+			return mismatch
+		}
+		if len(array.Elements) != v.Len() {
+			return fmt.Errorf("cannot convert ARRAY of length %d to %s", len(array.Elements), t)
+		}
+		for i, element := range array.Elements {
+			if err := unmarshal(element, v.Index(i)); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+	case reflect.Map:
+		if isNull {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return mismatch
+		}
+		m := reflect.MakeMapWithSize(t, len(hash.Pairs))
+		for _, pair := range hash.SortedPairs() {
+			key := reflect.New(t.Key()).Elem()
+			if err := unmarshal(pair.Key, key); err != nil {
+				return fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+			}
+			value := reflect.New(t.Elem()).Elem()
+			if err := unmarshal(pair.Value, value); err != nil {
+				return fmt.Errorf("value of %s: %w", pair.Key.Inspect(), err)
+			}
+			m.SetMapIndex(key, value)
+		}
+		v.Set(m)
+	case reflect.Struct:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return mismatch
+		}
+		return unmarshalStruct(hash, v)
+	default:
+		return mismatch
+	}
+	return nil
+}
+
+//unmarshalStruct 按键名设置字段，先精确匹配再忽略大小写匹配，没有对应字段的键被忽略
+func unmarshalStruct(hash *object.Hash, v reflect.Value) error {
+	fields := structFields(v.Type())
+	for _, pair := range hash.SortedPairs() {
+		key, ok := pair.Key.(*object.String)
+		if !ok {
+			continue
+		}
+		var found *field
+		for i := range fields {
+			if fields[i].name == key.Value {
+				found = &fields[i]
+				break
+			}
+		}
+		if found == nil {
+			for i := range fields {
+				if strings.EqualFold(fields[i].name, key.Value) {
+					found = &fields[i]
+					break
+				}
+			}
+		}
+		if found == nil {
+			continue
+		}
+		if err := unmarshal(pair.Value, v.FieldByIndex(found.index)); err != nil {
+			return fmt.Errorf("field %s: %w", found.name, err)
+		}
+	}
+	return nil
+}
+
+//integer 取出Integer或BigInt的值
+func integer(obj object.Object, mismatch error) (*big.Int, error) {
+	switch n := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(n.Value), nil
+	case *object.BigInt:
+		return n.Value, nil
+	}
+	return nil, mismatch
+}
+
+func unmarshalBigInt(obj object.Object, v reflect.Value) error {
+	n, err := integer(obj, &UnmarshalTypeError{Value: obj.Type(), Type: v.Type()})
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(*new(big.Int).Set(n)))
+	return nil
+}
+
+//toInterface 转换为interface{}时使用的Go值
+func toInterface(obj object.Object) (interface{}, error) {
+	switch obj := obj.(type) {
+	case *object.Null:
+		return nil, nil
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.BigInt:
+		return new(big.Int).Set(obj.Value), nil
+	case *object.Float:
+		return obj.Value, nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.Array:
+		elements := make([]interface{}, len(obj.Elements))
+		for i, element := range obj.Elements {
+			value, err := toInterface(element)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+			elements[i] = value
+		}
+		return elements, nil
+	case *object.Hash:
+		m := make(map[string]interface{}, len(obj.Pairs))
+		for _, pair := range obj.SortedPairs() {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return nil, fmt.Errorf("cannot convert hash with %s keys to map[string]interface {}", pair.Key.Type())
+			}
+			value, err := toInterface(pair.Value)
+			if err != nil {
+				return nil, fmt.Errorf("value of %s: %w", pair.Key.Inspect(), err)
+			}
+			m[key.Value] = value
+		}
+		return m, nil
+	}
+	return obj, nil
+}
@@ -0,0 +1,89 @@
+package object
+
+import (
+	"fmt"
+	"monkey/code"
+)
+
+const (
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CELL_OBJ              = "CELL"
+)
+
+//CompiledFunction 编译后的函数
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	Positions     code.Positions //指令对应的源码位置
+	NumLocals     int            //局部变量个数，包含参数
+	NumParameters int            //普通参数个数，不包含剩余参数
+	Rest          bool           //最后一个局部变量是否是剩余参数
+	//按缺少的默认参数个数选择执行的入口，Entries[k]跳过前k个默认值的求值
+	//没有默认参数时为空，从0开始执行
+	Entries    []int
+	Name       string   //let绑定的名称
+	LocalNames []string //局部变量名，按下标排列
+	FreeNames  []string //捕获的变量名，按下标排列
+}
+
+func (cf *CompiledFunction) Type() ObjectType {
+	return COMPILED_FUNCTION_OBJ
+}
+
+func (cf *CompiledFunction) Inspect() string {
+	name := cf.Name
+	if name == "" {
+		name = "<anonymous>"
+	}
+	return fmt.Sprintf("CompiledFunction[%s]", name)
+}
+
+//Required 调用时至少需要的实参个数
+func (cf *CompiledFunction) Required() int {
+	if len(cf.Entries) == 0 {
+		return cf.NumParameters
+	}
+	return cf.NumParameters - (len(cf.Entries) - 1)
+}
+
+//Closure 运行时的函数，由编译后的函数和捕获的变量组成
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []*Cell
+}
+
+//Type 与求值器中的函数一致，对Monkey程序来说两者没有区别
+func (c *Closure) Type() ObjectType {
+	return FUNCTION_OBJ
+}
+
+func (c *Closure) Inspect() string {
+	name := c.Fn.Name
+	if name == "" {
+		name = "<anonymous>"
+	}
+	return fmt.Sprintf("Closure[%s]", name)
+}
+
+//Cell 闭包捕获的变量
+//所在的函数返回前Ref指向栈上的局部变量，闭包与函数共享同一个变量；返回后Ref指向Value
+type Cell struct {
+	Ref   *Object
+	Value Object
+}
+
+func (c *Cell) Type() ObjectType {
+	return CELL_OBJ
+}
+
+func (c *Cell) Inspect() string {
+	if c.Ref == nil || *c.Ref == nil {
+		return "cell"
+	}
+	return "cell(" + (*c.Ref).Inspect() + ")"
+}
+
+//Close 将变量的值从栈上复制到Cell中
+func (c *Cell) Close() {
+	c.Value = *c.Ref
+	c.Ref = &c.Value
+}
@@ -0,0 +1,92 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"monkey/token"
+	"strings"
+)
+
+//maxArgWidth 调用栈中单个参数展示的最大长度
+const maxArgWidth = 24
+
+//Frame 调用栈中的一帧，记录一次函数调用
+type Frame struct {
+	Function string         //函数名，取自let绑定，匿名函数为空
+	Pos      token.Position //调用位置
+	Args     []Object       //实参
+	Parent   *Frame         //调用者所在的帧，最外层为nil
+	Depth    int            //调用的嵌套层数，最外层的调用为1
+}
+
+//String 格式为 name(arg1, arg2)
+func (f *Frame) String() string {
+	name := f.Function
+	if name == "" {
+		name = "<anonymous>"
+	}
+	args := []string{}
+	for _, arg := range f.Args {
+		args = append(args, shortInspect(arg))
+	}
+	return name + "(" + strings.Join(args, ", ") + ")"
+}
+
+//Stack 从当前帧开始向外展开整个调用栈，最内层的调用在前
+func (f *Frame) Stack() []*Frame {
+	var stack []*Frame
+	for frame := f; frame != nil; frame = frame.Parent {
+		stack = append(stack, frame)
+	}
+	return stack
+}
+
+//shortInspect 参数的简短展示，函数只显示fn，过长的值截断
+func shortInspect(obj Object) string {
+	if obj == nil {
+		return "null"
+	}
+	var s string
+	switch obj := obj.(type) {
+	case *Function:
+		s = "fn"
+		if obj.Name != "" {
+			s = "fn " + obj.Name
+		}
+	case *String:
+		s = fmt.Sprintf("%q", obj.Value)
+	default:
+		s = obj.Inspect()
+	}
+	s = strings.ReplaceAll(s, "\n", " ")
+	if len(s) > maxArgWidth {
+		s = s[:maxArgWidth-3] + "..."
+	}
+	return s
+}
+
+//StackTrace 以回溯的形式展示错误发生的位置和调用栈，最近的调用在前
+//
+//	ERROR: type mismatch: INTEGER + BOOLEAN
+//	  at 1:21
+//	traceback (most recent call first):
+//	  inner(1)
+//	      called at 2:21
+//	  outer(1)
+//	      called at 3:1
+func (e *Error) StackTrace() string {
+	var out bytes.Buffer
+	out.WriteString(e.Inspect())
+	out.WriteString("\n")
+	if e.Pos.IsValid() {
+		out.WriteString("  at " + e.Pos.String() + "\n")
+	}
+	if len(e.Stack) > 0 {
+		out.WriteString("traceback (most recent call first):\n")
+		for _, frame := range e.Stack {
+			out.WriteString("  " + frame.String() + "\n")
+			out.WriteString("      called at " + frame.Pos.String() + "\n")
+		}
+	}
+	return out.String()
+}
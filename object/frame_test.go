@@ -0,0 +1,35 @@
+package object
+
+import (
+	"monkey/token"
+	"testing"
+)
+
+func TestErrorStackTrace(t *testing.T) {
+	outer := &Frame{
+		Function: "outer",
+		Pos:      token.Position{Filename: "a.mk", Line: 9, Column: 1},
+		Args:     []Object{&String{Value: "a very long string argument"}},
+	}
+	inner := &Frame{
+		Pos:    token.Position{Filename: "a.mk", Line: 3, Column: 5},
+		Args:   []Object{&Integer{Value: 1}, &Function{Name: "cb"}},
+		Parent: outer,
+	}
+	err := &Error{
+		Message: "boom",
+		Pos:     token.Position{Filename: "a.mk", Line: 1, Column: 7},
+		Stack:   inner.Stack(),
+	}
+	expected := `ERROR: boom
+  at a.mk:1:7
+traceback (most recent call first):
+  <anonymous>(1, fn cb)
+      called at a.mk:3:5
+  outer("a very long string a...)
+      called at a.mk:9:1
+`
+	if got := err.StackTrace(); got != expected {
+		t.Errorf("wrong stack trace.\nwant=%q\ngot=%q", expected, got)
+	}
+}
@@ -4,7 +4,14 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"io"
+	"math"
+	"math/big"
 	"monkey/ast"
+	"monkey/token"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -12,6 +19,8 @@ type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
+	BIGINT_OBJ       = "BIGINT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
@@ -21,6 +30,26 @@ const (
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
+	ERROR_VALUE_OBJ  = "ERROR_VALUE"
+	QUOTE_OBJ        = "QUOTE"
+	MACRO_OBJ        = "MACRO"
+)
+
+//错误的类型
+const (
+	GENERIC_ERROR  = "Error"         //throw抛出的错误
+	TYPE_ERROR     = "TypeError"     //操作数或参数类型不正确
+	NAME_ERROR     = "NameError"     //标识符未定义
+	ARGUMENT_ERROR = "ArgumentError" //参数个数不正确
+
+	ARITHMETIC_ERROR = "ArithmeticError" //除数为零等算术错误
+	INTERNAL_ERROR   = "InternalError"   //解释器内部的错误
+
+	CANCELLED_ERROR  = "CancelledError" //求值被取消或超时
+	STEP_LIMIT_ERROR = "StepLimitError" //求值的步数超出限制
+	CALL_DEPTH_ERROR = "CallDepthError" //函数调用的嵌套层数超出限制
 )
 
 type Object interface {
@@ -44,6 +73,52 @@ func (i *Integer) Inspect() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
+//BigInt 任意精度整数，整数运算溢出时自动提升为BigInt，结果能用int64表示时再降回Integer
+type BigInt struct {
+	Value *big.Int
+}
+
+func (b *BigInt) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte{byte(b.Value.Sign() + 1)})
+	h.Write(b.Value.Bytes())
+	return HashKey{Type: b.Type(), Value: h.Sum64()}
+}
+
+func (b *BigInt) Type() ObjectType {
+	return BIGINT_OBJ
+}
+
+func (b *BigInt) Inspect() string {
+	return b.Value.String()
+}
+
+//Float 64位浮点数
+type Float struct {
+	Value float64
+}
+
+//HashKey 整数值的浮点数与对应的整数使用相同的键，保证 1 == 1.0 时 {1: x}[1.0] 也能取到值
+func (f *Float) HashKey() HashKey {
+	if f.Value == math.Trunc(f.Value) && f.Value >= math.MinInt64 && f.Value < math.MaxInt64 {
+		return HashKey{Type: INTEGER_OBJ, Value: uint64(int64(f.Value))}
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
+//Inspect 总是带有小数点或指数，以便和整数区分
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -79,8 +154,38 @@ func (r *ReturnValue) Inspect() string {
 	return r.Value.Inspect()
 }
 
+//Break break语句产生的信号，沿语句块向上传递直到匹配的循环
+type Break struct {
+	Label string //为空时匹配最内层循环
+}
+
+func (b *Break) Type() ObjectType {
+	return BREAK_OBJ
+}
+
+func (b *Break) Inspect() string {
+	return "break " + b.Label
+}
+
+//Continue continue语句产生的信号
+type Continue struct {
+	Label string
+}
+
+func (c *Continue) Type() ObjectType {
+	return CONTINUE_OBJ
+}
+
+func (c *Continue) Inspect() string {
+	return "continue " + c.Label
+}
+
 type Error struct {
+	Kind    string //错误类型，如TypeError
 	Message string
+	Value   Object         //throw抛出的原始值
+	Pos     token.Position //出错的位置
+	Stack   []*Frame       //出错时的调用栈，最内层的调用在前
 }
 
 func (e *Error) Type() ObjectType {
@@ -91,9 +196,48 @@ func (e Error) Inspect() string {
 	return "ERROR: " + e.Message
 }
 
+//Fatal 取消求值和超出求值限制的错误不能被try捕获
+func (e *Error) Fatal() bool {
+	switch e.Kind {
+	case CANCELLED_ERROR, STEP_LIMIT_ERROR, CALL_DEPTH_ERROR:
+		return true
+	}
+	return false
+}
+
+//ErrorValue 被catch捕获后的错误，可以像普通的值一样传递
+//通过 e["type"]、e["message"]、e["value"] 访问错误的类型、信息和throw抛出的值
+type ErrorValue struct {
+	Err *Error
+}
+
+func (e *ErrorValue) Type() ObjectType {
+	return ERROR_VALUE_OBJ
+}
+
+func (e *ErrorValue) Inspect() string {
+	return e.Err.Kind + ": " + e.Err.Message
+}
+
 type Environment struct {
 	store map[string]Object
-	outer *Environment //父环境
+	outer *Environment        //父环境
+	frame *Frame              //创建该环境的函数调用，非函数调用创建的环境为nil
+	hook  Hook                //求值钩子，内层环境使用外层环境的钩子
+	out   *Output             //puts等内置函数的输出，内层环境使用外层环境的输出
+	host  map[string]*Builtin //宿主程序注册的内置函数，内层环境使用外层环境的
+	block bool                //语句块的环境，只有创建时绑定的变量属于它，其余的Set写入外层环境
+}
+
+//Output 程序的标准输出和标准错误
+type Output struct {
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+//Hook 求值器在求值每个语句和表达式之前调用Before，返回错误时停止求值该节点并返回该错误
+type Hook interface {
+	Before(node ast.Node, env *Environment) *Error
 }
 
 func NewEnvironment() *Environment {
@@ -110,12 +254,97 @@ func (e *Environment) Get(name string) (Object, bool) {
 }
 
 func (e *Environment) Set(name string, val Object) Object {
+	if _, ok := e.store[name]; !ok && e.block {
+		return e.outer.Set(name, val)
+	}
 	e.store[name] = val
 	return val
 }
 
+//Outer 父环境，最外层环境返回nil
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+//Names 当前环境（不含父环境）中绑定的名字，按名称排序
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//SetHook 设置求值钩子，对该环境及其内层环境中的求值生效，h为nil时取消该环境上的钩子
+func (e *Environment) SetHook(h Hook) {
+	e.hook = h
+}
+
+//Hook 返回当前生效的求值钩子，没有时返回nil
+func (e *Environment) Hook() Hook {
+	for env := e; env != nil; env = env.outer {
+		if env.hook != nil {
+			return env.hook
+		}
+	}
+	return nil
+}
+
+//SetOutput 设置该环境及其内层环境中程序的输出，为nil的一项使用os.Stdout或os.Stderr
+func (e *Environment) SetOutput(stdout, stderr io.Writer) {
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	e.out = &Output{Stdout: stdout, Stderr: stderr}
+}
+
+//Output 返回当前生效的输出，没有设置时为os.Stdout和os.Stderr
+func (e *Environment) Output() *Output {
+	for env := e; env != nil; env = env.outer {
+		if env.out != nil {
+			return env.out
+		}
+	}
+	return &Output{Stdout: os.Stdout, Stderr: os.Stderr}
+}
+
+//SetBuiltin 为该环境及其内层环境注册内置函数，查找时在环境中的变量之后、标准的内置函数之前
+func (e *Environment) SetBuiltin(name string, builtin *Builtin) {
+	if e.host == nil {
+		e.host = map[string]*Builtin{}
+	}
+	e.host[name] = builtin
+}
+
+//Builtin 查找宿主程序注册的内置函数
+func (e *Environment) Builtin(name string) (*Builtin, bool) {
+	for env := e; env != nil; env = env.outer {
+		if builtin, ok := env.host[name]; ok {
+			return builtin, true
+		}
+	}
+	return nil, false
+}
+
+//Frame 返回当前所在的函数调用帧，顶层环境中为nil
+func (e *Environment) Frame() *Frame {
+	for env := e; env != nil; env = env.outer {
+		if env.frame != nil {
+			return env.frame
+		}
+	}
+	return nil
+}
+
 type Function struct {
+	Name       string //let绑定的名称，用于调用栈展示
 	Parameters []*ast.Identifier
+	Defaults   map[string]ast.Expression //参数默认值，调用时求值
+	Rest       *ast.Identifier           //剩余参数，收集多余的实参
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -126,10 +355,7 @@ func (f *Function) Type() ObjectType {
 
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
+	params := ast.ParameterStrings(f.Parameters, f.Defaults, f.Rest)
 	out.WriteString("fn")
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ","))
@@ -145,6 +371,21 @@ func NewEncloseEnvironment(outer *Environment) *Environment {
 	return env
 }
 
+//NewBlockEnvironment 为语句块创建环境，如catch语句块，name只在语句块中绑定为val，语句块中的其他绑定写入outer
+func NewBlockEnvironment(outer *Environment, name string, val Object) *Environment {
+	env := NewEncloseEnvironment(outer)
+	env.store[name] = val
+	env.block = true
+	return env
+}
+
+//NewCallEnvironment 为一次函数调用创建环境
+func NewCallEnvironment(outer *Environment, frame *Frame) *Environment {
+	env := NewEncloseEnvironment(outer)
+	env.frame = frame
+	return env
+}
+
 type String struct {
 	Value string
 }
@@ -159,8 +400,12 @@ func (s *String) Inspect() string {
 
 type BuiltinFunction func(args ...Object) Object
 
+//EnvBuiltinFunction 需要访问调用者环境的内置函数，如puts需要取得程序的输出
+type EnvBuiltinFunction func(env *Environment, args ...Object) Object
+
 type Builtin struct {
-	Fn BuiltinFunction
+	Fn    BuiltinFunction
+	EnvFn EnvBuiltinFunction //不为空时求值器调用EnvFn，虚拟机总是调用Fn
 }
 
 func (b *Builtin) Type() ObjectType {
@@ -171,6 +416,42 @@ func (b *Builtin) Inspect() string {
 	return "builtin function"
 }
 
+//Quote quote(...)的结果，包装未求值的语法树
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType {
+	return QUOTE_OBJ
+}
+
+func (q *Quote) Inspect() string {
+	return "QUOTE(" + q.Node.String() + ")"
+}
+
+//Macro 宏，只在展开阶段使用
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType {
+	return MACRO_OBJ
+}
+
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+	params := ast.ParameterStrings(m.Parameters, nil, nil)
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ","))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+	return out.String()
+}
+
 type Array struct {
 	Elements []Object
 }
@@ -209,6 +490,64 @@ func (h *Hash) Inspect() string {
 	return out.String()
 }
 
+//SortedPairs 返回按键排序的键值对，用于需要稳定顺序的遍历
+//整数、大整数和浮点数的键按数值排在一起，其他类型的键按类型名分组，同类型的按字面值排序
+func (h *Hash) SortedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		a, b := pairs[i].Key, pairs[j].Key
+		if ga, gb := sortGroup(a), sortGroup(b); ga != gb {
+			return ga < gb
+		}
+		if fa, ok := numeric(a); ok {
+			return compareNumeric(fa, b) < 0
+		}
+		return a.Inspect() < b.Inspect()
+	})
+	return pairs
+}
+
+//sortGroup 键排序时所在的分组，数值类型同属一组
+func sortGroup(obj Object) ObjectType {
+	if _, ok := numeric(obj); ok {
+		return INTEGER_OBJ
+	}
+	return obj.Type()
+}
+
+//numeric 数值转换为big.Float，整数和大整数的转换没有精度损失，NaN返回nil
+func numeric(obj Object) (*big.Float, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return new(big.Float).SetInt64(obj.Value), true
+	case *BigInt:
+		return new(big.Float).SetInt(obj.Value), true
+	case *Float:
+		if math.IsNaN(obj.Value) {
+			return nil, true
+		}
+		return new(big.Float).SetFloat64(obj.Value), true
+	}
+	return nil, false
+}
+
+//compareNumeric 比较a与数值b，NaN小于其他所有数值
+func compareNumeric(a *big.Float, b Object) int {
+	fb, _ := numeric(b)
+	switch {
+	case a == nil && fb == nil:
+		return 0
+	case a == nil:
+		return -1
+	case fb == nil:
+		return 1
+	}
+	return a.Cmp(fb)
+}
+
 type HashKey struct {
 	Type  ObjectType
 	Value uint64
@@ -1,6 +1,10 @@
 package object
 
-import "testing"
+import (
+	"math"
+	"math/big"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -20,3 +24,92 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestHashSortedPairs(t *testing.T) {
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	keys := []Object{
+		&String{Value: "b"},
+		&Integer{Value: 10},
+		&String{Value: "a"},
+		&Integer{Value: 2},
+		&Boolean{Value: true},
+	}
+	for _, key := range keys {
+		hash.Pairs[key.(Hashable).HashKey()] = HashPair{Key: key, Value: key}
+	}
+	testSortedKeys(t, hash, []string{"true", "2", "10", "a", "b"})
+
+	//整数、大整数和浮点数按数值排在一起
+	n, _ := new(big.Int).SetString("99999999999999999999", 10)
+	hash = &Hash{Pairs: map[HashKey]HashPair{}}
+	keys = []Object{
+		&Float{Value: 10.5},
+		&Float{Value: 9.5},
+		&Integer{Value: 2},
+		&BigInt{Value: n},
+		&Integer{Value: -1},
+		&BigInt{Value: new(big.Int).Neg(n)},
+		&Float{Value: math.Inf(1)},
+		&Float{Value: math.NaN()},
+		&String{Value: "1"},
+	}
+	for _, key := range keys {
+		hash.Pairs[key.(Hashable).HashKey()] = HashPair{Key: key, Value: key}
+	}
+	testSortedKeys(t, hash, []string{"NaN", "-99999999999999999999", "-1", "2", "9.5", "10.5", "99999999999999999999", "+Inf", "1"})
+}
+
+func testSortedKeys(t *testing.T, hash *Hash, expected []string) {
+	t.Helper()
+	pairs := hash.SortedPairs()
+	if len(pairs) != len(expected) {
+		t.Fatalf("wrong number of pairs. got=%d", len(pairs))
+	}
+	for i, pair := range pairs {
+		if pair.Key.Inspect() != expected[i] {
+			t.Errorf("pairs[%d] wrong. want=%q, got=%q", i, expected[i], pair.Key.Inspect())
+		}
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{1.5, "1.5"},
+		{2, "2.0"},
+		{-0.25, "-0.25"},
+		{1e21, "1e+21"},
+		{1.0 / 3, "0.3333333333333333"},
+	}
+	for _, tt := range tests {
+		f := &Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("wrong inspect for %g. want=%q, got=%q", tt.value, tt.expected, f.Inspect())
+		}
+	}
+}
+
+func TestFloatHashKey(t *testing.T) {
+	if (&Float{Value: 2}).HashKey() != (&Integer{Value: 2}).HashKey() {
+		t.Errorf("integral float has different hash key from integer")
+	}
+	if (&Float{Value: 2.5}).HashKey() != (&Float{Value: 2.5}).HashKey() {
+		t.Errorf("floats with same value have different hash keys")
+	}
+	if (&Float{Value: 2.5}).HashKey() == (&Float{Value: 3.5}).HashKey() {
+		t.Errorf("floats with different values have same hash keys")
+	}
+}
+
+func TestBigIntHashKey(t *testing.T) {
+	a, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	b, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	if (&BigInt{Value: a}).HashKey() != (&BigInt{Value: b}).HashKey() {
+		t.Errorf("big integers with same value have different hash keys")
+	}
+	if (&BigInt{Value: a}).HashKey() == (&BigInt{Value: new(big.Int).Neg(a)}).HashKey() {
+		t.Errorf("big integers with different signs have same hash keys")
+	}
+}
@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"monkey/token"
+	"strings"
+)
+
+//Severity 诊断信息的严重程度
+type Severity int
+
+const (
+	SeverityError Severity = iota + 1
+	SeverityWarning
+	SeverityInfo
+	SeverityHint
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityInfo:
+		return "info"
+	case SeverityHint:
+		return "hint"
+	default:
+		return "unknown"
+	}
+}
+
+//错误码
+const (
+	CodeUnexpectedToken  = "E001" //不符合预期的token
+	CodeNoPrefixParseFn  = "E002" //token不能作为表达式的开始
+	CodeInvalidInteger   = "E003" //无法解析的整数字面量
+	CodeInvalidAssign    = "E004" //赋值的目标不是标识符
+	CodeLoopControl      = "E005" //break/continue出现在循环之外
+	CodeUndefinedLabel   = "E006" //未定义的循环标签
+	CodeIllegalCharacter = "E007" //无法识别的字符
+	CodeInvalidTry       = "E008" //try缺少catch和finally
+	CodeInvalidFloat     = "E009" //无法解析的浮点数字面量
+	CodeInvalidParameter = "E010" //不合法的函数参数列表
+)
+
+//Diagnostic 解析过程中产生的诊断信息
+type Diagnostic struct {
+	Severity Severity
+	Pos      token.Position //起始位置
+	End      token.Position //结束位置（不包含）
+	Code     string         //错误码
+	Message  string
+	Hints    []string //修复建议
+}
+
+//Error 格式为 <位置>: <信息>
+func (d *Diagnostic) Error() string {
+	return d.Pos.String() + ": " + d.Message
+}
+
+//withHint 附加一条修复建议，d为空时忽略
+func (d *Diagnostic) withHint(format string, a ...interface{}) *Diagnostic {
+	if d != nil {
+		d.Hints = append(d.Hints, fmt.Sprintf(format, a...))
+	}
+	return d
+}
+
+//Render 渲染诊断信息，附带出错的源码行并在出错位置下方标出^
+//
+//	main.mk:2:5: error[E001]: expected next token to be IDENT, got = instead
+//	   2 | let = 2;
+//	     |     ^
+//	     = hint: ...
+func (d *Diagnostic) Render(source string) string {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "%s: %s[%s]: %s\n", d.Pos, d.Severity, d.Code, d.Message)
+	line, ok := sourceLine(source, d.Pos.Line)
+	if ok && d.Pos.Column > 0 {
+		gutter := fmt.Sprintf("%4d", d.Pos.Line)
+		blank := strings.Repeat(" ", len(gutter))
+		fmt.Fprintf(&out, "%s | %s\n", gutter, line)
+		fmt.Fprintf(&out, "%s | %s\n", blank, caret(line, d.Pos, d.End))
+		for _, hint := range d.Hints {
+			fmt.Fprintf(&out, "%s = hint: %s\n", blank, hint)
+		}
+		return out.String()
+	}
+	for _, hint := range d.Hints {
+		fmt.Fprintf(&out, "  = hint: %s\n", hint)
+	}
+	return out.String()
+}
+
+//sourceLine 取出源码中的第n行（从1开始）
+func sourceLine(source string, n int) (string, bool) {
+	if n <= 0 {
+		return "", false
+	}
+	lines := strings.Split(source, "\n")
+	if n > len(lines) {
+		return "", false
+	}
+	return strings.TrimRight(lines[n-1], "\r"), true
+}
+
+//caret 生成位于出错位置下方的标记行，制表符原样保留以便与源码对齐
+func caret(line string, pos token.Position, end token.Position) string {
+	var out bytes.Buffer
+	start := pos.Column - 1
+	for i := 0; i < start && i < len(line); i++ {
+		if line[i] == '\t' {
+			out.WriteByte('\t')
+		} else {
+			out.WriteByte(' ')
+		}
+	}
+	if start > len(line) {
+		out.WriteString(strings.Repeat(" ", start-len(line)))
+	}
+	width := 1
+	if end.Line == pos.Line && end.Column > pos.Column {
+		width = end.Column - pos.Column
+	}
+	out.WriteString(strings.Repeat("^", width))
+	return out.String()
+}
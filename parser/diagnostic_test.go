@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"monkey/lexer"
+	"testing"
+)
+
+func TestParserRecovery(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+		statements     int
+	}{
+		{"let x 5; let y = 2;", []string{"1:7: expected next token to be =, got INT instead"}, 1},
+		{"add(1, 2; let y = 3; y", []string{"1:9: expected next token to be ), got ; instead"}, 2},
+		{"let = 1;\nlet = 2;\nlet z = 3;", []string{
+			"1:5: expected next token to be IDENT, got = instead",
+			"2:5: expected next token to be IDENT, got = instead",
+		}, 1},
+		{"let f = fn() { let = 1; x };\nf()", []string{"1:20: expected next token to be IDENT, got = instead"}, 2},
+		{"let f = fn() { if (x { y } z };\nf()", []string{"1:22: expected next token to be ), got { instead"}, 2},
+		{"} let a = 1;", []string{"1:1: no prefix parse function for } found"}, 1},
+		{"let f = fn() { return 1 }; f()", []string{}, 2},
+		{"let a = 1 @ 2;", []string{"1:11: illegal character \"@\""}, 1},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("wrong number of errors for %q. want=%d, got=%d (%q)", tt.input, len(tt.expectedErrors), len(errors), errors)
+			continue
+		}
+		for i, msg := range tt.expectedErrors {
+			if errors[i] != msg {
+				t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, msg, errors[i])
+			}
+		}
+		if len(program.Statements) != tt.statements {
+			t.Errorf("wrong number of statements for %q. want=%d, got=%d", tt.input, tt.statements, len(program.Statements))
+		}
+	}
+}
+
+func TestDiagnosticFields(t *testing.T) {
+	l := lexer.New("for (;;) { }\nbreak outer;")
+	p := New(l)
+	p.ParseProgram()
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) != 1 {
+		t.Fatalf("wrong number of diagnostics. got=%d", len(diagnostics))
+	}
+	d := diagnostics[0]
+	if d.Severity != SeverityError {
+		t.Errorf("wrong severity. got=%s", d.Severity)
+	}
+	if d.Code != CodeLoopControl {
+		t.Errorf("wrong code. want=%s, got=%s", CodeLoopControl, d.Code)
+	}
+	if d.Pos.String() != "2:1" || d.End.String() != "2:6" {
+		t.Errorf("wrong range. got=%s-%s", d.Pos, d.End)
+	}
+	if len(d.Hints) != 1 {
+		t.Errorf("wrong number of hints. got=%d", len(d.Hints))
+	}
+}
+
+func TestDiagnosticRender(t *testing.T) {
+	input := "let x = 1;\n\tlet = add(x);"
+	l := lexer.NewWithFilename("main.mk", input)
+	p := New(l)
+	p.ParseProgram()
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) != 1 {
+		t.Fatalf("wrong number of diagnostics. got=%d", len(diagnostics))
+	}
+	expected := "main.mk:2:6: error[E001]: expected next token to be IDENT, got = instead\n" +
+		"   2 | \tlet = add(x);\n" +
+		"     | \t    ^\n"
+	if got := diagnostics[0].Render(input); got != expected {
+		t.Errorf("wrong render.\nwant=%q\ngot=%q", expected, got)
+	}
+
+	l = lexer.New("let x = 1 + ;")
+	p = New(l)
+	p.ParseProgram()
+	expected = "1:13: error[E002]: no prefix parse function for ; found\n" +
+		"   1 | let x = 1 + ;\n" +
+		"     |             ^\n"
+	if got := p.Diagnostics()[0].Render("let x = 1 + ;"); got != expected {
+		t.Errorf("wrong render.\nwant=%q\ngot=%q", expected, got)
+	}
+}
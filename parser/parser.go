@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"math/big"
 	"monkey/ast"
 	"monkey/lexer"
 	"monkey/token"
@@ -32,8 +33,9 @@ var precedences = map[token.TokenType]int{
 	token.PLUS:  SUM, // + -
 	token.MINUS: SUM,
 
-	token.SLASH:    PRODUCT, //* /
+	token.SLASH:    PRODUCT, //* / %
 	token.ASTERISK: PRODUCT,
+	token.PERCENT:  PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
 
@@ -41,14 +43,21 @@ var precedences = map[token.TokenType]int{
 }
 
 type Parser struct {
-	l      *lexer.Lexer //词法分析器
-	errors []string     //解析中出现的错误
+	l           *lexer.Lexer  //词法分析器
+	diagnostics []*Diagnostic //解析中出现的错误
+
+	panicking  bool //出错后进入恐慌模式，直到同步到下一条语句前不再记录新的错误
+	blockDepth int  //当前语句块的嵌套深度
 
 	curToken  token.Token //当前的token
 	peekToken token.Token //下一个即将读取的token
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	inParseFns     map[token.TokenType]inParseFn
+
+	loops []string //当前所在的循环标签栈，未加标签的循环记为空字符串
+
+	comments []token.Token //读取到的注释，按出现顺序排列
 }
 
 type (
@@ -59,14 +68,15 @@ type (
 //New 创建一个解析器
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
-		l:      l,
-		errors: []string{},
+		l:           l,
+		diagnostics: []*Diagnostic{},
 	}
 	//初始化
 	// 注册前缀解析函数
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)   //处理标识符
 	p.registerPrefix(token.INT, p.parseIntegerLiteral) //处理整数
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
@@ -77,14 +87,17 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 
-	// 注册中缀解析函数 + - * / == != > <
+	// 注册中缀解析函数 + - * / % == != > <
 	p.inParseFns = make(map[token.TokenType]inParseFn)
 	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
@@ -96,8 +109,23 @@ func New(l *lexer.Lexer) *Parser {
 	p.nextToken()
 	return p
 }
+//Errors 以 <位置>: <信息> 的形式返回所有错误
 func (p *Parser) Errors() []string {
-	return p.errors
+	errors := make([]string, 0, len(p.diagnostics))
+	for _, d := range p.diagnostics {
+		errors = append(errors, d.Error())
+	}
+	return errors
+}
+
+//Comments 返回源码中的注释，词法解析器由lexer.NewWithComments创建时才有注释
+func (p *Parser) Comments() []token.Token {
+	return p.comments
+}
+
+//Diagnostics 返回解析中产生的诊断信息
+func (p *Parser) Diagnostics() []*Diagnostic {
+	return p.diagnostics
 }
 
 //ParseProgram  解析程序
@@ -107,6 +135,11 @@ func (p *Parser) ParseProgram() *ast.Program {
 	//消费所有的token，直到结束EOF
 	for !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
+		//出错的语句直接丢弃，跳到下一条语句继续解析
+		if p.panicking {
+			p.synchronize()
+			continue
+		}
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
@@ -115,6 +148,35 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return program
 }
 
+//synchronize 从错误中恢复：丢弃token直到语句的边界
+//边界为分号之后、语句关键字、或者（在语句块内时）所在语句块的右括号
+func (p *Parser) synchronize() {
+	p.panicking = false
+	braces := 0
+	for moved := false; !p.curTokenIs(token.EOF); moved = true {
+		switch p.curToken.Type {
+		case token.SEMICOLON:
+			if braces == 0 {
+				p.nextToken()
+				return
+			}
+		case token.LBRACE:
+			braces++
+		case token.RBRACE:
+			if braces > 0 {
+				braces--
+			} else if p.blockDepth > 0 {
+				return
+			}
+		case token.LET, token.RETURN, token.FOR, token.BREAK, token.CONTINUE:
+			if moved && braces == 0 {
+				return
+			}
+		}
+		p.nextToken()
+	}
+}
+
 //parseStatement 解析语句
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
@@ -123,6 +185,18 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.FOR:
+		return p.parseForStatement(nil)
+	case token.BREAK, token.CONTINUE:
+		return p.parseLoopControlStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.IDENT:
+		//<标签>: for ...
+		if p.peekTokenIs(token.COLON) {
+			return p.parseLabeledStatement()
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -149,8 +223,11 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	//解析表达式并存储
 	p.nextToken()
 	stmt.Value = p.parseExpression(LOWEST)
-	//读取直到语句结束分号
-	if !p.curTokenIs(token.SEMICOLON) {
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
+	//语句以可选的分号结束
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 	return stmt
@@ -161,7 +238,150 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	p.nextToken()
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
-	for !p.curTokenIs(token.SEMICOLON) {
+	//读取直到语句结束分号，不越过所在语句块的结尾
+	for !p.curTokenIs(token.SEMICOLON) && !p.peekTokenIs(token.RBRACE) && !p.peekTokenIs(token.EOF) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+//parseForStatement 解析for循环
+//for (<初始化>; <条件>; <后置>) { } 或 for (<变量> in <表达式>) { }
+func (p *Parser) parseForStatement(label *ast.Identifier) ast.Statement {
+	tok := p.curToken
+	if !p.exceptPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	//标识符后紧跟in时为迭代形式
+	if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.IN) {
+		return p.parseForInStatement(tok, label)
+	}
+	stmt := &ast.ForStatement{Token: tok, Label: label}
+	//初始化语句，解析后当前token应停在分号上
+	if !p.curTokenIs(token.SEMICOLON) {
+		if p.curTokenIs(token.LET) {
+			stmt.Init = p.parseLetStatement()
+		} else {
+			stmt.Init = p.parseExpressionStatement()
+		}
+		if !p.curTokenIs(token.SEMICOLON) {
+			p.curError(token.SEMICOLON)
+			return nil
+		}
+	}
+	//循环条件
+	p.nextToken()
+	if !p.curTokenIs(token.SEMICOLON) {
+		stmt.Condition = p.parseExpression(LOWEST)
+		if !p.exceptPeek(token.SEMICOLON) {
+			return nil
+		}
+	}
+	//后置表达式
+	p.nextToken()
+	if !p.curTokenIs(token.RPAREN) {
+		stmt.Post = p.parseExpression(LOWEST)
+		if !p.exceptPeek(token.RPAREN) {
+			return nil
+		}
+	}
+	if !p.exceptPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody(label)
+	p.skipOptionalSemicolon()
+	return stmt
+}
+
+//parseForInStatement 解析迭代形式的for循环，当前token为循环变量
+func (p *Parser) parseForInStatement(tok token.Token, label *ast.Identifier) ast.Statement {
+	stmt := &ast.ForInStatement{Token: tok, Label: label}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	p.nextToken() //跳过in
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+	if !p.exceptPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.exceptPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody(label)
+	p.skipOptionalSemicolon()
+	return stmt
+}
+
+//parseLoopBody 解析循环体，解析期间记录所在循环的标签
+func (p *Parser) parseLoopBody(label *ast.Identifier) *ast.BlockStatement {
+	name := ""
+	if label != nil {
+		name = label.Value
+	}
+	p.loops = append(p.loops, name)
+	body := p.parseBlockStatement()
+	p.loops = p.loops[:len(p.loops)-1]
+	return body
+}
+
+//parseLabeledStatement 解析带标签的循环 <标签>: for ...
+func (p *Parser) parseLabeledStatement() ast.Statement {
+	label := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	p.nextToken() //跳过冒号
+	if !p.exceptPeek(token.FOR) {
+		return nil
+	}
+	return p.parseForStatement(label)
+}
+
+//parseLoopControlStatement 解析break或continue语句，只能出现在循环内
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+	tok := p.curToken
+	var label *ast.Identifier
+	if p.peekTokenIs(token.IDENT) {
+		p.nextToken()
+		label = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+	if len(p.loops) == 0 {
+		p.tokenErrorf(tok, CodeLoopControl, "%s outside of loop", tok.Literal).
+			withHint("`%s` can only be used inside a for loop", tok.Literal)
+		return nil
+	}
+	if label != nil && !p.inLoop(label.Value) {
+		p.tokenErrorf(label.Token, CodeUndefinedLabel, "undefined loop label: %s", label.Value).
+			withHint("label an enclosing loop with `%s: for (...)`", label.Value)
+		return nil
+	}
+	p.skipOptionalSemicolon()
+	if tok.Type == token.BREAK {
+		return &ast.BreakStatement{Token: tok, Label: label}
+	}
+	return &ast.ContinueStatement{Token: tok, Label: label}
+}
+
+//inLoop 当前是否处于指定标签的循环内
+func (p *Parser) inLoop(label string) bool {
+	for _, l := range p.loops {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+//skipOptionalSemicolon 跳过语句块后可选的分号
+func (p *Parser) skipOptionalSemicolon() {
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+}
+
+//parseThrowStatement 解析throw语句 throw <表达式>;
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 	return stmt
@@ -218,8 +438,26 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %s as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		//超出int64范围的字面量使用大整数表示
+		if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+			if big, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+				lit.Big = big
+				return lit
+			}
+		}
+		p.tokenErrorf(p.curToken, CodeInvalidInteger, "could not parse %s as integer", p.curToken.Literal)
+		return nil
+	}
+	lit.Value = value
+	return lit
+}
+
+//parseFloatLiteral 解析浮点数字面量
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.tokenErrorf(p.curToken, CodeInvalidFloat, "could not parse %s as float", p.curToken.Literal)
 		return nil
 	}
 	lit.Value = value
@@ -270,6 +508,14 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 
 //解析辅助方法
 
+//Precedence 中缀运算符的优先级，不是中缀运算符时为LOWEST
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LOWEST
+}
+
 //peekPrecedence 查看下一操作符的优先级
 func (p *Parser) peekPrecedence() int {
 	if p, ok := precedences[p.peekToken.Type]; ok {
@@ -296,10 +542,14 @@ func (p *Parser) peekTokenIs(t token.TokenType) bool {
 	return p.peekToken.Type == t
 }
 
-//nextToken 读取下一个token
+//nextToken 读取下一个token，注释不参与解析，单独记录下来
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+	for p.peekToken.Type == token.COMMENT {
+		p.comments = append(p.comments, p.peekToken)
+		p.peekToken = p.l.NextToken()
+	}
 }
 
 //断言下一个token类型，类型正确时会自动取出下一个token
@@ -314,8 +564,34 @@ func (p *Parser) exceptPeek(t token.TokenType) bool {
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("excepted nex token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.tokenErrorf(p.peekToken, CodeUnexpectedToken, "expected next token to be %s, got %s instead", t, p.peekToken.Type)
+}
+
+func (p *Parser) curError(t token.TokenType) {
+	p.tokenErrorf(p.curToken, CodeUnexpectedToken, "expected token to be %s, got %s instead", t, p.curToken.Type)
+}
+
+//errorf 记录一条范围为[pos, end)的解析错误
+//恐慌模式下不再记录，避免一个错误引发一连串的后续错误，此时返回nil
+func (p *Parser) errorf(pos token.Position, end token.Position, code string, format string, a ...interface{}) *Diagnostic {
+	if p.panicking {
+		return nil
+	}
+	p.panicking = true
+	d := &Diagnostic{
+		Severity: SeverityError,
+		Pos:      pos,
+		End:      end,
+		Code:     code,
+		Message:  fmt.Sprintf(format, a...),
+	}
+	p.diagnostics = append(p.diagnostics, d)
+	return d
+}
+
+//tokenErrorf 记录一条位于tok上的解析错误
+func (p *Parser) tokenErrorf(tok token.Token, code string, format string, a ...interface{}) *Diagnostic {
+	return p.errorf(tok.Pos, tok.End, code, format, a...)
 }
 
 //registerPrefix 注册前缀解析函数
@@ -330,8 +606,12 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn inParseFn) {
 
 //noPrefixParseFnError 处理不能解析的token错误
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	if t == token.ILLEGAL {
+		p.tokenErrorf(p.curToken, CodeIllegalCharacter, "illegal character %q", p.curToken.Literal).
+			withHint("remove the character")
+		return
+	}
+	p.tokenErrorf(p.curToken, CodeNoPrefixParseFn, "no prefix parse function for %s found", t)
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
@@ -370,14 +650,59 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+//parseTryExpression 解析 try { } catch (e) { } finally { }
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
+	if !p.exceptPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Block = p.parseBlockStatement()
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+			if !p.exceptPeek(token.IDENT) {
+				return nil
+			}
+			expression.CatchParam = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.exceptPeek(token.RPAREN) {
+				return nil
+			}
+		}
+		if !p.exceptPeek(token.LBRACE) {
+			return nil
+		}
+		expression.Catch = p.parseBlockStatement()
+	}
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+		if !p.exceptPeek(token.LBRACE) {
+			return nil
+		}
+		expression.Finally = p.parseBlockStatement()
+	}
+	if expression.Catch == nil && expression.Finally == nil {
+		p.tokenErrorf(expression.Token, CodeInvalidTry, "try without catch or finally").
+			withHint("add a `catch (e) { }` or `finally { }` block")
+		return nil
+	}
+	return expression
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{
 		Token: p.curToken,
 	}
 	block.Statements = []ast.Statement{}
+	p.blockDepth++
+	defer func() { p.blockDepth-- }()
 	p.nextToken()
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
+		if p.panicking {
+			p.synchronize()
+			continue
+		}
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
@@ -391,34 +716,110 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	if !p.exceptPeek(token.LPAREN) {
 		return nil
 	}
-	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(lit) {
+		return nil
+	}
 	if !p.exceptPeek(token.LBRACE) {
 		return nil
 	}
+	//函数体内不能跳出外层的循环
+	loops := p.loops
+	p.loops = nil
 	lit.Body = p.parseBlockStatement()
+	p.loops = loops
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
-	if p.peekTokenIs(token.RPAREN) {
-		p.nextToken()
-		return identifiers
+//parseMacroLiteral 解析宏 macro(a, b) { ... }，宏的参数不支持默认值和剩余参数
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curToken}
+	if !p.exceptPeek(token.LPAREN) {
+		return nil
 	}
-	p.nextToken()
+	params := &ast.FunctionLiteral{}
+	if !p.parseFunctionParameters(params) {
+		return nil
+	}
+	if len(params.Defaults) > 0 || params.Rest != nil {
+		p.tokenErrorf(lit.Token, CodeInvalidParameter, "macro parameters cannot have default values or a rest parameter").
+			withHint("macros receive their arguments as unevaluated expressions")
+		return nil
+	}
+	lit.Parameters = params.Parameters
+	if !p.exceptPeek(token.LBRACE) {
+		return nil
+	}
+	loops := p.loops
+	p.loops = nil
+	lit.Body = p.parseBlockStatement()
+	p.loops = loops
+	return lit
+}
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
+//parseFunctionParameters 解析参数列表 ( a, b = 10, ...rest )
+//默认值只能出现在普通参数之后，剩余参数只能是最后一个
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
+	lit.Parameters = []*ast.Identifier{}
+	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
+		return true
+	}
+	for {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if !p.exceptPeek(token.IDENT) {
+				return false
+			}
+			lit.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.checkDuplicateParameter(lit, lit.Rest) {
+				return false
+			}
+			if p.peekTokenIs(token.COMMA) {
+				p.nextToken()
+				p.tokenErrorf(p.curToken, CodeInvalidParameter, "rest parameter must be the last parameter").
+					withHint("move ...%s to the end of the parameter list", lit.Rest.Value)
+				return false
+			}
+			break
+		}
+		if !p.exceptPeek(token.IDENT) {
+			return false
+		}
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+		if !p.checkDuplicateParameter(lit, ident) {
+			return false
+		}
+		lit.Parameters = append(lit.Parameters, ident)
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			if lit.Defaults == nil {
+				lit.Defaults = map[string]ast.Expression{}
+			}
+			lit.Defaults[ident.Value] = p.parseExpression(LOWEST)
+		} else if len(lit.Defaults) > 0 {
+			p.tokenErrorf(ident.Token, CodeInvalidParameter, "parameter %s without default value follows parameter with default value", ident.Value).
+				withHint("give %s a default value or move it before the parameters with defaults", ident.Value)
+			return false
+		}
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
 	}
-	if !p.exceptPeek(token.RPAREN) {
-		return nil
+	return p.exceptPeek(token.RPAREN)
+}
+
+//checkDuplicateParameter 检查参数是否与之前的参数同名
+func (p *Parser) checkDuplicateParameter(lit *ast.FunctionLiteral, ident *ast.Identifier) bool {
+	for _, param := range lit.Parameters {
+		if param.Value == ident.Value {
+			p.tokenErrorf(ident.Token, CodeInvalidParameter, "duplicate parameter %s", ident.Value).
+				withHint("rename one of the parameters named %s", ident.Value)
+			return false
+		}
 	}
-	return identifiers
+	return true
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
@@ -513,8 +914,8 @@ func (p *Parser) parseAssignExpression(name ast.Expression) ast.Expression {
 	if n, ok := name.(*ast.Identifier); ok {
 		stmt.Name = n
 	} else {
-		msg := fmt.Sprintf("expected assign token to be IDENT, got %s ", name.TokenLiteral())
-		p.errors = append(p.errors, msg)
+		p.errorf(name.Pos(), p.curToken.Pos, CodeInvalidAssign, "expected assign token to be IDENT, got %s ", name.TokenLiteral()).
+			withHint("only variables declared with `let` can be assigned")
 	}
 
 	operator := p.curToken
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"monkey/ast"
 	"monkey/lexer"
+	"reflect"
 	"testing"
 )
 
@@ -257,6 +258,384 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	}
 	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
+
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contains %d statements. got=%d\n", 1, len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got=%T", stmt.Expression)
+	}
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want %d. got=%d", 2, len(macro.Parameters))
+	}
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statements. got=%d", len(macro.Body.Statements))
+	}
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got=%T", macro.Body.Statements[0])
+	}
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+
+	for _, input := range []string{"macro(x = 1) { x }", "macro(...xs) { xs }", "macro(x, x) { x }"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Diagnostics()) == 0 || p.Diagnostics()[0].Code != CodeInvalidParameter {
+			t.Errorf("expected %s for %q. got=%v", CodeInvalidParameter, input, p.Errors())
+		}
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	tests := []struct {
+		input     string
+		init      string
+		condition string
+		post      string
+	}{
+		{"for (let i = 0; i < 10; i = i + 1) { i }", "let i = 0;", "(i < 10)", "i=(i + 1)"},
+		{"for (i = 0; i < 10; ) { i }", "i=0", "(i < 10)", ""},
+		{"for (; i < 10; ) { i };", "", "(i < 10)", ""},
+		{"for (;;) { i }", "", "", ""},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contains %d statements. got=%d\n", 1, len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ForStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T", program.Statements[0])
+		}
+		if got := nodeString(stmt.Init); got != tt.init {
+			t.Errorf("init wrong. want=%q, got=%q", tt.init, got)
+		}
+		if got := nodeString(stmt.Condition); got != tt.condition {
+			t.Errorf("condition wrong. want=%q, got=%q", tt.condition, got)
+		}
+		if got := nodeString(stmt.Post); got != tt.post {
+			t.Errorf("post wrong. want=%q, got=%q", tt.post, got)
+		}
+		if len(stmt.Body.Statements) != 1 {
+			t.Errorf("body is not 1 statements. got=%d\n", len(stmt.Body.Statements))
+		}
+	}
+}
+
+func TestForInStatement(t *testing.T) {
+	input := `for (x in [1, 2]) { puts(x); }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contains %d statements. got=%d\n", 1, len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ForInStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForInStatement. got=%T", program.Statements[0])
+	}
+	if !testIdentifier(t, stmt.Variable, "x") {
+		return
+	}
+	if _, ok := stmt.Iterable.(*ast.ArrayLiteral); !ok {
+		t.Fatalf("stmt.Iterable is not ast.ArrayLiteral. got=%T", stmt.Iterable)
+	}
+	if len(stmt.Body.Statements) != 1 {
+		t.Errorf("body is not 1 statements. got=%d\n", len(stmt.Body.Statements))
+	}
+}
+
+func TestFunctionDefaultAndRestParameters(t *testing.T) {
+	input := `fn(a, b = 10, c = a * 2, ...rest) { rest }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	function, ok := stmt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got=%T", stmt.Expression)
+	}
+	if len(function.Parameters) != 3 {
+		t.Fatalf("function literal parameters wrong. want 3. got=%d", len(function.Parameters))
+	}
+	if _, ok := function.Defaults["a"]; ok {
+		t.Errorf("parameter a should not have a default value")
+	}
+	testLiteralExpression(t, function.Defaults["b"], 10)
+	testInfixExpression(t, function.Defaults["c"], "a", "*", 2)
+	if !testIdentifier(t, function.Rest, "rest") {
+		return
+	}
+	expected := "fn(a,b = 10,c = (a * 2),...rest)rest"
+	if function.String() != expected {
+		t.Errorf("function.String() wrong. want=%q, got=%q", expected, function.String())
+	}
+}
+
+func TestFunctionParameterErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+	}{
+		{"fn(...rest, a) { }", "1:11: rest parameter must be the last parameter"},
+		{"fn(a = 1, b) { }", "1:11: parameter b without default value follows parameter with default value"},
+		{"fn(a, b, a) { }", "1:10: duplicate parameter a"},
+		{"fn(a, ...a) { }", "1:10: duplicate parameter a"},
+		{"fn(1) { }", "1:4: expected next token to be IDENT, got INT instead"},
+		{"fn(...) { }", "1:7: expected next token to be IDENT, got ) instead"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+		if errors[0] != tt.message {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.message, errors[0])
+		}
+	}
+}
+
+func TestForStatementErrors(t *testing.T) {
+	tests := []string{
+		"for i < 10 { }",
+		"for (let i = 0 i < 10; ) { }",
+		"for (x in arr { }",
+	}
+	for _, input := range tests {
+		l := lexer.New(input)
+		p := New(l)
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+	p := New(lexer.New("for (i = 0 i < 10; ) { }"))
+	p.ParseProgram()
+	if errors := p.Errors(); len(errors) == 0 || errors[0] != "1:10: expected token to be ;, got INT instead" {
+		t.Errorf("wrong errors. got=%q", errors)
+	}
+}
+
+func TestLoopControlStatements(t *testing.T) {
+	input := `outer: for (x in xs) { for (;;) { break outer; continue; } }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	outer, ok := program.Statements[0].(*ast.ForInStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForInStatement. got=%T", program.Statements[0])
+	}
+	if !testIdentifier(t, outer.Label, "outer") {
+		return
+	}
+	inner, ok := outer.Body.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("outer.Body.Statements[0] is not ast.ForStatement. got=%T", outer.Body.Statements[0])
+	}
+	if inner.Label != nil {
+		t.Errorf("inner.Label was not nil. got=%+v", inner.Label)
+	}
+	if len(inner.Body.Statements) != 2 {
+		t.Fatalf("inner body is not 2 statements. got=%d", len(inner.Body.Statements))
+	}
+	brk, ok := inner.Body.Statements[0].(*ast.BreakStatement)
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.BreakStatement. got=%T", inner.Body.Statements[0])
+	}
+	if !testIdentifier(t, brk.Label, "outer") {
+		return
+	}
+	cont, ok := inner.Body.Statements[1].(*ast.ContinueStatement)
+	if !ok {
+		t.Fatalf("Statements[1] is not ast.ContinueStatement. got=%T", inner.Body.Statements[1])
+	}
+	if cont.Label != nil {
+		t.Errorf("cont.Label was not nil. got=%+v", cont.Label)
+	}
+}
+
+func TestLoopControlErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "1:1: break outside of loop"},
+		{"if (true) { continue; }", "1:13: continue outside of loop"},
+		{"for (;;) { fn() { break; } }", "1:19: break outside of loop"},
+		{"for (;;) { break outer; }", "1:18: undefined loop label: outer"},
+		{"a: for (;;) { } for (;;) { continue a; }", "1:37: undefined loop label: a"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := `let add = fn(a, b) {
+  a + b;
+};
+add(1, 2)[0];
+x = 5;`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	let := program.Statements[0].(*ast.LetStatement)
+	function := let.Value.(*ast.FunctionLiteral)
+	body := function.Body.Statements[0].(*ast.ExpressionStatement)
+	index := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.IndexExpression)
+	call := index.Left.(*ast.CallExpression)
+	assign := program.Statements[2].(*ast.ExpressionStatement).Expression
+
+	tests := []struct {
+		node     ast.Node
+		expected string
+	}{
+		{program, "1:1"},
+		{let, "1:1"},
+		{let.Name, "1:5"},
+		{function, "1:11"},
+		{function.Parameters[1], "1:17"},
+		{body.Expression, "2:3"},
+		{index, "4:1"},
+		{call.Arguments[1], "4:8"},
+		{assign, "5:1"},
+	}
+	for i, tt := range tests {
+		if got := tt.node.Pos().String(); got != tt.expected {
+			t.Errorf("tests[%d] %T has wrong position. want=%q, got=%q", i, tt.node, tt.expected, got)
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	input := "let x = 1;\nlet = 2;"
+	l := lexer.NewWithFilename("main.mk", input)
+	p := New(l)
+	p.ParseProgram()
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors")
+	}
+	expected := "main.mk:2:5: expected next token to be IDENT, got = instead"
+	if errors[0] != expected {
+		t.Errorf("wrong error. want=%q, got=%q", expected, errors[0])
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input      string
+		catchParam string
+		hasCatch   bool
+		hasFinally bool
+	}{
+		{"try { x } catch (e) { e }", "e", true, false},
+		{"try { x } catch { 1 }", "", true, false},
+		{"try { x } finally { 1 }", "", false, true},
+		{"let y = try { x } catch (err) { 1 } finally { 2 };", "err", true, true},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contains %d statements. got=%d\n", 1, len(program.Statements))
+		}
+		var exp ast.Expression
+		switch stmt := program.Statements[0].(type) {
+		case *ast.ExpressionStatement:
+			exp = stmt.Expression
+		case *ast.LetStatement:
+			exp = stmt.Value
+		}
+		try, ok := exp.(*ast.TryExpression)
+		if !ok {
+			t.Fatalf("exp is not ast.TryExpression. got=%T", exp)
+		}
+		if len(try.Block.Statements) != 1 {
+			t.Errorf("try block is not 1 statements. got=%d", len(try.Block.Statements))
+		}
+		if got := nodeString(try.CatchParam); got != tt.catchParam {
+			t.Errorf("catch param wrong. want=%q, got=%q", tt.catchParam, got)
+		}
+		if (try.Catch != nil) != tt.hasCatch {
+			t.Errorf("catch block presence wrong. want=%t", tt.hasCatch)
+		}
+		if (try.Finally != nil) != tt.hasFinally {
+			t.Errorf("finally block presence wrong. want=%t", tt.hasFinally)
+		}
+	}
+}
+
+func TestThrowStatement(t *testing.T) {
+	l := lexer.New(`throw "boom"; 1`)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contains %d statements. got=%d\n", 2, len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ThrowStatement. got=%T", program.Statements[0])
+	}
+	if stmt.Value.String() != "boom" {
+		t.Errorf("stmt.Value wrong. got=%q", stmt.Value.String())
+	}
+
+	l = lexer.New("try { 1 }")
+	p = New(l)
+	p.ParseProgram()
+	if len(p.Diagnostics()) != 1 || p.Diagnostics()[0].Code != CodeInvalidTry {
+		t.Errorf("expected %s diagnostic. got=%q", CodeInvalidTry, p.Errors())
+	}
+}
+
+//nodeString 空节点返回空字符串
+func nodeString(node ast.Node) string {
+	if node == nil || reflect.ValueOf(node).IsNil() {
+		return ""
+	}
+	return node.String()
+}
+
 func TestIfExpression(t *testing.T) {
 	input := `if (x < y) { x }`
 	l := lexer.New(input)
@@ -569,6 +948,47 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	input := "123456789012345678901234567890;"
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Big == nil || literal.Big.String() != "123456789012345678901234567890" {
+		t.Errorf("literal.Big wrong. got=%v", literal.Big)
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5;", 1.5},
+		{"2e3;", 2000},
+		{"0.125e-1;", 0.0125},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
 func TestIdentifierExpression(t *testing.T) {
 	//
 	input := `foobar;`
@@ -687,7 +1107,7 @@ let foobar = 833383;
 }
 
 func checkParserErrors(t *testing.T, p *Parser) {
-	errors := p.errors
+	errors := p.Errors()
 	if len(errors) == 0 {
 		return
 	}
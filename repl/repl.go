@@ -2,16 +2,26 @@ package repl
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
+	"monkey/ast"
+	"monkey/compiler"
 	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"monkey/vm"
 )
 
 const PROMPT = ">>"
 
+//执行程序的引擎
+const (
+	EngineEval = "eval" //树遍历求值器
+	EngineVM   = "vm"   //编译为字节码后由虚拟机执行
+)
+
 // repl/repl.go
 
 const MONKEY_FACE = `            __,__
@@ -27,18 +37,31 @@ const MONKEY_FACE = `            __,__
            '-----'
 `
 
-func printParserErrors(out io.Writer, errors []string) {
+//PrintParserErrors 输出解析错误，每条错误附带出错的源码行
+func PrintParserErrors(out io.Writer, source string, diagnostics []*parser.Diagnostic) {
 	io.WriteString(out, MONKEY_FACE)
 	io.WriteString(out, "Woops! We ran into some monkey business here!\n")
 	io.WriteString(out, " parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+	for _, d := range diagnostics {
+		io.WriteString(out, d.Render(source))
 	}
 }
 
 func Start(in io.Reader, out io.Writer) {
+	StartWithEngine(EngineEval, in, out)
+}
+
+//StartWithEngine 使用指定的引擎启动REPL，每一行的变量在后续的行中仍然可用
+func StartWithEngine(engine string, in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 	env := object.NewEnvironment()
+	macroEnv := object.NewEnvironment()
+	constants := []object.Object{}
+	globals := make([]object.Object, vm.GlobalsSize)
+	symbolTable := compiler.NewSymbolTable()
+	for i, name := range evaluator.BuiltinNames {
+		symbolTable.DefineBuiltin(i, name)
+	}
 	for {
 		fmt.Fprintf(out, PROMPT)
 		scanned := scanner.Scan()
@@ -50,15 +73,48 @@ func Start(in io.Reader, out io.Writer) {
 		l := lexer.New(line)
 		p := parser.New(l)
 		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		if len(p.Diagnostics()) != 0 {
+			PrintParserErrors(out, line, p.Diagnostics())
+			continue
+		}
+		//之前的行中定义的宏在后续的行中仍然可用
+		evaluator.DefineMacros(program, macroEnv)
+		expanded, err := evaluator.ExpandMacrosContext(context.Background(), program, macroEnv, evaluator.Limits{})
+		if err != nil {
+			PrintResult(out, err)
 			continue
 		}
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+		program = expanded.(*ast.Program)
+		if engine != EngineVM {
+			PrintResult(out, evaluator.EvalContext(context.Background(), program, env, evaluator.Limits{}))
+			continue
+		}
+		comp := compiler.NewWithState(symbolTable, constants)
+		if err := comp.Compile(program); err != nil {
+			fmt.Fprintf(out, "Woops! Compilation failed:\n %s\n", err)
+			continue
+		}
+		bytecode := comp.Bytecode()
+		constants = bytecode.Constants
+		machine := vm.NewWithGlobalsState(bytecode, globals)
+		if err := machine.Run(); err != nil {
+			fmt.Fprintf(out, "Woops! Executing bytecode failed:\n %s\n", err)
+			continue
 		}
+		PrintResult(out, machine.Result())
+	}
+}
+
+//PrintResult 输出执行结果，错误输出调用栈
+func PrintResult(out io.Writer, result object.Object) {
+	if result == nil {
+		return
+	}
+	if err, ok := result.(*object.Error); ok {
+		io.WriteString(out, err.StackTrace())
+	} else {
+		io.WriteString(out, result.Inspect())
+		io.WriteString(out, "\n")
 	}
 }
 
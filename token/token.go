@@ -1,5 +1,7 @@
 package token
 
+import "fmt"
+
 //TokenType 类型
 type TokenType string
 
@@ -8,6 +10,37 @@ type Token struct {
 	Type TokenType
 	//字面量
 	Literal string
+	//起始位置
+	Pos Position
+	//结束位置（最后一个字符之后）
+	End Position
+}
+
+//Position 源码中的位置
+type Position struct {
+	Filename string //文件名，可为空
+	Offset   int    //字节偏移，从0开始
+	Line     int    //行号，从1开始
+	Column   int    //列号（按字节计），从1开始
+}
+
+//IsValid 是否是有效的位置
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+//String 格式为 file:line:column，无文件名时为 line:column
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.Filename != "" {
+			return p.Filename
+		}
+		return "-"
+	}
+	if p.Filename != "" {
+		return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
 }
 
 //token类型枚举
@@ -17,11 +50,13 @@ const (
 
 	ILLEGAL = "ILLEGAL" //未知符号
 	EOF     = "EOF"     //文件结尾
+	COMMENT = "COMMENT" //注释 //...，只有保留注释的词法解析器才会输出
 
 	//标识符 字面量
 
 	IDENT  = "IDENT" //标识符
 	INT    = "INT"   //int类型
+	FLOAT  = "FLOAT" //浮点数
 	STRING = "STRING"
 
 	//运算符
@@ -32,6 +67,7 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
 	LT       = "<"
 	GT       = ">"
 
@@ -43,6 +79,7 @@ const (
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
+	ELLIPSIS  = "..."
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -61,18 +98,34 @@ const (
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
 
-	FOR = "FOR"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	THROW    = "THROW"
+	MACRO    = "MACRO"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
-	"for":    FOR,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"throw":    THROW,
+	"macro":    MACRO,
 }
 
 //LookupIdent 判定是否是关键字还是标识符
@@ -0,0 +1,91 @@
+package vm
+
+import (
+	"fmt"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/evaluator/evaltest"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+// vm/conformance_test.go
+
+//rejectedTests 两个引擎会给出不同结果的程序，在语法分析时就被拒绝
+var rejectedTests = []string{
+	"fn(a, a) { a }(1, 2)",
+	"fn(a, b = 1, ...a) { a }(1, 2)",
+	"macro(x, x) { x }",
+}
+
+func TestConformance(t *testing.T) {
+	for _, input := range rejectedTests {
+		p := parser.New(lexer.New(input))
+		if p.ParseProgram(); len(p.Errors()) == 0 {
+			t.Errorf("expected parser errors for %q", input)
+		}
+	}
+	//用例与求值器的测试共用，虚拟机的结果既要符合用例的期望，也要与求值器的结果完全一致
+	for _, tt := range evaltest.All {
+		input := tt.Input
+		program := parser.New(lexer.New(input)).ParseProgram()
+		expected := evaluator.Eval(program, object.NewEnvironment())
+
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Errorf("compiler error for %q: %s", input, err)
+			continue
+		}
+		machine := New(comp.Bytecode())
+		if err := machine.Run(); err != nil {
+			t.Errorf("vm error for %q: %s", input, err)
+			continue
+		}
+		if err := evaltest.Check(machine.Result(), tt.Expected); err != nil {
+			t.Errorf("%q: %s", input, err)
+		}
+		if expected == nil {
+			continue
+		}
+		compareResult(t, input, expected, machine.Result())
+	}
+}
+
+func compareResult(t *testing.T, input string, expected, actual object.Object) {
+	if actual == nil {
+		t.Errorf("nil result for %q. want=%s", input, expected.Inspect())
+		return
+	}
+	if expected.Type() != actual.Type() {
+		t.Errorf("wrong type for %q. want=%s, got=%s (%s)", input, expected.Type(), actual.Type(), actual.Inspect())
+		return
+	}
+	if want, got := evaltest.Inspect(expected), evaltest.Inspect(actual); want != got {
+		t.Errorf("wrong value for %q. want=%s, got=%s", input, want, got)
+	}
+	want, ok := expected.(*object.Error)
+	if !ok {
+		return
+	}
+	got := actual.(*object.Error)
+	if want.Kind != got.Kind {
+		t.Errorf("wrong error kind for %q. want=%s, got=%s", input, want.Kind, got.Kind)
+	}
+	if want.Pos != got.Pos {
+		t.Errorf("wrong error position for %q. want=%s, got=%s", input, want.Pos, got.Pos)
+	}
+	if want, got := stackString(want.Stack), stackString(got.Stack); want != got {
+		t.Errorf("wrong stack for %q. want=%s, got=%s", input, want, got)
+	}
+}
+
+func stackString(stack []*object.Frame) string {
+	frames := []string{}
+	for _, frame := range stack {
+		frames = append(frames, fmt.Sprintf("%s@%s", frame, frame.Pos))
+	}
+	return strings.Join(frames, " <- ")
+}
@@ -0,0 +1,110 @@
+package vm
+
+import (
+	"monkey/object"
+)
+
+//iterator for-in循环的迭代状态，只存在于栈上
+type iterator struct {
+	items []object.Object
+	next  int
+}
+
+func (it *iterator) Type() object.ObjectType {
+	return "ITERATOR"
+}
+
+func (it *iterator) Inspect() string {
+	return "iterator"
+}
+
+//raise 抛出错误，跳转到最近的异常处理，没有异常处理时返回false
+func (vm *VM) raise(err *object.Error) bool {
+	vm.locate(err)
+	if err.Stack == nil {
+		err.Stack = vm.callStack(nil)
+	}
+	for vm.framesIndex > 0 {
+		frame := vm.currentFrame()
+		if n := len(frame.handlers); n > 0 {
+			h := frame.handlers[n-1]
+			frame.handlers = frame.handlers[:n-1]
+			vm.sp = h.sp
+			frame.ip = h.target - 1
+			vm.push(&object.ErrorValue{Err: err})
+			return true
+		}
+		if vm.framesIndex == 1 {
+			break
+		}
+		vm.popFrame()
+		vm.closeCells(frame.basePointer)
+	}
+	return false
+}
+
+//locate 错误没有位置时，使用当前正在执行的指令的位置
+func (vm *VM) locate(err *object.Error) *object.Error {
+	if !err.Pos.IsValid() {
+		frame := vm.currentFrame()
+		err.Pos = frame.cl.Fn.Positions.Lookup(frame.ip)
+	}
+	return err
+}
+
+//callError 调用函数失败时，调用栈中包含被调用的函数
+func (vm *VM) callError(err *object.Error, name string, numArgs int) *object.Error {
+	if err.Stack != nil {
+		return err
+	}
+	args := make([]object.Object, numArgs)
+	copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+	frame := vm.currentFrame()
+	callee := &object.Frame{
+		Function: name,
+		Pos:      frame.cl.Fn.Positions.Lookup(frame.ip),
+		Args:     args,
+	}
+	err.Stack = vm.callStack(callee)
+	return err
+}
+
+//callStack 由正在执行的函数生成调用栈，innermost不为空时作为最内层的一帧
+func (vm *VM) callStack(innermost *object.Frame) []*object.Frame {
+	var parent *object.Frame
+	for i := 1; i < vm.framesIndex; i++ {
+		caller := vm.frames[i-1]
+		frame := vm.frames[i]
+		parent = &object.Frame{
+			Function: frame.cl.Fn.Name,
+			Pos:      caller.cl.Fn.Positions.Lookup(caller.ip),
+			Args:     vm.frameArgs(frame),
+			Parent:   parent,
+		}
+	}
+	if innermost != nil {
+		innermost.Parent = parent
+		parent = innermost
+	}
+	if parent == nil {
+		return nil
+	}
+	return parent.Stack()
+}
+
+//frameArgs 函数的参数当前的值，剩余参数展开
+func (vm *VM) frameArgs(frame *Frame) []object.Object {
+	fn := frame.cl.Fn
+	args := []object.Object{}
+	for i := 0; i < fn.NumParameters; i++ {
+		if arg := vm.stack[frame.basePointer+i]; arg != nil {
+			args = append(args, arg)
+		}
+	}
+	if fn.Rest {
+		if rest, ok := vm.stack[frame.basePointer+fn.NumParameters].(*object.Array); ok {
+			args = append(args, rest.Elements...)
+		}
+	}
+	return args
+}
@@ -0,0 +1,28 @@
+package vm
+
+import (
+	"monkey/code"
+	"monkey/object"
+)
+
+//Frame 一次函数调用的执行状态
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	basePointer int       //局部变量在栈上的起始位置
+	handlers    []handler //当前函数中已注册的异常处理
+}
+
+//handler try语句注册的异常处理
+type handler struct {
+	target int //出错时跳转的位置
+	sp     int //注册时的栈顶，出错时恢复
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
@@ -0,0 +1,529 @@
+package vm
+
+import (
+	"fmt"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/object"
+)
+
+//StackSize 值栈的初始大小，不够时按需扩大
+const StackSize = 2048
+const GlobalsSize = 65536
+
+//MaxFrames 函数调用最多嵌套的层数，与求值器默认的限制相同，超出时返回CallDepthError
+const MaxFrames = evaluator.DefaultMaxDepth
+
+//initialFrames 调用帧的初始个数，不够时按需扩大
+const initialFrames = 64
+
+//VM 执行字节码的栈式虚拟机
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+
+	stack []object.Object
+	sp    int //始终指向下一个空闲的位置，栈顶为stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	openCells []openCell //捕获了栈上局部变量、所在函数尚未返回的Cell
+
+	lastPopped object.Object
+	result     object.Object //顶层return的值或未捕获的错误
+}
+
+//openCell 指向栈上slot位置的Cell
+type openCell struct {
+	slot int
+	cell *object.Cell
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		Positions:    bytecode.Positions,
+	}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+	frames := make([]*Frame, initialFrames)
+	frames[0] = mainFrame
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     make([]object.Object, GlobalsSize),
+		globalNames: bytecode.Globals,
+		stack:       make([]object.Object, StackSize),
+		sp:          0,
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+//NewWithGlobalsState 沿用之前的全局变量，用于REPL中逐行执行
+func NewWithGlobalsState(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = s
+	return vm
+}
+
+//LastPoppedStackElem 最后一个被弹出栈的值，即最后一条表达式语句的值
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.lastPopped
+}
+
+//Result 程序的执行结果：未捕获的错误、顶层return的值或者最后一条表达式语句的值
+func (vm *VM) Result() object.Object {
+	if vm.result != nil {
+		return vm.result
+	}
+	return vm.lastPopped
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	if vm.framesIndex == len(vm.frames) {
+		vm.frames = append(vm.frames, f)
+	} else {
+		vm.frames[vm.framesIndex] = f
+	}
+	vm.framesIndex++
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+//Run 执行字节码，只有字节码本身有误时才返回error，Monkey的运行时错误通过Result返回
+func (vm *VM) Run() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			vm.result = vm.locate(&object.Error{Kind: object.INTERNAL_ERROR, Message: fmt.Sprintf("internal error: %v", r)})
+		}
+	}()
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		var failure *object.Error
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			failure = vm.push(vm.constants[constIndex])
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan:
+			failure = vm.executeBinaryOperation(op)
+		case code.OpMinus:
+			failure = vm.pushResult(evaluator.PrefixOperation("-", vm.pop()))
+		case code.OpBang:
+			failure = vm.pushResult(evaluator.PrefixOperation("!", vm.pop()))
+		case code.OpTrue:
+			failure = vm.push(evaluator.TRUE)
+		case code.OpFalse:
+			failure = vm.push(evaluator.FALSE)
+		case code.OpNull:
+			failure = vm.push(evaluator.NULL)
+		case code.OpPop:
+			vm.lastPopped = vm.pop()
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			if !evaluator.IsTruthy(vm.pop()) {
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			vm.globals[globalIndex] = vm.pop()
+		case code.OpGetGlobal:
+			globalIndex := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			failure = vm.pushVariable(vm.globals[globalIndex], vm.globalNames, globalIndex)
+		case code.OpSetLocal:
+			localIndex := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+			vm.stack[vm.currentFrame().basePointer+localIndex] = vm.pop()
+		case code.OpGetLocal:
+			localIndex := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+			frame := vm.currentFrame()
+			failure = vm.pushVariable(vm.stack[frame.basePointer+localIndex], frame.cl.Fn.LocalNames, localIndex)
+		case code.OpGetBuiltin:
+			builtinIndex := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+			builtin, _ := evaluator.GetBuiltin(evaluator.BuiltinNames[builtinIndex])
+			failure = vm.push(builtin)
+		case code.OpGetFree:
+			freeIndex := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+			cl := vm.currentFrame().cl
+			failure = vm.pushVariable(*cl.Free[freeIndex].Ref, cl.Fn.FreeNames, freeIndex)
+		case code.OpGetBoundLocal, code.OpGetBoundFree:
+			index := int(code.ReadUint8(ins[ip+1:]))
+			target := int(code.ReadUint16(ins[ip+2:]))
+			vm.currentFrame().ip += 3
+			frame := vm.currentFrame()
+			var value object.Object
+			if op == code.OpGetBoundLocal {
+				value = vm.stack[frame.basePointer+index]
+			} else {
+				value = *frame.cl.Free[index].Ref
+			}
+			if value != nil {
+				frame.ip = target - 1
+				failure = vm.push(value)
+			}
+		case code.OpCaptureLocal:
+			localIndex := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+			failure = vm.push(vm.captureLocal(vm.currentFrame().basePointer + localIndex))
+		case code.OpCaptureFree:
+			freeIndex := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+			failure = vm.push(vm.currentFrame().cl.Free[freeIndex])
+		case code.OpClosure:
+			constIndex := int(code.ReadUint16(ins[ip+1:]))
+			numFree := int(code.ReadUint8(ins[ip+3:]))
+			vm.currentFrame().ip += 3
+			failure = vm.pushClosure(constIndex, numFree)
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp = vm.sp - numElements
+			failure = vm.push(&object.Array{Elements: elements})
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			hash, e := vm.buildHash(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+			if e != nil {
+				failure = e
+			} else {
+				failure = vm.push(hash)
+			}
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			failure = vm.pushResult(evaluator.IndexOperation(left, index))
+		case code.OpCall:
+			numArgs := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+			failure = vm.executeCall(numArgs)
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+			if vm.framesIndex == 1 {
+				vm.result = returnValue
+				return nil
+			}
+			frame := vm.popFrame()
+			vm.closeCells(frame.basePointer)
+			vm.sp = frame.basePointer - 1
+			failure = vm.push(returnValue)
+		case code.OpReturn:
+			if vm.framesIndex == 1 {
+				vm.result = evaluator.NULL
+				return nil
+			}
+			frame := vm.popFrame()
+			vm.closeCells(frame.basePointer)
+			vm.sp = frame.basePointer - 1
+			failure = vm.push(evaluator.NULL)
+		case code.OpIter:
+			items, e := evaluator.Iterate(vm.pop())
+			if e != nil {
+				failure = e
+			} else {
+				failure = vm.push(&iterator{items: items})
+			}
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			it := vm.stack[vm.sp-1].(*iterator)
+			if it.next < len(it.items) {
+				it.next++
+				failure = vm.push(it.items[it.next-1])
+			} else {
+				vm.sp--
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpSetupTry:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			frame := vm.currentFrame()
+			frame.handlers = append(frame.handlers, handler{target: pos, sp: vm.sp})
+		case code.OpPopTry:
+			frame := vm.currentFrame()
+			frame.handlers = frame.handlers[:len(frame.handlers)-1]
+		case code.OpThrow:
+			failure = evaluator.Throw(vm.pop())
+		default:
+			def, err := code.Lookup(byte(op))
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("opcode %s not supported", def.Name)
+		}
+
+		if failure != nil && !vm.raise(failure) {
+			vm.result = failure
+			return nil
+		}
+	}
+	return nil
+}
+
+func (vm *VM) push(o object.Object) *object.Error {
+	if vm.sp >= len(vm.stack) {
+		vm.growStack(vm.sp + 1)
+	}
+	vm.stack[vm.sp] = o
+	vm.sp++
+	return nil
+}
+
+//pushResult 将运算结果压栈，结果是错误时返回该错误
+func (vm *VM) pushResult(o object.Object) *object.Error {
+	if err, ok := o.(*object.Error); ok {
+		return err
+	}
+	return vm.push(o)
+}
+
+//pushVariable 将变量的值压栈，变量尚未赋值时报错
+func (vm *VM) pushVariable(o object.Object, names []string, index int) *object.Error {
+	if o == nil {
+		name := ""
+		if index < len(names) {
+			name = names[index]
+		}
+		return &object.Error{Kind: object.NAME_ERROR, Message: "identifier not found: " + name}
+	}
+	return vm.push(o)
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) *object.Error {
+	right := vm.pop()
+	left := vm.pop()
+	//整数运算的快速路径，溢出等情况交给求值器处理
+	if l, ok := left.(*object.Integer); ok {
+		if r, ok := right.(*object.Integer); ok {
+			switch op {
+			case code.OpAdd:
+				if sum := l.Value + r.Value; (sum > l.Value) == (r.Value > 0) {
+					return vm.push(&object.Integer{Value: sum})
+				}
+			case code.OpSub:
+				if diff := l.Value - r.Value; (diff < l.Value) == (r.Value > 0) {
+					return vm.push(&object.Integer{Value: diff})
+				}
+			case code.OpLessThan:
+				return vm.push(evaluator.NativeBool(l.Value < r.Value))
+			case code.OpGreaterThan:
+				return vm.push(evaluator.NativeBool(l.Value > r.Value))
+			case code.OpEqual:
+				return vm.push(evaluator.NativeBool(l.Value == r.Value))
+			case code.OpNotEqual:
+				return vm.push(evaluator.NativeBool(l.Value != r.Value))
+			}
+		}
+	}
+	return vm.pushResult(evaluator.InfixOperation(operators[op], left, right))
+}
+
+var operators = map[code.Opcode]string{
+	code.OpAdd:         "+",
+	code.OpSub:         "-",
+	code.OpMul:         "*",
+	code.OpDiv:         "/",
+	code.OpMod:         "%",
+	code.OpEqual:       "==",
+	code.OpNotEqual:    "!=",
+	code.OpGreaterThan: ">",
+	code.OpLessThan:    "<",
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, *object.Error) {
+	hashedPairs := make(map[object.HashKey]object.HashPair)
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, &object.Error{Kind: object.TYPE_ERROR, Message: fmt.Sprintf("unusable as hash key: %s", key.Type())}
+		}
+		hashedPairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+	return &object.Hash{Pairs: hashedPairs}, nil
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) *object.Error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return &object.Error{Kind: object.INTERNAL_ERROR, Message: fmt.Sprintf("not a function: %+v", constant)}
+	}
+	free := make([]*object.Cell, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i].(*object.Cell)
+	}
+	vm.sp = vm.sp - numFree
+	return vm.push(&object.Closure{Fn: function, Free: free})
+}
+
+//growStack 将值栈扩大到至少size，捕获了栈上局部变量的Cell改为指向新的栈
+func (vm *VM) growStack(size int) {
+	newSize := len(vm.stack) * 2
+	for newSize < size {
+		newSize *= 2
+	}
+	stack := make([]object.Object, newSize)
+	copy(stack, vm.stack)
+	vm.stack = stack
+	for _, open := range vm.openCells {
+		open.cell.Ref = &vm.stack[open.slot]
+	}
+}
+
+//captureLocal 捕获栈上slot位置的局部变量，同一个变量只对应一个Cell
+func (vm *VM) captureLocal(slot int) *object.Cell {
+	for _, open := range vm.openCells {
+		if open.slot == slot {
+			return open.cell
+		}
+	}
+	cell := &object.Cell{Ref: &vm.stack[slot]}
+	vm.openCells = append(vm.openCells, openCell{slot: slot, cell: cell})
+	return cell
+}
+
+//closeCells 函数返回前，将捕获的局部变量从栈上复制到Cell中
+func (vm *VM) closeCells(basePointer int) {
+	if len(vm.openCells) == 0 {
+		return
+	}
+	open := vm.openCells[:0]
+	for _, c := range vm.openCells {
+		if c.slot >= basePointer {
+			c.cell.Close()
+		} else {
+			open = append(open, c)
+		}
+	}
+	vm.openCells = open
+}
+
+func (vm *VM) executeCall(numArgs int) *object.Error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		err := &object.Error{Kind: object.TYPE_ERROR, Message: fmt.Sprintf("not a function: %s", callee.Type())}
+		return vm.callError(err, "", numArgs)
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) *object.Error {
+	fn := cl.Fn
+	required := fn.Required()
+	if numArgs < required || (!fn.Rest && numArgs > fn.NumParameters) {
+		err := &object.Error{
+			Kind:    object.ARGUMENT_ERROR,
+			Message: fmt.Sprintf("wrong number of arguments. got=%d, want=%s", numArgs, arity(fn)),
+		}
+		return vm.callError(err, fn.Name, numArgs)
+	}
+	//framesIndex包含最外层的主程序，等于新调用的嵌套层数
+	if vm.framesIndex > MaxFrames {
+		err := &object.Error{Kind: object.CALL_DEPTH_ERROR, Message: fmt.Sprintf("maximum call depth of %d exceeded", MaxFrames)}
+		err.Stack = vm.callStack(nil)
+		return err
+	}
+	basePointer := vm.sp - numArgs
+	if basePointer+fn.NumLocals >= len(vm.stack) {
+		vm.growStack(basePointer + fn.NumLocals + 1)
+	}
+	supplied := numArgs
+	if fn.Rest {
+		restStart := basePointer + fn.NumParameters
+		rest := []object.Object{}
+		if numArgs > fn.NumParameters {
+			rest = append(rest, vm.stack[restStart:vm.sp]...)
+			supplied = fn.NumParameters
+		}
+		vm.stack[restStart] = &object.Array{Elements: rest}
+		vm.sp = restStart + 1
+	}
+	//未传入的参数和局部变量在赋值前为空
+	for i := vm.sp; i < basePointer+fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+	frame := NewFrame(cl, basePointer)
+	if len(fn.Entries) > 0 {
+		frame.ip = fn.Entries[supplied-required] - 1
+	}
+	vm.pushFrame(frame)
+	vm.sp = basePointer + fn.NumLocals
+	return nil
+}
+
+//arity 描述函数可以接受的参数个数
+func arity(fn *object.CompiledFunction) string {
+	required := fn.Required()
+	switch {
+	case fn.Rest:
+		return fmt.Sprintf("at least %d", required)
+	case required == fn.NumParameters:
+		return fmt.Sprintf("%d", required)
+	default:
+		return fmt.Sprintf("%d to %d", required, fn.NumParameters)
+	}
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) *object.Error {
+	args := vm.stack[vm.sp-numArgs : vm.sp]
+	result := builtin.Fn(args...)
+	if err, ok := result.(*object.Error); ok {
+		return vm.callError(err, builtinNames[builtin], numArgs)
+	}
+	vm.sp = vm.sp - numArgs - 1
+	if result == nil {
+		result = evaluator.NULL
+	}
+	return vm.push(result)
+}
+
+//builtinNames 调用栈中展示的内置函数名
+var builtinNames = func() map[*object.Builtin]string {
+	names := map[*object.Builtin]string{}
+	for _, name := range evaluator.BuiltinNames {
+		builtin, _ := evaluator.GetBuiltin(name)
+		names[builtin] = name
+	}
+	return names
+}()
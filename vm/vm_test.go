@@ -0,0 +1,159 @@
+package vm
+
+import (
+	"monkey/compiler"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+)
+
+// vm/vm_test.go
+
+type vmTestCase struct {
+	input    string
+	expected string
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		vm := New(comp.Bytecode())
+		if err := vm.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+		result := vm.Result()
+		if result == nil {
+			t.Errorf("nil result for %q", tt.input)
+			continue
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{"let newClosure = fn(a) { fn() { a; }; }; let closure = newClosure(99); closure();", "99"},
+		{"let newAdder = fn(a, b) { fn(c) { a + b + c }; }; let adder = newAdder(1, 2); adder(8);", "11"},
+		{`
+let newAdderOuter = fn(a, b) {
+	let c = a + b;
+	fn(d) {
+		let e = d + c;
+		fn(f) { e + f; };
+	};
+};
+let newAdderInner = newAdderOuter(1, 2)
+let adder = newAdderInner(3);
+adder(8);
+`, "14"},
+		//闭包与外层函数共享同一个变量
+		{"let f = fn() { let n = 1; let g = fn() { n }; n = 2; g() }; f()", "2"},
+		{`
+let fs = [];
+for (x in [1, 2, 3]) { fs = push(fs, fn() { x }); }
+fs[0]() + fs[2]()
+`, "6"},
+	}
+	runVmTests(t, tests)
+}
+
+func TestAssignmentScope(t *testing.T) {
+	tests := []vmTestCase{
+		//赋值在当前函数中定义变量，执行之前读取的是外层的同名变量
+		{"let g = 0; let f = fn() { for (let i = 0; i < 3; i = i + 1) { g = g + 1 }; g }; [f(), g]", "[3, 0]"},
+		{"let x = 1; let f = fn() { let y = x; let x = 10; [y, x] }; f()", "[1, 10]"},
+		{"let x = 1; let f = fn() { let g = fn() { x }; let a = g(); let x = 2; [a, g()] }; f()", "[1, 2]"},
+		{"let f = fn() { let a = 1; fn() { let b = a; let a = b + 1; a } }; f()()", "2"},
+		{"let f = fn() { len = fn(x) { 0 }; len([1]) }; [f(), len([1])]", "[0, 1]"},
+		{"let f = fn() { let y = z; let z = 1; y }; f()", "ERROR: identifier not found: z"},
+		{`
+let f = fn() {
+	let log = [];
+	try {
+		try { throw "x" } finally { log = push(log, "finally") }
+	} catch (e) {
+		log = push(log, e["message"])
+	};
+	log
+};
+f()
+`, "[finally, x]"},
+	}
+	runVmTests(t, tests)
+}
+
+func TestRecursiveFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let countDown = fn(x) { if (x == 0) { return 0; } else { countDown(x - 1); } }; countDown(1);", "0"},
+		{`
+let wrapper = fn() {
+	let countDown = fn(x) { if (x == 0) { return 0; } else { countDown(x - 1); } };
+	countDown(1);
+};
+wrapper();
+`, "0"},
+		{`
+let fibonacci = fn(x) {
+	if (x == 0) { return 0; }
+	if (x == 1) { return 1; }
+	fibonacci(x - 1) + fibonacci(x - 2);
+};
+fibonacci(15);
+`, "610"},
+	}
+	runVmTests(t, tests)
+}
+
+func TestCallingFunctionsWithDefaultsAndRest(t *testing.T) {
+	tests := []vmTestCase{
+		{"let f = fn(a, b = 2, c = b + 1) { [a, b, c] }; f(1)", "[1, 2, 3]"},
+		{"let f = fn(a, b = 2, c = b + 1) { [a, b, c] }; f(1, 5)", "[1, 5, 6]"},
+		{"let f = fn(a, b = 2, c = b + 1) { [a, b, c] }; f(1, 5, 7)", "[1, 5, 7]"},
+		{"let f = fn(a, ...rest) { let g = fn() { rest }; g() }; f(1, 2, 3)", "[2, 3]"},
+		{"let f = fn() { 1 }; f(1)", "ERROR: wrong number of arguments. got=1, want=0"},
+		{"1()", "ERROR: not a function: INTEGER"},
+	}
+	runVmTests(t, tests)
+}
+
+func TestTryInsideFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{`
+let n = 0;
+for (x in [1, 2, 3]) { try { if (x == 2) { break; } n = n + x } finally { n = n + 10 } };
+n
+`, "21"},
+		{"let f = fn(x) { try { 10 / x } catch (e) { -1 } }; [f(2), f(0)]", "[5, -1]"},
+	}
+	runVmTests(t, tests)
+}
+
+func TestStackOverflow(t *testing.T) {
+	program := parser.New(lexer.New("let f = fn(x) { f(x + 1) }; f(0)")).ParseProgram()
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	vm := New(comp.Bytecode())
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	errObj, ok := vm.Result().(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", vm.Result(), vm.Result())
+	}
+	if errObj.Kind != object.CALL_DEPTH_ERROR {
+		t.Errorf("wrong error kind. want=%s, got=%s", object.CALL_DEPTH_ERROR, errObj.Kind)
+	}
+	if len(errObj.Stack) != MaxFrames {
+		t.Errorf("wrong stack depth. want=%d, got=%d", MaxFrames, len(errObj.Stack))
+	}
+}